@@ -0,0 +1,140 @@
+package data
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"io"
+	"strconv"
+)
+
+// ReadCSV reads recorded stream, first line is a header which names columns,
+// `timestamp` and `price` are required, `venue` and `instrument` are optional stream keys,
+// `size` and `side` (buy or sell) are optional trade details
+func ReadCSV(reader io.Reader) (result []*InputData, err error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.ReuseRecord = true
+
+	header, err := csvReader.Read()
+
+	if err != nil {
+		return
+	}
+
+	columns := map[string]int{}
+
+	for index, name := range header {
+		columns[name] = index
+	}
+
+	timestampColumn, okTimestamp := columns["timestamp"]
+	priceColumn, okPrice := columns["price"]
+
+	if !okTimestamp || !okPrice {
+		err = fmt.Errorf("header %v must have timestamp and price columns", header)
+		return
+	}
+
+	venueColumn, okVenue := columns["venue"]
+	instrumentColumn, okInstrument := columns["instrument"]
+	sizeColumn, okSize := columns["size"]
+	sideColumn, okSide := columns["side"]
+
+	for {
+		record, errRead := csvReader.Read()
+
+		if errRead == io.EOF {
+			return
+		}
+
+		if errRead != nil {
+			err = errRead
+			return
+		}
+
+		timestamp, errParse := strconv.ParseUint(record[timestampColumn], 10, 64)
+
+		if errParse != nil {
+			err = fmt.Errorf("wrong timestamp %q: %w", record[timestampColumn], errParse)
+			return
+		}
+
+		cost, errParse := decimal.NewFromString(record[priceColumn])
+
+		if errParse != nil {
+			err = fmt.Errorf("wrong price %q: %w", record[priceColumn], errParse)
+			return
+		}
+
+		log := &InputData{DecimalCost: cost, Timestamp: timestamp}
+
+		if okVenue {
+			log.Venue = record[venueColumn]
+		}
+
+		if okInstrument {
+			log.Instrument = record[instrumentColumn]
+		}
+
+		if okSize && record[sizeColumn] != "" {
+			if log.DecimalSize, errParse = decimal.NewFromString(record[sizeColumn]); errParse != nil {
+				err = fmt.Errorf("wrong size %q: %w", record[sizeColumn], errParse)
+				return
+			}
+		}
+
+		if okSide {
+			if log.Side, errParse = ParseSide(record[sideColumn]); errParse != nil {
+				err = errParse
+				return
+			}
+		}
+
+		result = append(result, log)
+	}
+}
+
+// WriteCSV writes stream in the format ReadCSV reads it, stream keys and trades are written only when there are any
+func WriteCSV(writer io.Writer, data []*InputData) error {
+	csvWriter := csv.NewWriter(writer)
+	withKeys, withTrades := false, false
+
+	for _, log := range data {
+		withKeys = withKeys || log.Key() != (StreamKey{})
+		withTrades = withTrades || !log.DecimalSize.IsZero() || log.Side != SideUnknown
+	}
+
+	header := []string{"timestamp", "price"}
+
+	if withKeys {
+		header = append(header, "venue", "instrument")
+	}
+
+	if withTrades {
+		header = append(header, "size", "side")
+	}
+
+	if err := csvWriter.Write(header); err != nil {
+		return err
+	}
+
+	for _, log := range data {
+		record := []string{strconv.FormatUint(log.Timestamp, 10), log.DecimalCost.String()}
+
+		if withKeys {
+			record = append(record, log.Venue, log.Instrument)
+		}
+
+		if withTrades {
+			record = append(record, log.DecimalSize.String(), log.Side.String())
+		}
+
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+
+	return csvWriter.Error()
+}
@@ -0,0 +1,127 @@
+package data
+
+import (
+	"bytes"
+	"github.com/shopspring/decimal"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	data, err := ReadCSV(strings.NewReader("timestamp,price\n100,65372.5\n105,65373\n"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	await := []*InputData{
+		{DecimalCost: decimal.RequireFromString("65372.5"), Timestamp: 100},
+		{DecimalCost: decimal.NewFromInt(65373), Timestamp: 105},
+	}
+
+	if !reflect.DeepEqual(await, data) {
+		t.Errorf("ReadCSV should be %s, got %s", await, data)
+	}
+
+	if _, err = ReadCSV(strings.NewReader("timestamp,price\nnow,65372.5\n")); err == nil {
+		t.Errorf("ReadCSV should fail on wrong timestamp")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	data := []*InputData{{DecimalCost: decimal.RequireFromString("65372.5"), Timestamp: 100}}
+	buffer := bytes.Buffer{}
+
+	if err := WriteCSV(&buffer, data); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ReadCSV(&buffer)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(data, result) {
+		t.Errorf("WriteCSV result should be read back as %s, got %s", data, result)
+	}
+}
+
+func TestReadCSV_StreamKeys(t *testing.T) {
+	data, err := ReadCSV(strings.NewReader("venue,instrument,timestamp,price\nbinance,BTC/USD,100,65372.5\nkraken,ETH/USD,101,4000\n"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	await := []*InputData{
+		{DecimalCost: decimal.RequireFromString("65372.5"), Timestamp: 100, Venue: "binance", Instrument: "BTC/USD"},
+		{DecimalCost: decimal.NewFromInt(4000), Timestamp: 101, Venue: "kraken", Instrument: "ETH/USD"},
+	}
+
+	if !reflect.DeepEqual(await, data) {
+		t.Errorf("ReadCSV should be %#v, got %#v", await, data)
+	}
+
+	buffer := bytes.Buffer{}
+
+	if err = WriteCSV(&buffer, data); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ReadCSV(&buffer)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(data, result) {
+		t.Errorf("WriteCSV stream keys should be read back as %#v, got %#v", data, result)
+	}
+
+	if _, err = ReadCSV(strings.NewReader("time,price\n100,1\n")); err == nil {
+		t.Errorf("ReadCSV should fail without timestamp column")
+	}
+}
+
+func TestReadCSV_Trades(t *testing.T) {
+	data, err := ReadCSV(strings.NewReader("timestamp,price,size,side\n100,65372.5,0.5,buy\n101,65373,2,sell\n102,65374,,\n"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	await := []*InputData{
+		{DecimalCost: decimal.RequireFromString("65372.5"), Timestamp: 100, DecimalSize: decimal.RequireFromString("0.5"), Side: SideBuy},
+		{DecimalCost: decimal.NewFromInt(65373), Timestamp: 101, DecimalSize: decimal.NewFromInt(2), Side: SideSell},
+		{DecimalCost: decimal.NewFromInt(65374), Timestamp: 102},
+	}
+
+	if !reflect.DeepEqual(await, data) {
+		t.Errorf("ReadCSV should be %#v, got %#v", await, data)
+	}
+
+	buffer := bytes.Buffer{}
+
+	if err = WriteCSV(&buffer, data); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ReadCSV(&buffer)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Zero size is written as 0, so it is compared by value
+	for i := range data {
+		if !data[i].DecimalSize.Equal(result[i].DecimalSize) || data[i].Side != result[i].Side {
+			t.Errorf("WriteCSV trades should be read back as %#v, got %#v", data[i], result[i])
+		}
+	}
+
+	if _, err = ReadCSV(strings.NewReader("timestamp,price,side\n100,1,hold\n")); err == nil {
+		t.Errorf("ReadCSV should fail on unknown side")
+	}
+}
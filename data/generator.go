@@ -0,0 +1,66 @@
+package data
+
+import (
+	"github.com/shopspring/decimal"
+	"math/rand"
+)
+
+// Generator produces a reproducible stream of InputData, the price makes a random walk in cents,
+// so the same Seed always yields the same stream, which is what we need for load testing and regression tracking
+type Generator struct {
+	Seed int64
+	// Rate is the amount of data per second
+	Rate uint64
+	// StartTimestamp first second of the stream
+	StartTimestamp uint64
+	// StartCents initial price in cents, 65372 USD by default
+	StartCents int64
+	// StepCents maximal absolute price change between two consecutive data
+	StepCents int64
+
+	random *rand.Rand
+	cents int64
+	timestamp uint64
+}
+
+func (g *Generator) New(Seed int64, Rate uint64, StartTimestamp uint64) *Generator {
+	g.Seed = Seed
+	g.Rate = Rate
+	g.StartTimestamp = StartTimestamp
+	g.StartCents = 6537200
+	g.StepCents = 50
+	g.Reset()
+	return g
+}
+
+// Reset rewinds the generator to the beginning of the stream
+func (g *Generator) Reset() {
+	g.random = rand.New(rand.NewSource(g.Seed))
+	g.cents = g.StartCents
+	g.timestamp = g.StartTimestamp
+}
+
+// NextSecond returns data for the next second of the stream, result is reused between calls to keep allocations
+// out of measurements, so caller should copy pointers it wants to keep
+func (g *Generator) NextSecond(result []*InputData) []*InputData {
+	result = result[:0]
+
+	for i := uint64(0); i < g.Rate; i++ {
+		g.cents += g.random.Int63n(2*g.StepCents+1) - g.StepCents
+
+		if g.cents < 1 {
+			g.cents = 1
+		}
+
+		result = append(result, &InputData{DecimalCost: decimal.New(g.cents, -2), Timestamp: g.timestamp})
+	}
+
+	g.timestamp++
+
+	return result
+}
+
+// Timestamp returns second which will be generated next
+func (g *Generator) Timestamp() uint64 {
+	return g.timestamp
+}
@@ -0,0 +1,23 @@
+package data
+
+import (
+	"testing"
+)
+
+func TestGenerator_NextSecond(t *testing.T) {
+	g1, g2 := (&Generator{}).New(7, 3, 100), (&Generator{}).New(7, 3, 100)
+
+	for second := 0; second < 5; second++ {
+		data1, data2 := g1.NextSecond(nil), g2.NextSecond(nil)
+
+		for i := range data1 {
+			if !data1[i].DecimalCost.Equal(data2[i].DecimalCost) || data1[i].Timestamp != data2[i].Timestamp {
+				t.Errorf("Generator.NextSecond is not reproducible %s != %s", data1[i], data2[i])
+			}
+
+			if data1[i].Timestamp != uint64(100 + second) {
+				t.Errorf("Generator.NextSecond wrong timestamp %s", data1[i])
+			}
+		}
+	}
+}
@@ -11,8 +11,76 @@ import (
 type InputData struct {
 	DecimalCost decimal.Decimal
 	Timestamp uint64
+
+	// DecimalSize is traded quantity and Side is aggressor side of the trade, they are zero when stream has only prices
+	DecimalSize decimal.Decimal
+	Side Side
+
+	// Venue is exchange data came from and Instrument is traded pair, like BTC/USD, both are empty for a single stream
+	Venue string
+	Instrument string
+}
+
+// Side is aggressor side of a trade
+type Side uint8
+
+const (
+	SideUnknown Side = iota
+	SideBuy
+	SideSell
+)
+
+func (s Side) String() string {
+	switch s {
+	case SideBuy:
+		return "buy"
+	case SideSell:
+		return "sell"
+	default:
+		return ""
+	}
+}
+
+// ParseSide parses side in the format Side.String returns it, empty string is SideUnknown
+func ParseSide(side string) (Side, error) {
+	switch side {
+	case "buy":
+		return SideBuy, nil
+	case "sell":
+		return SideSell, nil
+	case "":
+		return SideUnknown, nil
+	default:
+		return SideUnknown, fmt.Errorf("unknown side %q", side)
+	}
 }
 
+// StreamKey identifies independent stream of data, every key gets its own aggregator and features
+type StreamKey struct {
+	Venue string
+	Instrument string
+}
+
+func (k StreamKey) String() string {
+	return k.Venue + ":" + k.Instrument
+}
+
+// Less orders keys by venue and then by instrument
+func (k StreamKey) Less(k2 StreamKey) bool {
+	if k.Venue != k2.Venue {
+		return k.Venue < k2.Venue
+	}
+
+	return k.Instrument < k2.Instrument
+}
+
+func (d *InputData) Key() StreamKey {
+	return StreamKey{Venue: d.Venue, Instrument: d.Instrument}
+}
+
+// UnwindowedSeconds is window size of features without window (like EMA), they get every data of the tick
+const UnwindowedSeconds = 0
+
 func IsThereAreAnyDataToProcess(TimeCurrent uint64, WindowSeconds uint64, data []*InputData) bool {
 	for _, log := range data {
 		// We are skipping data not in window
@@ -3,134 +3,165 @@ package main
 import (
 	dfeData "data-feature-engineer/data"
 	"errors"
-	"github.com/bits-and-blooms/bitset"
 	"sort"
 )
 
 // DataAggregatorInterface should aggregate data in time manner with respect to available window sizes
+// Lifecycle is per tick: Update replaces everything aggregated on the previous tick,
+// results of GetDataBatch and GetDataForWindow are valid only until the next Update
 type DataAggregatorInterface interface {
 	Update(TimeCurrent uint64, data []*dfeData.InputData)
-	GetDataBatch() (result *[][]*dfeData.InputData, err error)
+	GetDataBatch() (result *DataBatch, err error)
 	GetDataForWindow(WindowSeconds uint64) (result []*dfeData.InputData, err error)
 }
 
+// windowRange is [from, to) range of the sorted per tick buffer
+type windowRange struct {
+	from int
+	to int
+}
+
+// DataBatch is a per tick view of aggregated data keyed by window size,
+// for every window it holds data which are new to that window this tick, sorted by timestamp
+// Views are subslices of one buffer owned by aggregator, they must be treated as read only
+type DataBatch struct {
+	TimeCurrent uint64
+
+	buffer []*dfeData.InputData
+	windowRanges []windowRange
+	windowSecondsMap map[uint64]int
+}
+
+// Get returns data new to the window this tick, window must be one of the aggregator windows or dfeData.UnwindowedSeconds,
+// which is the whole batch
+func (b *DataBatch) Get(WindowSeconds uint64) (result []*dfeData.InputData, err error) {
+	if WindowSeconds == dfeData.UnwindowedSeconds {
+		result = b.buffer[:len(b.buffer):len(b.buffer)]
+		return
+	}
+
+	windowIndex, ok := b.windowSecondsMap[WindowSeconds]
+
+	if !ok {
+		err = errors.New("that window is not prepared")
+		return
+	}
+
+	windowRange := b.windowRanges[windowIndex]
+	// Capacity is limited, so append on a view can't overwrite data of another window
+	result = b.buffer[windowRange.from:windowRange.to:windowRange.to]
+
+	return
+}
+
 type DataAggregator struct {
 	// WindowSeconds are sorted to process minimal first
 	WindowSeconds []uint64
 	WindowSecondsMap map[uint64]int
-	windowDataSlices [][]*dfeData.InputData
+
+	// batch is reused between ticks, so GetDataBatch doesn't allocate
+	batch DataBatch
+	isUpdated bool
 }
 
 func (da *DataAggregator) New(WindowSeconds[] uint64) *DataAggregator {
-	da.WindowSeconds = WindowSeconds
+	// Copy, so we don't reorder slice of the caller
+	da.WindowSeconds = append([]uint64{}, WindowSeconds...)
 	sort.SliceStable(da.WindowSeconds, func (i, j int) bool {
-		return WindowSeconds[i] < WindowSeconds[j]
+		return da.WindowSeconds[i] < da.WindowSeconds[j]
 	})
 
-	da.windowDataSlices = make([][]*dfeData.InputData, len(da.WindowSeconds))
 	da.WindowSecondsMap = make(map[uint64]int)
 
 	for windowIndex, WindowSeconds := range da.WindowSeconds {
 		da.WindowSecondsMap[WindowSeconds] = windowIndex
 	}
 
+	da.batch.windowRanges = make([]windowRange, len(da.WindowSeconds))
+	da.batch.windowSecondsMap = da.WindowSecondsMap
+
 	return da
 }
 
-// Update A Bunch of data comes into this method, then it should be split for available window sizes
-// Data are sorted from the lowest timestamp to highest
-// To lower amount of processing we probably can go through slice in inverse way, but then we must sort(?)
-// So the lowest window can process fewer data
+// Update A Bunch of data comes into this method, it is copied into one buffer sorted by timestamp,
+// then every window is just a range of the buffer: data with |Timestamp - TimeCurrent| <= WindowSeconds
+// Data of the previous tick are dropped, capacity of the buffer is reused
 func (da *DataAggregator) Update(TimeCurrent uint64, data []*dfeData.InputData)  {
-	isDataProcessedBitset := bitset.New(uint(len(data)))
+	da.batch.TimeCurrent = TimeCurrent
+	da.batch.buffer = append(da.batch.buffer[:0], data...)
+	da.isUpdated = true
 
-	// This way the widest computations only for widest window size, in best case where are huge gaps
-	// In data, we're processing much lower amount of data
-	for windowIndex, WindowSeconds := range da.WindowSeconds {
-		for i := len(data) - 1; i >= 0; i-- {
-			// If element is in future we don't care and continue processing,
-			// but if it is before we can stop processing
-			if (data)[i].IsBeforeWindow(TimeCurrent, WindowSeconds) {
-				break
-			}
-
-			if !(data)[i].IsInWindow(TimeCurrent, WindowSeconds) {
-				continue
-			}
-
-			// It is already processed by another window
-			if isDataProcessedBitset.Test(uint(i)) {
-				continue
-			}
-
-			isDataProcessedBitset.Set(uint(i))
-			// Does it always guaranteed for lower window to be with higher timestamps?
-			// Yes because if it fits in window, it is highest, like FIFO^
-			da.windowDataSlices[windowIndex] = append(da.windowDataSlices[windowIndex], (data)[i])
-
-			// Maybe just join slices when supplying proper windowDataSlices, because data duplication
-			// Is very bad for cheap instances because of limited RAM
-			//for appendixWindow := windowIndex + 1; appendixWindow < len(da.WindowSeconds); i++ {
-			//	// If lowest window contains that element, it means that all highest will also contain this
-			//	// Data are inverse sorted
-			//	// How do we effectively inverse slice in Go?
-			//	windowDataSlices[windowIndex] = append(windowDataSlices[windowIndex], data[i])
-			//}
-		}
+	// Data mostly come sorted, so we only pay for sorting when they are not
+	if !isSortedByTimestamp(da.batch.buffer) {
+		sort.Stable(inputDataByTimestamp(da.batch.buffer))
 	}
-}
 
-func (da *DataAggregator) GetDataBatch() (result *[][]*dfeData.InputData, err error) {
-	data := make([][]*dfeData.InputData, len(da.WindowSeconds))
+	for windowIndex, WindowSeconds := range da.WindowSeconds {
+		from := uint64(0)
 
-	for _, WindowSeconds := range da.WindowSeconds {
-		resultInner, errInner := da.GetDataForWindow(WindowSeconds)
+		if TimeCurrent > WindowSeconds {
+			from = TimeCurrent - WindowSeconds
+		}
 
-		if errInner != nil {
-			err = errInner
-			return
+		da.batch.windowRanges[windowIndex] = windowRange{
+			from: searchTimestamp(da.batch.buffer, from),
+			to: searchTimestamp(da.batch.buffer, TimeCurrent + WindowSeconds + 1),
 		}
+	}
+}
 
-		data = append(data, resultInner)
+// GetDataBatch returns view of the last tick for every window, it doesn't allocate
+func (da *DataAggregator) GetDataBatch() (result *DataBatch, err error) {
+	if !da.isUpdated {
+		err = errors.New("aggregator was not updated yet")
+		return
 	}
 
-	result = &data
+	result = &da.batch
 	return
 }
 
 func (da *DataAggregator) GetDataForWindow(WindowSeconds uint64) (result []*dfeData.InputData, err error) {
-	var windowIndex int
-	var ok bool
+	return da.batch.Get(WindowSeconds)
+}
 
-	err = nil
+// searchTimestamp returns index of the first data with Timestamp >= timestamp in sorted data
+func searchTimestamp(data []*dfeData.InputData, timestamp uint64) int {
+	from, to := 0, len(data)
 
-	if windowIndex, ok = da.WindowSecondsMap[WindowSeconds]; !ok {
-		err = errors.New("that window is not prepared")
-		return
+	for from < to {
+		middle := int(uint(from + to) >> 1)
+
+		if data[middle].Timestamp < timestamp {
+			from = middle + 1
+		} else {
+			to = middle
+		}
 	}
 
-	resultLen := 0
+	return from
+}
 
-	for windowIndexSub := windowIndex; windowIndexSub < len(da.WindowSeconds); windowIndexSub++ {
-		resultLen += len(da.windowDataSlices[windowIndexSub])
+func isSortedByTimestamp(data []*dfeData.InputData) bool {
+	for i := 1; i < len(data); i++ {
+		if data[i].Timestamp < data[i-1].Timestamp {
+			return false
+		}
 	}
 
-	data := make([]*dfeData.InputData, 0, resultLen)
+	return true
+}
 
-	for windowIndexSub := windowIndex; windowIndexSub >= 0; windowIndexSub-- {
-		data = append(data, da.windowDataSlices[windowIndexSub]...)
-	}
+type inputDataByTimestamp []*dfeData.InputData
 
-	// There data are actually inverse and they are sorted
-	InverseWindowDataSlice(&data)
-	result = data
+func (d inputDataByTimestamp) Len() int {
+	return len(d)
+}
 
-	return
+func (d inputDataByTimestamp) Less(i, j int) bool {
+	return d[i].Timestamp < d[j].Timestamp
 }
 
-// InverseWindowDataSlice inverses window data slices in place to be RAM effective
-func InverseWindowDataSlice(windowDataSlice *[]*dfeData.InputData) {
-	for i, j := 0, len(*windowDataSlice) - 1; i < j; i, j = i + 1, j-1 {
-		(*windowDataSlice)[i], (*windowDataSlice)[j] = (*windowDataSlice)[j], (*windowDataSlice)[i]
-	}
-}
\ No newline at end of file
+func (d inputDataByTimestamp) Swap(i, j int) {
+	d[i], d[j] = d[j], d[i]
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	dfeData "data-feature-engineer/data"
+	"fmt"
 	"github.com/shopspring/decimal"
 	"reflect"
 	"testing"
@@ -44,39 +45,52 @@ func TestDataAggregator_Update(t *testing.T) {
 
 	aggregator.Update(201, data)
 
-	if len(aggregator.windowDataSlices[0]) != 1 {
-		t.Errorf("TestDataAggregator.Update Wrong amount of elements in window slice [0] should be 1, got %d",
-			len(aggregator.windowDataSlices[0]))
+	// Every window is a range of one sorted buffer: |Timestamp - TimeCurrent| <= WindowSeconds
+	var tests = []struct {
+		WindowSeconds uint64
+		expected []*dfeData.InputData
+	}{
+		{5, []*dfeData.InputData { data[0] }},
+		{15, []*dfeData.InputData { data[0] }},
+		{100, []*dfeData.InputData { data[0], data[1], data[2] }},
+		{3600, data},
 	}
 
-	await := []*dfeData.InputData { data[0] }
+	for _, tt := range tests {
+		result, err := aggregator.GetDataForWindow(tt.WindowSeconds)
 
-	if !reflect.DeepEqual(await, aggregator.windowDataSlices[0]) {
-		t.Errorf("TestDataAggregator.Update Window Data Slices [0] is wrong should be %s, got %s",
-			await, aggregator.windowDataSlices[0])
+		if err != nil {
+			t.Error(err)
+		}
+
+		if !reflect.DeepEqual(tt.expected, result) {
+			t.Errorf("TestDataAggregator.Update Window Data [%d] is wrong should be %s, got %s",
+				tt.WindowSeconds, tt.expected, result)
+		}
 	}
+}
 
-	if len(aggregator.windowDataSlices[2]) != 2 {
-		t.Errorf("TestDataAggregator.Update Wrong amount of elements in window slice [2] should be 3, got %d",
-			len(aggregator.windowDataSlices[2]))
+func TestDataAggregator_Update_Unsorted(t *testing.T) {
+	data := []*dfeData.InputData {
+		{DecimalCost: decimal.NewFromInt(1), Timestamp: 300},
+		{DecimalCost: decimal.NewFromInt(2), Timestamp: 200},
+		{DecimalCost: decimal.NewFromInt(3), Timestamp: 300},
+		{DecimalCost: decimal.NewFromInt(4), Timestamp: 250},
 	}
 
-	await = []*dfeData.InputData { data[1], data[2] }
-	result := aggregator.windowDataSlices[2]
-	InverseWindowDataSlice(&result)
+	aggregator := bootstrapDataAggregator([]uint64 {60, 3600})
+	aggregator.Update(300, data)
+
+	await := []*dfeData.InputData { data[1], data[3], data[0], data[2] }
+	result, _ := aggregator.GetDataForWindow(3600)
 
 	if !reflect.DeepEqual(await, result) {
-		t.Errorf("TestDataAggregator.Update Window Data Slices [2] is wrong should be %s, got %s",
-			await, aggregator.windowDataSlices[2])
+		t.Errorf("TestDataAggregator.Update data should be stable sorted %s, got %s", await, result)
 	}
 
-	await = []*dfeData.InputData { data[3] }
-	result = aggregator.windowDataSlices[3]
-	InverseWindowDataSlice(&result)
-
-	if !reflect.DeepEqual(await, result) {
-		t.Errorf("TestDataAggregator.Update Window Data Slices [3] is wrong should be %s, got %s",
-			await, aggregator.windowDataSlices[3])
+	// Input slice is not touched
+	if data[0].Timestamp != 300 || data[1].Timestamp != 200 {
+		t.Errorf("TestDataAggregator.Update reordered input %s", data)
 	}
 }
 
@@ -103,4 +117,163 @@ func TestDataAggregator_GetDataForWindow(t *testing.T) {
 		t.Errorf("TestDataAggregator.GetDataForWindow(100) is wrong should be %s, got %s",
 			await, result)
 	}
-}
\ No newline at end of file
+}
+
+func TestDataAggregator_GetDataBatch(t *testing.T) {
+	aggregator := bootstrapDataAggregator([]uint64 {5, 15, 100, 3600})
+
+	if _, err := aggregator.GetDataBatch(); err == nil {
+		t.Errorf("TestDataAggregator.GetDataBatch should fail before first Update")
+	}
+
+	data := []*dfeData.InputData {
+		{DecimalCost: decimal.NewFromInt(15), Timestamp: 100},
+		{DecimalCost: decimal.NewFromInt(16), Timestamp: 190},
+		{DecimalCost: decimal.NewFromInt(17), Timestamp: 198},
+		{DecimalCost: decimal.NewFromInt(18), Timestamp: 200},
+	}
+
+	aggregator.Update(200, data)
+	batch, err := aggregator.GetDataBatch()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		WindowSeconds uint64
+		expected []*dfeData.InputData
+	}{
+		{5, []*dfeData.InputData { data[2], data[3] }},
+		{15, []*dfeData.InputData { data[1], data[2], data[3] }},
+		{100, data},
+		{3600, data},
+	}
+
+	for _, tt := range tests {
+		result, err := batch.Get(tt.WindowSeconds)
+
+		if err != nil {
+			t.Error(err)
+		}
+
+		if !reflect.DeepEqual(tt.expected, result) {
+			t.Errorf("TestDataAggregator.GetDataBatch window %d should be %s, got %s", tt.WindowSeconds, tt.expected, result)
+		}
+	}
+
+	if _, err = batch.Get(30); err == nil {
+		t.Errorf("TestDataAggregator.GetDataBatch Get(30) should fail for window which is not prepared")
+	}
+}
+
+// Every Update starts a new tick, data of the previous tick must not leak into the next one
+func TestDataAggregator_Update_MultiTick(t *testing.T) {
+	aggregator := bootstrapDataAggregator([]uint64 {30, 5})
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfeData.InputData
+		expected5 int
+		expected30 int
+	}{
+		{5, []*dfeData.InputData { {DecimalCost: decimal.NewFromInt(1), Timestamp: 3}, {DecimalCost: decimal.NewFromInt(2), Timestamp: 5} }, 2, 2},
+		{10, []*dfeData.InputData { {DecimalCost: decimal.NewFromInt(3), Timestamp: 9} }, 1, 1},
+		// Empty tick clears previous data
+		{15, []*dfeData.InputData {}, 0, 0},
+		// Late data are only new to the wide window
+		{20, []*dfeData.InputData { {DecimalCost: decimal.NewFromInt(4), Timestamp: 2}, {DecimalCost: decimal.NewFromInt(5), Timestamp: 20} }, 1, 2},
+	}
+
+	for i, tick := range ticks {
+		aggregator.Update(tick.TimeCurrent, tick.input)
+		batch, err := aggregator.GetDataBatch()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if batch.TimeCurrent != tick.TimeCurrent {
+			t.Errorf("TestDataAggregator.Update tick %d batch TimeCurrent should be %d, got %d", i, tick.TimeCurrent, batch.TimeCurrent)
+		}
+
+		result5, _ := batch.Get(5)
+		result30, _ := batch.Get(30)
+
+		if len(result5) != tick.expected5 || len(result30) != tick.expected30 {
+			t.Errorf("TestDataAggregator.Update tick %d should have %d and %d data, got %s and %s",
+				i, tick.expected5, tick.expected30, result5, result30)
+		}
+	}
+}
+
+func TestDataAggregator_New_KeepsCallerSlice(t *testing.T) {
+	WindowSeconds := []uint64 {150, 50, 4}
+	bootstrapDataAggregator(WindowSeconds)
+
+	if !reflect.DeepEqual([]uint64 {150, 50, 4}, WindowSeconds) {
+		t.Errorf("TestDataAggregator.New reordered slice of the caller %v", WindowSeconds)
+	}
+}
+
+func bootstrapAggregatorBenchmarkData(amount int) []*dfeData.InputData {
+	data := make([]*dfeData.InputData, 0, amount)
+
+	for i := 0; i < amount; i++ {
+		data = append(data, &dfeData.InputData{DecimalCost: decimal.NewFromInt(int64(i)), Timestamp: uint64(1000 + i * 5 / amount)})
+	}
+
+	return data
+}
+
+func bootstrapWindowSeconds(amount int) []uint64 {
+	WindowSeconds := make([]uint64, 0, amount)
+
+	for i := 1; i <= amount; i++ {
+		WindowSeconds = append(WindowSeconds, uint64(i * 5))
+	}
+
+	return WindowSeconds
+}
+
+// Views are subslices of one buffer, so after the buffer is grown there are no allocations per tick at all
+func TestDataAggregator_Allocations(t *testing.T) {
+	data := bootstrapAggregatorBenchmarkData(1000)
+
+	for _, windows := range []int { 1, 6, 60 } {
+		aggregator := bootstrapDataAggregator(bootstrapWindowSeconds(windows))
+
+		allocations := testing.AllocsPerRun(100, func() {
+			aggregator.Update(1004, data)
+			batch, _ := aggregator.GetDataBatch()
+
+			for _, WindowSeconds := range aggregator.WindowSeconds {
+				batch.Get(WindowSeconds)
+			}
+		})
+
+		if allocations != 0 {
+			t.Errorf("TestDataAggregator.Allocations with %d windows should not allocate, got %f allocations", windows, allocations)
+		}
+	}
+}
+
+func BenchmarkDataAggregator_Update(b *testing.B) {
+	data := bootstrapAggregatorBenchmarkData(1000)
+
+	for _, windows := range []int { 1, 6, 60 } {
+		b.Run(fmt.Sprintf("windows=%d", windows), func(b *testing.B) {
+			aggregator := bootstrapDataAggregator(bootstrapWindowSeconds(windows))
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				aggregator.Update(1004, data)
+				batch, _ := aggregator.GetDataBatch()
+
+				for _, WindowSeconds := range aggregator.WindowSeconds {
+					batch.Get(WindowSeconds)
+				}
+			}
+		})
+	}
+}
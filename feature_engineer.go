@@ -1,10 +1,19 @@
 package main
 
 import (
+	"context"
 	dfedata "data-feature-engineer/data"
 	"data-feature-engineer/features"
+	"data-feature-engineer/storage"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"sync"
 )
 
+// DefaultWindowSeconds window sizes from TZ.md
+var DefaultWindowSeconds = []uint64{5, 30, 60, 300, 1800, 3600}
+
 // FeatureEngineer Should also make data storage for features (should there are be N instances of data storage?)
 // Probably we can make one instance and make use of immutability of Linked List implementation for example
 // When feature mutate storage it mutates only copy available to itself, data are manipulated via pointers,
@@ -12,19 +21,226 @@ import (
 type FeatureEngineer struct {
 	Features []features.Feature
 	DataAggregator DataAggregatorInterface
+
+	// Workers when greater than 1 features are evaluated concurrently by that amount of goroutines,
+	// features are independent (every feature owns its state), so order of evaluation doesn't matter,
+	// vector is still built in order features were appended.
+	// Dependent features are evaluated after their dependencies, so every layer is evaluated concurrently on its own
+	Workers int
+
+	// Variables names of feature values expressions can refer to, see AppendExpressionFeature
+	Variables features.ExpressionVariables
+
+	// layers features including hidden dependencies grouped by depth of dependencies, built for copy of Features
+	// in layersFeatures, Features are exported and can be changed in place, so they are compared on every tick
+	layers [][]features.Feature
+	layersFeatures []features.Feature
+}
+
+func (f *FeatureEngineer) New(WindowSeconds []uint64) *FeatureEngineer {
+	f.DataAggregator = (&DataAggregator{}).New(WindowSeconds)
+	return f
 }
 
 func (f *FeatureEngineer) Update(TimeCurrent uint64, data []*dfedata.InputData) error {
-	f.DataAggregator.Update(TimeCurrent, data)
-	dataBatch, err := f.DataAggregator.GetDataBatch()
+	return f.UpdateContext(context.Background(), TimeCurrent, data)
+}
 
-	if err != nil {
-		return err
+// UpdateContext the first error of any feature cancels evaluation of the rest of features and is returned
+func (f *FeatureEngineer) UpdateContext(ctx context.Context, TimeCurrent uint64, data []*dfedata.InputData) error {
+	var dataBatch *DataBatch
+
+	// Without aggregator every feature gets whole batch and filters it by its own window
+	if f.DataAggregator != nil {
+		var err error
+
+		f.DataAggregator.Update(TimeCurrent, data)
+
+		if dataBatch, err = f.DataAggregator.GetDataBatch(); err != nil {
+			return err
+		}
+	}
+
+	if f.layers == nil || !sameFeatures(f.layersFeatures, f.Features) {
+		var err error
+
+		if f.layers, err = buildLayers(f.Features); err != nil {
+			return err
+		}
+
+		f.layersFeatures = append(f.layersFeatures[:0], f.Features...)
+	}
+
+	for _, layer := range f.layers {
+		if err := f.updateLayer(ctx, TimeCurrent, data, dataBatch, layer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *FeatureEngineer) updateLayer(ctx context.Context, TimeCurrent uint64, data []*dfedata.InputData, dataBatch *DataBatch, layer []features.Feature) error {
+	if f.Workers > 1 && len(layer) > 1 {
+		return f.updateParallel(ctx, TimeCurrent, data, dataBatch, layer)
 	}
 
 	// We need to somehow reuse common data between features
-	for _, feature := range f.Features {
-		feature.Update(TimeCurrent, (*dataBatch)[feature.GetWindowSeconds()])
+	for _, feature := range layer {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := updateFeature(feature, TimeCurrent, data, dataBatch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sameFeatures(a []features.Feature, b []features.Feature) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// buildLayers puts every feature into layer next to the deepest of its dependencies, features without dependencies
+// are in the first layer in order they were appended, so without dependent features there is just one layer
+func buildLayers(appended []features.Feature) ([][]features.Feature, error) {
+	depths := map[features.Feature]int{}
+	visiting := map[features.Feature]bool{}
+	var layers [][]features.Feature
+
+	var visit func(feature features.Feature) (int, error)
+	visit = func(feature features.Feature) (int, error) {
+		if depth, ok := depths[feature]; ok {
+			return depth, nil
+		}
+
+		if visiting[feature] {
+			return 0, errors.New("features have cyclic dependencies")
+		}
+
+		visiting[feature] = true
+		depth := 0
+
+		if dependentFeature, ok := feature.(features.DependentFeature); ok {
+			for _, dependency := range dependentFeature.GetDependencies() {
+				dependencyDepth, err := visit(dependency)
+
+				if err != nil {
+					return 0, err
+				}
+
+				if dependencyDepth + 1 > depth {
+					depth = dependencyDepth + 1
+				}
+			}
+		}
+
+		visiting[feature] = false
+		depths[feature] = depth
+
+		for len(layers) <= depth {
+			layers = append(layers, nil)
+		}
+
+		layers[depth] = append(layers[depth], feature)
+
+		return depth, nil
+	}
+
+	for _, feature := range appended {
+		if _, err := visit(feature); err != nil {
+			return nil, err
+		}
+	}
+
+	return layers, nil
+}
+
+// updateParallel evaluates features of one layer on a bounded pool of workers, pool lives for one layer of tick
+func (f *FeatureEngineer) updateParallel(ctx context.Context, TimeCurrent uint64, data []*dfedata.InputData, dataBatch *DataBatch, layer []features.Feature) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := f.Workers
+
+	if workers > len(layer) {
+		workers = len(layer)
+	}
+
+	var firstErr error
+	var firstErrOnce sync.Once
+	var wg sync.WaitGroup
+	jobs := make(chan features.Feature)
+
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for feature := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				if err := updateFeature(feature, TimeCurrent, data, dataBatch); err != nil {
+					firstErrOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+schedule:
+	for _, feature := range layer {
+		select {
+		case jobs <- feature:
+		case <-ctx.Done():
+			break schedule
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	// Parent context could be cancelled, then not all features were updated
+	return ctx.Err()
+}
+
+// updateFeature feeds feature with data of its window and checks whether it failed
+func updateFeature(feature features.Feature, TimeCurrent uint64, data []*dfedata.InputData, dataBatch *DataBatch) error {
+	windowData := data
+
+	if dataBatch != nil {
+		var err error
+
+		if windowData, err = dataBatch.Get(feature.GetWindowSeconds()); err != nil {
+			return err
+		}
+	}
+
+	feature.Update(TimeCurrent, windowData)
+
+	if fallibleFeature, ok := feature.(features.FallibleFeature); ok {
+		return fallibleFeature.GetError()
 	}
 
 	return nil
@@ -32,4 +248,111 @@ func (f *FeatureEngineer) Update(TimeCurrent uint64, data []*dfedata.InputData)
 
 func (f *FeatureEngineer) AppendFeature(feature features.Feature) {
 	f.Features = append(f.Features, feature)
-}
\ No newline at end of file
+}
+
+// AppendNamedFeature appends feature and names its values for expressions, names are in order of values.
+// Name which is already taken is an error and feature is not appended, expressions would silently refer
+// to another feature otherwise
+func (f *FeatureEngineer) AppendNamedFeature(feature features.Feature, names ...string) error {
+	if f.Variables == nil {
+		f.Variables = features.ExpressionVariables{}
+	}
+
+	for index, name := range names {
+		if _, ok := f.Variables[name]; ok {
+			return fmt.Errorf("feature engineer: name %s is already taken", name)
+		}
+
+		for _, other := range names[:index] {
+			if other == name {
+				return fmt.Errorf("feature engineer: name %s is repeated", name)
+			}
+		}
+	}
+
+	for index, name := range names {
+		f.Variables[name] = features.ExpressionVariable{Feature: feature, Index: index}
+	}
+
+	f.AppendFeature(feature)
+
+	return nil
+}
+
+// AppendExpressionFeature compiles expression over named values, like `(max_300 - min_300) / avg_300`,
+// and appends its feature, it is evaluated after features it refers to
+func (f *FeatureEngineer) AppendExpressionFeature(source string) error {
+	expression, err := features.CompileExpression(source, f.Variables)
+
+	if err != nil {
+		return err
+	}
+
+	f.AppendFeature((&features.ExpressionFeature{}).New(expression))
+
+	return nil
+}
+
+// AppendWindowFeatures appends min, max, avg and stddev for every window size in given order,
+// so vector layout is [min_w0, max_w0, avg_w0, std_w0, min_w1, ...], they are named like min_300,
+// so repeated window is an error, features of windows before it stay appended
+func (f *FeatureEngineer) AppendWindowFeatures(WindowSeconds []uint64) error {
+	for _, windowSeconds := range WindowSeconds {
+		windowFeatures := []struct {
+			feature features.Feature
+			name string
+		}{
+			{(&features.MinFeature{}).New(windowSeconds), "min"},
+			{(&features.MaxFeature{}).New(windowSeconds), "max"},
+			{(&features.AvgFeature{}).New(windowSeconds, &storage.LinkedListDataStorage{}), "avg"},
+			{(&features.StdDevFeature{}).New(windowSeconds, &storage.LinkedListDataStorage{}), "std"},
+		}
+
+		for _, windowFeature := range windowFeatures {
+			if err := f.AppendNamedFeature(windowFeature.feature, fmt.Sprintf("%s_%d", windowFeature.name, windowSeconds)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// AppendBarFeatures appends OHLC bar for every window size in given order,
+// every bar puts [open, high, low, close, count] into vector, they are named like open_300
+func (f *FeatureEngineer) AppendBarFeatures(WindowSeconds []uint64) error {
+	for _, windowSeconds := range WindowSeconds {
+		var names []string
+
+		for _, value := range []string{"open", "high", "low", "close", "count"} {
+			names = append(names, fmt.Sprintf("%s_%d", value, windowSeconds))
+		}
+
+		if err := f.AppendNamedFeature((&features.BarFeature{}).New(windowSeconds), names...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetVector collects last values of all features in order they were appended,
+// features with several values put all of them in their order
+func (f *FeatureEngineer) GetVector() []decimal.Decimal {
+	result := make([]decimal.Decimal, 0, len(f.Features))
+
+	for _, feature := range f.Features {
+		if multiValueFeature, ok := feature.(features.MultiValueFeature); ok {
+			result = append(result, multiValueFeature.GetValues()...)
+			continue
+		}
+
+		result = append(result, feature.GetValue())
+	}
+
+	return result
+}
+
+func (f *FeatureEngineer) Emit(TimeCurrent uint64, emit func(vector Vector)) {
+	emit(Vector{TimeCurrent: TimeCurrent, Values: f.GetVector()})
+}
@@ -1,11 +1,26 @@
 package main
 
 import (
+	"context"
+	dfeData "data-feature-engineer/data"
 	"data-feature-engineer/features"
 	"data-feature-engineer/storage"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"reflect"
 	"testing"
 )
 
+// bootstrapWindowFeatures appends window features of distinct windows, which never fails
+func bootstrapWindowFeatures(featureEngineer *FeatureEngineer, WindowSeconds []uint64) *FeatureEngineer {
+	if err := featureEngineer.AppendWindowFeatures(WindowSeconds); err != nil {
+		panic(err)
+	}
+
+	return featureEngineer
+}
+
 func TestFeatureEngineer_AppendFeature(t *testing.T) {
 	f := features.MaxFeature{}
 	f.New(100)
@@ -17,4 +32,329 @@ func TestFeatureEngineer_AppendFeature(t *testing.T) {
 	fe := FeatureEngineer{}
 	fe.AppendFeature(&f)
 	fe.AppendFeature(&f2)
-}
\ No newline at end of file
+}
+
+// Features fed through aggregator must be equal to features filtering whole batch on their own
+func TestFeatureEngineer_Update(t *testing.T) {
+	aggregated := bootstrapWindowFeatures((&FeatureEngineer{}).New(DefaultWindowSeconds), DefaultWindowSeconds)
+	direct := bootstrapWindowFeatures(&FeatureEngineer{}, DefaultWindowSeconds)
+	generator := (&dfeData.Generator{}).New(3, 2, 1000)
+
+	for tick := 0; tick < 30; tick++ {
+		var batch []*dfeData.InputData
+
+		// Every third tick is empty to check carried values
+		if tick % 3 != 2 {
+			for second := 0; second < DefaultTickSeconds; second++ {
+				batch = append(batch, generator.NextSecond(nil)...)
+			}
+		} else {
+			for second := 0; second < DefaultTickSeconds; second++ {
+				generator.NextSecond(nil)
+			}
+		}
+
+		TimeCurrent := generator.Timestamp() - 1
+
+		if err := aggregated.Update(TimeCurrent, batch); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := direct.Update(TimeCurrent, batch); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(direct.GetVector(), aggregated.GetVector()) {
+			t.Errorf("FeatureEngineer.Update tick %d should be %v, got %v", tick, direct.GetVector(), aggregated.GetVector())
+		}
+	}
+}
+
+func TestFeatureEngineer_Update_UnknownWindow(t *testing.T) {
+	fe := (&FeatureEngineer{}).New([]uint64 { 5 })
+	fe.AppendFeature((&features.MaxFeature{}).New(30))
+
+	if err := fe.Update(5, nil); err == nil {
+		t.Errorf("FeatureEngineer.Update should fail for feature with window which aggregator doesn't prepare")
+	}
+}
+
+func TestFeatureEngineer_Update_Parallel(t *testing.T) {
+	serial := bootstrapWindowFeatures((&FeatureEngineer{}).New(DefaultWindowSeconds), DefaultWindowSeconds)
+	parallel := bootstrapWindowFeatures((&FeatureEngineer{Workers: 4}).New(DefaultWindowSeconds), DefaultWindowSeconds)
+	generator := (&dfeData.Generator{}).New(5, 3, 1000)
+
+	for tick := 0; tick < 30; tick++ {
+		var batch []*dfeData.InputData
+
+		for second := 0; second < DefaultTickSeconds; second++ {
+			batch = append(batch, generator.NextSecond(nil)...)
+		}
+
+		TimeCurrent := generator.Timestamp() - 1
+
+		if err := serial.Update(TimeCurrent, batch); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := parallel.Update(TimeCurrent, batch); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(serial.GetVector(), parallel.GetVector()) {
+			t.Errorf("FeatureEngineer.Update parallel tick %d should be %v, got %v", tick, serial.GetVector(), parallel.GetVector())
+		}
+	}
+}
+
+type failingFeature struct {
+	features.BasicFeature
+}
+
+func (f *failingFeature) GetError() error {
+	return errors.New("feature failed")
+}
+
+func TestFeatureEngineer_Update_ParallelError(t *testing.T) {
+	for _, workers := range []int { 1, 4 } {
+		fe := (&FeatureEngineer{Workers: workers}).New([]uint64 { 5 })
+		failing := &failingFeature{}
+		failing.WindowSeconds = 5
+
+		fe.AppendFeature(failing)
+
+		for i := 0; i < 100; i++ {
+			fe.AppendFeature((&features.MaxFeature{}).New(5))
+		}
+
+		err := fe.Update(5, []*dfeData.InputData { {DecimalCost: decimal.NewFromInt(1), Timestamp: 5} })
+
+		if err == nil || err.Error() != "feature failed" {
+			t.Errorf("FeatureEngineer.Update with %d workers should return error of failed feature, got %v", workers, err)
+		}
+	}
+}
+
+func TestFeatureEngineer_UpdateContext_Cancelled(t *testing.T) {
+	fe := bootstrapWindowFeatures((&FeatureEngineer{Workers: 4}).New(DefaultWindowSeconds), DefaultWindowSeconds)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := fe.UpdateContext(ctx, 5, nil); err != context.Canceled {
+		t.Errorf("FeatureEngineer.UpdateContext should return context error, got %v", err)
+	}
+}
+
+func BenchmarkFeatureEngineer_Update_Workers(b *testing.B) {
+	for _, workers := range []int { 1, 4, 8 } {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			featureEngineer := bootstrapWindowFeatures((&FeatureEngineer{Workers: workers}).New(DefaultWindowSeconds), DefaultWindowSeconds)
+			generator := (&dfeData.Generator{}).New(1, 1000, 0)
+			batch := make([]*dfeData.InputData, 0, 1000 * DefaultTickSeconds)
+			second := make([]*dfeData.InputData, 0, 1000)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				batch = batch[:0]
+
+				for s := 0; s < DefaultTickSeconds; s++ {
+					second = generator.NextSecond(second)
+					batch = append(batch, second...)
+				}
+
+				b.StartTimer()
+
+				if err := featureEngineer.Update(generator.Timestamp() - 1, batch); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestFeatureEngineer_GetVector_MultiValue(t *testing.T) {
+	fe := (&FeatureEngineer{}).New([]uint64 { 5 })
+	fe.AppendFeature((&features.MaxFeature{}).New(5))
+	fe.AppendFeature((&features.QuantileFeature{}).New(5, 5, 100, 0.5, 0.95))
+
+	if err := fe.Update(5, []*dfeData.InputData { {DecimalCost: decimal.NewFromInt(7), Timestamp: 5} }); err != nil {
+		t.Fatal(err)
+	}
+
+	// max, median, p95, IQR
+	await := []decimal.Decimal { decimal.NewFromInt(7), decimal.NewFromInt(7), decimal.NewFromInt(7), decimal.NewFromInt(0) }
+	result := fe.GetVector()
+
+	if len(result) != len(await) {
+		t.Fatalf("FeatureEngineer.GetVector should be %v, got %v", await, result)
+	}
+
+	for i := range await {
+		if !await[i].Equal(result[i]) {
+			t.Errorf("FeatureEngineer.GetVector should be %v, got %v", await, result)
+		}
+	}
+}
+
+// Unwindowed features get whole batch, even when aggregator has no windows for them
+func TestFeatureEngineer_Update_Unwindowed(t *testing.T) {
+	fe := (&FeatureEngineer{}).New([]uint64 { 5 })
+	fe.AppendFeature((&features.EMAFeature{}).New(5))
+
+	data := []*dfeData.InputData {
+		{DecimalCost: decimal.NewFromInt(10), Timestamp: 1},
+		{DecimalCost: decimal.NewFromInt(20), Timestamp: 1},
+	}
+
+	if err := fe.Update(100, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := fe.GetVector(); !result[0].Equal(decimal.NewFromInt(15)) {
+		t.Errorf("FeatureEngineer.Update unwindowed EMA should be 15, got %v", result)
+	}
+}
+
+// cyclicFeature depends on whatever it is told to
+type cyclicFeature struct {
+	dependencies []features.Feature
+
+	features.BasicFeature
+}
+
+func (f *cyclicFeature) GetDependencies() []features.Feature {
+	return f.dependencies
+}
+
+func TestFeatureEngineer_Update_Dependencies(t *testing.T) {
+	generator := (&dfeData.Generator{}).New(4, 3, 1000)
+
+	// Dependency of the serial engineer is hidden, it is not in the vector
+	serial := (&FeatureEngineer{}).New([]uint64 { 30 })
+	serial.AppendFeature((&features.ZScoreFeature{}).New((&features.StdDevFeature{}).New(30, &storage.LinkedListDataStorage{})))
+
+	parallel := (&FeatureEngineer{Workers: 4}).New([]uint64 { 30 })
+	stdDev := (&features.StdDevFeature{}).New(30, &storage.LinkedListDataStorage{})
+	parallel.AppendFeature((&features.ZScoreFeature{}).New(stdDev))
+	parallel.AppendFeature(stdDev)
+	parallel.AppendFeature((&features.MaxFeature{}).New(30))
+
+	for tick := 0; tick < 20; tick++ {
+		var batch []*dfeData.InputData
+
+		for second := 0; second < DefaultTickSeconds; second++ {
+			batch = append(batch, generator.NextSecond(nil)...)
+		}
+
+		TimeCurrent := generator.Timestamp() - 1
+
+		if err := serial.Update(TimeCurrent, batch); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := parallel.Update(TimeCurrent, batch); err != nil {
+			t.Fatal(err)
+		}
+
+		serialVector, parallelVector := serial.GetVector(), parallel.GetVector()
+
+		if len(serialVector) != 1 || len(parallelVector) != 3 {
+			t.Fatalf("FeatureEngineer.GetVector should have only appended features, got %v and %v", serialVector, parallelVector)
+		}
+
+		if !serialVector[0].Equal(parallelVector[0]) {
+			t.Errorf("FeatureEngineer.Update tick %d z-score should be %s, got %s", tick, serialVector[0], parallelVector[0])
+		}
+	}
+}
+
+func TestFeatureEngineer_Update_CyclicDependencies(t *testing.T) {
+	first, second := &cyclicFeature{}, &cyclicFeature{}
+	first.dependencies = []features.Feature{second}
+	second.dependencies = []features.Feature{first}
+
+	fe := FeatureEngineer{}
+	fe.AppendFeature(first)
+
+	if err := fe.Update(5, nil); err == nil {
+		t.Errorf("FeatureEngineer.Update should fail for cyclic dependencies")
+	}
+}
+
+// Features replaced in place keep the amount, layers still have to be rebuilt for the new one
+func TestFeatureEngineer_Update_ReplacedFeature(t *testing.T) {
+	fe := (&FeatureEngineer{}).New([]uint64 { 5 })
+	fe.AppendFeature((&features.MaxFeature{}).New(5))
+
+	data := []*dfeData.InputData{{DecimalCost: decimal.NewFromInt(10), Timestamp: 4}}
+
+	if err := fe.Update(5, data); err != nil {
+		t.Fatal(err)
+	}
+
+	ema := (&features.EMAFeature{}).New(5)
+	fe.Features[0] = (&features.EWMVarianceFeature{}).New(ema)
+
+	if err := fe.Update(10, []*dfeData.InputData{{DecimalCost: decimal.NewFromInt(12), Timestamp: 9}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if ema.GetAmount() != 1 {
+		t.Errorf("FeatureEngineer.Update should update dependency of replaced feature, got %d data", ema.GetAmount())
+	}
+}
+
+func TestFeatureEngineer_AppendExpressionFeature(t *testing.T) {
+	fe := bootstrapWindowFeatures((&FeatureEngineer{Workers: 4}).New([]uint64 { 5, 30 }), []uint64 { 5, 30 })
+
+	if err := fe.AppendExpressionFeature("(max_30 - min_30) / avg_30"); err != nil {
+		t.Fatal(err)
+	}
+
+	generator := (&dfeData.Generator{}).New(6, 3, 1000)
+
+	for tick := 0; tick < 10; tick++ {
+		var batch []*dfeData.InputData
+
+		for second := 0; second < DefaultTickSeconds; second++ {
+			batch = append(batch, generator.NextSecond(nil)...)
+		}
+
+		if err := fe.Update(generator.Timestamp() - 1, batch); err != nil {
+			t.Fatal(err)
+		}
+
+		vector := fe.GetVector()
+		// min_30, max_30 and avg_30 go after four features of 5 seconds window
+		await := vector[5].Sub(vector[4]).DivRound(vector[6], int32(decimal.DivisionPrecision))
+
+		if !vector[8].Equal(await) {
+			t.Errorf("FeatureEngineer.AppendExpressionFeature tick %d should be %s, got %s", tick, await, vector[8])
+		}
+	}
+}
+
+func TestFeatureEngineer_AppendNamedFeature_Duplicate(t *testing.T) {
+	for _, names := range [][]string{{"max_5"}, {"a", "a"}} {
+		fe := bootstrapWindowFeatures((&FeatureEngineer{}).New([]uint64 { 5 }), []uint64 { 5 })
+
+		if err := fe.AppendNamedFeature((&features.BarFeature{}).New(5), names...); err == nil {
+			t.Errorf("FeatureEngineer.AppendNamedFeature(%v) should fail", names)
+		}
+
+		if len(fe.Features) != 4 {
+			t.Errorf("FeatureEngineer.AppendNamedFeature(%v) should not append feature", names)
+		}
+	}
+}
+
+func TestFeatureEngineer_AppendWindowFeatures_Repeated(t *testing.T) {
+	fe := (&FeatureEngineer{}).New([]uint64 { 5, 30 })
+
+	if err := fe.AppendWindowFeatures([]uint64 { 5, 30, 5 }); err == nil {
+		t.Errorf("FeatureEngineer.AppendWindowFeatures should fail for repeated window")
+	}
+}
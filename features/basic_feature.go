@@ -2,6 +2,7 @@ package features
 
 import (
 	dfedata "data-feature-engineer/data"
+	"fmt"
 	decimal "github.com/shopspring/decimal"
 )
 
@@ -16,6 +17,9 @@ type ConnectionChannelData struct {
 	ProvidingFeature Feature
 }
 
+// UnwindowedSeconds see data.UnwindowedSeconds
+const UnwindowedSeconds = dfedata.UnwindowedSeconds
+
 type Feature interface {
 	Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData)
 
@@ -27,6 +31,39 @@ type Feature interface {
 	GetWindowSeconds() uint64
 }
 
+// MultiValueFeature is implemented by features producing several values at once, like a set of quantiles,
+// FeatureEngineer puts all of them into the vector instead of GetValue, amount of values must not change
+type MultiValueFeature interface {
+	GetValues() []decimal.Decimal
+}
+
+// FallibleFeature is implemented by features which can fail during Update, since Update itself doesn't return error
+// FeatureEngineer checks GetError after every Update and stops evaluation on the first error
+type FallibleFeature interface {
+	GetError() error
+}
+
+// checkPaneSeconds features splitting window into panes need panes of at least a second which split window evenly,
+// otherwise window edge drifts from pane edges
+func checkPaneSeconds(WindowSeconds uint64, PaneSeconds uint64) error {
+	if PaneSeconds == 0 {
+		return fmt.Errorf("pane must be at least 1 second")
+	}
+
+	if WindowSeconds % PaneSeconds != 0 {
+		return fmt.Errorf("pane of %d seconds doesn't divide window of %d seconds", PaneSeconds, WindowSeconds)
+	}
+
+	return nil
+}
+
+// DependentFeature is implemented by features computed from state of other features, like z-score from StdDevFeature,
+// FeatureEngineer updates dependencies before the feature on every tick, dependencies which were not appended
+// to FeatureEngineer are updated too, but they are not in the vector
+type DependentFeature interface {
+	GetDependencies() []Feature
+}
+
 // BasicFeature TODO caching for calculations for same feature different window sizes
 // Like for example for AvgFeature that will not work
 // But for MinMax that should work, we can probably chain sub minmax calls? Like divide and conquer algorithm
@@ -0,0 +1,6 @@
+// Package features running features over windows of InputData and features derived from them.
+//
+// ScriptFeature runs user scripts with bounded steps, bounded allocations per tick and bounded state retained
+// between ticks, see ScriptLimits. Allocations are estimated, not measured, so limits of the process itself
+// are still a good idea for untrusted scripts
+package features
@@ -0,0 +1,573 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"math"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ExpressionVariable value of feature expression refers to, Index is index of value of MultiValueFeature
+type ExpressionVariable struct {
+	Feature Feature
+	Index int
+}
+
+// ExpressionVariables variables by names expression can refer to
+type ExpressionVariables map[string]ExpressionVariable
+
+// Expression is compiled once into a tree of evaluators, evaluation reads current values of variables.
+//
+// Grammar, from the lowest precedence:
+//   condition ? then : else
+//   ||
+//   &&
+//   == !=
+//   < <= > >=
+//   + -
+//   * /
+//   unary - !
+//   number, variable, (expression), function(arguments)
+//
+// Functions are log, exp, sqrt, abs, min and max. Comparisons and logical operators are 1 or 0, any non zero is true.
+// Only the chosen branch of condition is evaluated, so `avg_300 != 0 ? max_300 / avg_300 : 0` is always defined.
+// Division by zero, log of non positive and sqrt of negative are undefined, undefined propagates to the result
+type Expression struct {
+	Source string
+
+	evaluate evaluator
+	dependencies []Feature
+}
+
+// evaluator returns false when result is undefined
+type evaluator func() (decimal.Decimal, bool)
+
+// CompileExpression parses source and resolves its variables, unknown variable is an error
+func CompileExpression(source string, variables ExpressionVariables) (*Expression, error) {
+	tokens, err := tokenizeExpression(source)
+
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", source, err)
+	}
+
+	p := &expressionParser{tokens: tokens, variables: variables, dependencies: map[Feature]bool{}}
+	expression := &Expression{Source: source}
+
+	if expression.evaluate, err = p.parseTernary(); err != nil {
+		return nil, fmt.Errorf("expression %q: %w", source, err)
+	}
+
+	if token := p.peek(); token.kind != tokenEnd {
+		return nil, fmt.Errorf("expression %q: unexpected %q at %d", source, token.text, token.position)
+	}
+
+	expression.dependencies = p.dependencyOrder
+
+	return expression, nil
+}
+
+// Evaluate returns false when result is undefined
+func (e *Expression) Evaluate() (decimal.Decimal, bool) {
+	return e.evaluate()
+}
+
+// Dependencies features expression refers to, in order of the first reference
+func (e *Expression) Dependencies() []Feature {
+	return e.dependencies
+}
+
+type tokenKind uint8
+
+const (
+	tokenEnd tokenKind = iota
+	tokenNumber
+	tokenIdentifier
+	tokenOperator
+)
+
+type expressionToken struct {
+	kind tokenKind
+	text string
+	position int
+}
+
+// expressionOperators longer first, so `<=` is not read as `<`
+var expressionOperators = []string{"<=", ">=", "==", "!=", "&&", "||", "+", "-", "*", "/", "(", ")", ",", "?", ":", "<", ">", "!"}
+
+// hasRunesPrefix is strings.HasPrefix without converting the rest of source back to string for every candidate
+func hasRunesPrefix(runes []rune, prefix string) bool {
+	i := 0
+
+	for _, r := range prefix {
+		if i >= len(runes) || runes[i] != r {
+			return false
+		}
+
+		i++
+	}
+
+	return true
+}
+
+func tokenizeExpression(source string) ([]expressionToken, error) {
+	var tokens []expressionToken
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+
+			// Exponent like 1e-3
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				i++
+
+				if i < len(runes) && (runes[i] == '-' || runes[i] == '+') {
+					i++
+				}
+
+				for i < len(runes) && unicode.IsDigit(runes[i]) {
+					i++
+				}
+			}
+
+			tokens = append(tokens, expressionToken{kind: tokenNumber, text: string(runes[start:i]), position: start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+
+			tokens = append(tokens, expressionToken{kind: tokenIdentifier, text: string(runes[start:i]), position: start})
+		default:
+			operator := ""
+
+			for _, candidate := range expressionOperators {
+				if hasRunesPrefix(runes[i:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected %q at %d", string(r), i)
+			}
+
+			tokens = append(tokens, expressionToken{kind: tokenOperator, text: operator, position: i})
+			i += utf8.RuneCountInString(operator)
+		}
+	}
+
+	return append(tokens, expressionToken{kind: tokenEnd, position: len(runes)}), nil
+}
+
+type expressionParser struct {
+	tokens []expressionToken
+	next int
+	variables ExpressionVariables
+	dependencies map[Feature]bool
+	dependencyOrder []Feature
+}
+
+func (p *expressionParser) peek() expressionToken {
+	return p.tokens[p.next]
+}
+
+// accept consumes operator if it is the next token
+func (p *expressionParser) accept(operator string) bool {
+	if token := p.peek(); token.kind == tokenOperator && token.text == operator {
+		p.next++
+		return true
+	}
+
+	return false
+}
+
+func (p *expressionParser) expect(operator string) error {
+	if !p.accept(operator) {
+		token := p.peek()
+		return fmt.Errorf("expected %q at %d, got %q", operator, token.position, token.text)
+	}
+
+	return nil
+}
+
+func (p *expressionParser) parseTernary() (evaluator, error) {
+	condition, err := p.parseBinary(0)
+
+	if err != nil || !p.accept("?") {
+		return condition, err
+	}
+
+	then, err := p.parseTernary()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.expect(":"); err != nil {
+		return nil, err
+	}
+
+	otherwise, err := p.parseTernary()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return func() (decimal.Decimal, bool) {
+		value, ok := condition()
+
+		if !ok {
+			return value, false
+		}
+
+		if !value.IsZero() {
+			return then()
+		}
+
+		return otherwise()
+	}, nil
+}
+
+// binaryLevels operators by precedence from the lowest, all of them are left associative
+var binaryLevels = [][]string{{"||"}, {"&&"}, {"==", "!="}, {"<", "<=", ">", ">="}, {"+", "-"}, {"*", "/"}}
+
+func (p *expressionParser) parseBinary(level int) (evaluator, error) {
+	if level == len(binaryLevels) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		operator := ""
+
+		for _, candidate := range binaryLevels[level] {
+			if p.accept(candidate) {
+				operator = candidate
+				break
+			}
+		}
+
+		if operator == "" {
+			return left, nil
+		}
+
+		right, err := p.parseBinary(level + 1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		left = binaryEvaluator(operator, left, right)
+	}
+}
+
+func binaryEvaluator(operator string, left evaluator, right evaluator) evaluator {
+	// Logical operators don't evaluate right side when left one decides
+	switch operator {
+	case "&&", "||":
+		return func() (decimal.Decimal, bool) {
+			value, ok := left()
+
+			if !ok || value.IsZero() == (operator == "&&") {
+				return boolDecimal(!value.IsZero()), ok
+			}
+
+			value, ok = right()
+
+			return boolDecimal(!value.IsZero()), ok
+		}
+	}
+
+	return func() (decimal.Decimal, bool) {
+		a, ok := left()
+
+		if !ok {
+			return a, false
+		}
+
+		b, ok := right()
+
+		if !ok {
+			return b, false
+		}
+
+		switch operator {
+		case "+":
+			return a.Add(b), true
+		case "-":
+			return a.Sub(b), true
+		case "*":
+			return a.Mul(b), true
+		case "/":
+			if b.IsZero() {
+				return decimal.Zero, false
+			}
+
+			return a.DivRound(b, int32(decimal.DivisionPrecision)), true
+		case "==":
+			return boolDecimal(a.Equal(b)), true
+		case "!=":
+			return boolDecimal(!a.Equal(b)), true
+		case "<":
+			return boolDecimal(a.LessThan(b)), true
+		case "<=":
+			return boolDecimal(a.LessThanOrEqual(b)), true
+		case ">":
+			return boolDecimal(a.GreaterThan(b)), true
+		default:
+			return boolDecimal(a.GreaterThanOrEqual(b)), true
+		}
+	}
+}
+
+func boolDecimal(value bool) decimal.Decimal {
+	if value {
+		return decimal.NewFromInt(1)
+	}
+
+	return decimal.NewFromInt(0)
+}
+
+func (p *expressionParser) parseUnary() (evaluator, error) {
+	for _, operator := range []string{"-", "!"} {
+		if !p.accept(operator) {
+			continue
+		}
+
+		operand, err := p.parseUnary()
+
+		if err != nil {
+			return nil, err
+		}
+
+		negate := operator == "-"
+
+		return func() (decimal.Decimal, bool) {
+			value, ok := operand()
+
+			if negate {
+				return value.Neg(), ok
+			}
+
+			return boolDecimal(value.IsZero()), ok
+		}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *expressionParser) parsePrimary() (evaluator, error) {
+	token := p.peek()
+
+	switch token.kind {
+	case tokenNumber:
+		p.next++
+		value, err := decimal.NewFromString(token.text)
+
+		if err != nil {
+			return nil, fmt.Errorf("wrong number %q at %d", token.text, token.position)
+		}
+
+		return func() (decimal.Decimal, bool) {
+			return value, true
+		}, nil
+	case tokenIdentifier:
+		p.next++
+
+		if p.accept("(") {
+			return p.parseCall(token)
+		}
+
+		return p.variable(token)
+	}
+
+	if p.accept("(") {
+		inner, err := p.parseTernary()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return inner, p.expect(")")
+	}
+
+	return nil, fmt.Errorf("unexpected %q at %d", token.text, token.position)
+}
+
+func (p *expressionParser) variable(token expressionToken) (evaluator, error) {
+	variable, ok := p.variables[token.text]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown variable %q at %d", token.text, token.position)
+	}
+
+	if !p.dependencies[variable.Feature] {
+		p.dependencies[variable.Feature] = true
+		p.dependencyOrder = append(p.dependencyOrder, variable.Feature)
+	}
+
+	feature, index := variable.Feature, variable.Index
+
+	if multiValueFeature, ok := feature.(MultiValueFeature); ok {
+		if index < 0 || index >= len(multiValueFeature.GetValues()) {
+			return nil, fmt.Errorf("variable %q refers to value %d of %d", token.text, index, len(multiValueFeature.GetValues()))
+		}
+
+		return func() (decimal.Decimal, bool) {
+			return multiValueFeature.GetValues()[index], true
+		}, nil
+	}
+
+	if index != 0 {
+		return nil, fmt.Errorf("variable %q refers to value %d of feature with one value", token.text, index)
+	}
+
+	return func() (decimal.Decimal, bool) {
+		return feature.GetValue(), true
+	}, nil
+}
+
+// expressionFunctions by name with amount of arguments, 0 means any positive amount
+var expressionFunctions = map[string]int{"log": 1, "exp": 1, "sqrt": 1, "abs": 1, "min": 0, "max": 0}
+
+func (p *expressionParser) parseCall(name expressionToken) (evaluator, error) {
+	arity, ok := expressionFunctions[name.text]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at %d", name.text, name.position)
+	}
+
+	var arguments []evaluator
+
+	for !p.accept(")") {
+		if len(arguments) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		argument, err := p.parseTernary()
+
+		if err != nil {
+			return nil, err
+		}
+
+		arguments = append(arguments, argument)
+	}
+
+	if len(arguments) == 0 || (arity > 0 && len(arguments) != arity) {
+		return nil, fmt.Errorf("function %q at %d got %d arguments", name.text, name.position, len(arguments))
+	}
+
+	switch name.text {
+	case "min", "max":
+		pick := decimal.Min
+
+		if name.text == "max" {
+			pick = decimal.Max
+		}
+
+		return func() (decimal.Decimal, bool) {
+			result, ok := arguments[0]()
+
+			for _, argument := range arguments[1:] {
+				if !ok {
+					break
+				}
+
+				var value decimal.Decimal
+				value, ok = argument()
+				result = pick(result, value)
+			}
+
+			return result, ok
+		}, nil
+	}
+
+	argument, function := arguments[0], name.text
+
+	return func() (decimal.Decimal, bool) {
+		value, ok := argument()
+
+		if !ok {
+			return value, false
+		}
+
+		switch function {
+		case "abs":
+			return value.Abs(), true
+		case "log":
+			if !value.IsPositive() {
+				return decimal.Zero, false
+			}
+
+			return floatResult(math.Log(value.InexactFloat64()))
+		case "sqrt":
+			if value.IsNegative() {
+				return decimal.Zero, false
+			}
+
+			return floatResult(math.Sqrt(value.InexactFloat64()))
+		default:
+			return floatResult(math.Exp(value.InexactFloat64()))
+		}
+	}, nil
+}
+
+// floatResult functions are computed on floats, decimal beyond float range becomes infinity or 0,
+// then result is not a number and it is undefined
+func floatResult(result float64) (decimal.Decimal, bool) {
+	if math.IsInf(result, 0) || math.IsNaN(result) {
+		return decimal.Zero, false
+	}
+
+	return decimal.NewFromFloat(result), true
+}
+
+// ExpressionFeature value of compiled Expression, it is evaluated after features it refers to, see DependentFeature.
+// Undefined result is 0
+type ExpressionFeature struct {
+	Expression *Expression
+
+	BasicFeature
+}
+
+func (f *ExpressionFeature) New(Expression *Expression) *ExpressionFeature {
+	f.Expression = Expression
+	f.LastValue = decimal.NewFromInt(0)
+	f.WindowSeconds = UnwindowedSeconds
+	return f
+}
+
+func (f *ExpressionFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	value, ok := f.Expression.Evaluate()
+
+	if !ok {
+		value = decimal.NewFromInt(0)
+	}
+
+	f.LastValue = value
+	f.OnUpdated(TimeCurrent, data)
+}
+
+func (f *ExpressionFeature) GetDependencies() []Feature {
+	return f.Expression.Dependencies()
+}
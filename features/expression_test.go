@@ -0,0 +1,122 @@
+package features
+
+import (
+	"github.com/shopspring/decimal"
+	"testing"
+)
+
+func bootstrapExpressionVariables() ExpressionVariables {
+	extremeTime := &ExtremeTimeFeature{LastValues: []decimal.Decimal{decimal.NewFromInt(100), decimal.NewFromInt(7)}}
+
+	return ExpressionVariables{
+		"max_300": {Feature: &BasicFeature{LastValue: decimal.NewFromInt(12)}},
+		"min_300": {Feature: &BasicFeature{LastValue: decimal.NewFromInt(8)}},
+		"avg_300": {Feature: &BasicFeature{LastValue: decimal.NewFromInt(10)}},
+		"zero": {Feature: &BasicFeature{LastValue: decimal.NewFromInt(0)}},
+		"since_max": {Feature: extremeTime, Index: 1},
+	}
+}
+
+func TestCompileExpression_Evaluate(t *testing.T) {
+	var tests = []struct {
+		source string
+		expected string
+		defined bool
+	}{
+		{"(max_300 - min_300) / avg_300", "0.4", true},
+		{"max_300 / avg_300 - 1", "0.2", true},
+		{"1 + 2 * 3 - 4 / 2", "5", true},
+		{"-avg_300 + --2", "-8", true},
+		{"2 * (3 + since_max)", "20", true},
+		{"1.5e1 + .5", "15.5", true},
+		{"max_300 > min_300", "1", true},
+		{"max_300 <= min_300 || avg_300 == 10 && !zero", "1", true},
+		{"min_300 != 8", "0", true},
+		{"zero != 0 ? avg_300 / zero : -1", "-1", true},
+		{"avg_300 > 5 ? avg_300 > 20 ? 2 : 1 : 0", "1", true},
+		{"abs(min_300 - max_300) + sqrt(16) + max(1, avg_300, 3) + min(zero, -2)", "16", true},
+		{"log(1) + exp(0)", "1", true},
+		{"avg_300 / zero", "0", false},
+		{"log(zero) + 1", "0", false},
+		{"sqrt(-1)", "0", false},
+		// Decimals beyond float range
+		{"exp(1000)", "0", false},
+		{"log(exp(700) * exp(700))", "0", false},
+		{"sqrt(exp(700) * exp(700))", "0", false},
+		{"log(exp(-700) * exp(-700))", "0", false},
+		// Right side is not evaluated, so it is defined
+		{"zero && 1 / zero", "0", true},
+	}
+
+	for _, tt := range tests {
+		expression, err := CompileExpression(tt.source, bootstrapExpressionVariables())
+
+		if err != nil {
+			t.Fatalf("CompileExpression(%q) failed: %s", tt.source, err)
+		}
+
+		result, ok := expression.Evaluate()
+
+		if ok != tt.defined || (ok && !result.Equal(decimal.RequireFromString(tt.expected))) {
+			t.Errorf("Expression(%q).Evaluate should be %s (%t), got %s (%t)", tt.source, tt.expected, tt.defined, result, ok)
+		}
+	}
+}
+
+func TestCompileExpression_Errors(t *testing.T) {
+	for _, source := range []string{
+		"",
+		"avg_300 +",
+		"(avg_300",
+		"avg_300 avg_300",
+		"unknown_5 + 1",
+		"median(avg_300)",
+		"log(1, 2)",
+		"max()",
+		"zero ? 1",
+		"avg_300 # 2",
+		"1..2",
+	} {
+		if _, err := CompileExpression(source, bootstrapExpressionVariables()); err == nil {
+			t.Errorf("CompileExpression(%q) should fail", source)
+		}
+	}
+
+	variables := bootstrapExpressionVariables()
+	variables["wrong"] = ExpressionVariable{Feature: variables["since_max"].Feature, Index: 2}
+
+	if _, err := CompileExpression("wrong", variables); err == nil {
+		t.Errorf("CompileExpression should fail for variable out of values of feature")
+	}
+}
+
+func TestExpressionFeature_Update(t *testing.T) {
+	max, avg := (&MaxFeature{}).New(30), (&AvgFeature{}).New(30, nil)
+	variables := ExpressionVariables{"max_30": {Feature: max}, "avg_30": {Feature: avg}}
+	expression, err := CompileExpression("avg_30 != 0 ? max_30 / avg_30 : 0", variables)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := (&ExpressionFeature{}).New(expression)
+
+	if dependencies := f.GetDependencies(); len(dependencies) != 2 || dependencies[0] != avg || dependencies[1] != max {
+		t.Errorf("ExpressionFeature.GetDependencies should be avg and max, got %v", dependencies)
+	}
+
+	max.LastValue = decimal.NewFromInt(3)
+	f.Update(5, nil)
+
+	// Undefined avg is guarded by condition
+	if !f.GetValue().IsZero() {
+		t.Errorf("ExpressionFeature.Update should be 0, got %s", f.GetValue())
+	}
+
+	avg.LastValue = decimal.NewFromInt(2)
+	f.Update(10, nil)
+
+	if !f.GetValue().Equal(decimal.RequireFromString("1.5")) {
+		t.Errorf("ExpressionFeature.Update should be 1.5, got %s", f.GetValue())
+	}
+}
@@ -0,0 +1,164 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/gammazero/deque"
+	"github.com/shopspring/decimal"
+)
+
+// TickRateFeature ticks per second in window, it is TradeCountFeature divided by window size,
+// idle window has no ticks, so its rate is 0
+type TickRateFeature struct {
+	BasicRunningFeature
+}
+
+func (f *TickRateFeature) New(WindowSeconds uint64, dataStorage storage.InputDataStorage) *TickRateFeature {
+	f.DataStorage = dataStorage
+	f.LastValue = decimal.NewFromInt(0)
+	f.NoCarry = true
+	f.RunningFeature = f
+	f.WindowSeconds = WindowSeconds
+	return f
+}
+
+func (f *TickRateFeature) InvalidateData(data *dfedata.InputData) {
+	f.LastAmount -= 1
+	f.calculateValue()
+}
+
+func (f *TickRateFeature) CalculateData(data *dfedata.InputData) {
+	f.LastAmount += 1
+	f.calculateValue()
+}
+
+func (f *TickRateFeature) calculateValue() {
+	if f.WindowSeconds == 0 {
+		f.LastValue = decimal.NewFromInt(int64(f.LastAmount))
+	} else {
+		f.LastValue = decimal.NewFromInt(int64(f.LastAmount)).Div(decimal.NewFromInt(int64(f.WindowSeconds)))
+	}
+}
+
+type interArrivalGap struct {
+	// from data which starts the gap, gap is evicted with it
+	from *dfedata.InputData
+	seconds uint64
+}
+
+// InterArrivalFeature mean and max gap in seconds between consecutive ticks in window, values are [mean, max].
+// Every tick opens a gap which is closed by the next one, gaps are evicted with ticks which opened them,
+// eviction goes from the oldest, so gaps are a queue with running sum and monotonic deque for max, like in MaxFeature
+type InterArrivalFeature struct {
+	last *dfedata.InputData
+	gaps deque.Deque
+	maxGaps deque.Deque
+	sum uint64
+
+	LastValues []decimal.Decimal
+
+	BasicRunningFeature
+}
+
+func (f *InterArrivalFeature) New(WindowSeconds uint64, dataStorage storage.InputDataStorage) *InterArrivalFeature {
+	f.DataStorage = dataStorage
+	f.LastValue = decimal.NewFromInt(0)
+	f.LastValues = []decimal.Decimal{decimal.NewFromInt(0), decimal.NewFromInt(0)}
+	f.RunningFeature = f
+	f.WindowSeconds = WindowSeconds
+	return f
+}
+
+func (f *InterArrivalFeature) InvalidateData(data *dfedata.InputData) {
+	if f.gaps.Len() > 0 && f.gaps.Front().(interArrivalGap).from == data {
+		f.sum -= f.gaps.PopFront().(interArrivalGap).seconds
+	}
+
+	if f.maxGaps.Len() > 0 && f.maxGaps.Front().(interArrivalGap).from == data {
+		f.maxGaps.PopFront()
+	}
+
+	f.LastAmount -= 1
+	f.calculateValues()
+}
+
+func (f *InterArrivalFeature) CalculateData(data *dfedata.InputData) {
+	// Window was reset, while only carried data was there, gap from it is not in window
+	if f.LastAmount == 0 {
+		f.gaps.Clear()
+		f.maxGaps.Clear()
+		f.sum = 0
+		f.last = nil
+	}
+
+	if f.last != nil {
+		gap := interArrivalGap{from: f.last}
+
+		if data.Timestamp > f.last.Timestamp {
+			gap.seconds = data.Timestamp - f.last.Timestamp
+		}
+
+		f.gaps.PushBack(gap)
+		f.sum += gap.seconds
+
+		for f.maxGaps.Len() > 0 && f.maxGaps.Back().(interArrivalGap).seconds <= gap.seconds {
+			f.maxGaps.PopBack()
+		}
+
+		f.maxGaps.PushBack(gap)
+	}
+
+	f.last = data
+	f.LastAmount += 1
+	f.calculateValues()
+}
+
+func (f *InterArrivalFeature) calculateValues() {
+	if f.gaps.Len() == 0 {
+		f.LastValues[0] = decimal.NewFromInt(0)
+		f.LastValues[1] = decimal.NewFromInt(0)
+	} else {
+		f.LastValues[0] = decimal.NewFromInt(int64(f.sum)).Div(decimal.NewFromInt(int64(f.gaps.Len())))
+		f.LastValues[1] = decimal.NewFromInt(int64(f.maxGaps.Front().(interArrivalGap).seconds))
+	}
+
+	f.LastValue = f.LastValues[0]
+}
+
+// GetValues mean and max gap
+func (f *InterArrivalFeature) GetValues() []decimal.Decimal {
+	return f.LastValues
+}
+
+// TimeSinceLastTickFeature seconds from the newest tick in window to TimeCurrent, it grows while there are no data,
+// window without ticks gives window size, since the last tick is at least that old, zero before the first tick
+type TimeSinceLastTickFeature struct {
+	last *dfedata.InputData
+
+	BasicFeature
+}
+
+func (f *TimeSinceLastTickFeature) New(WindowSeconds uint64) *TimeSinceLastTickFeature {
+	f.LastValue = decimal.NewFromInt(0)
+	f.WindowSeconds = WindowSeconds
+	return f
+}
+
+func (f *TimeSinceLastTickFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	for _, log := range data {
+		if f.last == nil || log.Timestamp >= f.last.Timestamp {
+			f.last = log
+		}
+	}
+
+	switch {
+	case f.last == nil || TimeCurrent <= f.last.Timestamp:
+		f.LastValue = decimal.NewFromInt(0)
+	case !f.last.IsInWindow(TimeCurrent, f.WindowSeconds):
+		f.LastValue = decimal.NewFromInt(int64(f.WindowSeconds))
+	default:
+		f.LastValue = decimal.NewFromInt(int64(TimeCurrent - f.last.Timestamp))
+	}
+
+	f.OnUpdated(TimeCurrent, data)
+}
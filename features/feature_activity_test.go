@@ -0,0 +1,174 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/shopspring/decimal"
+	"math"
+	"testing"
+)
+
+func TestActivityFeatures_Update(t *testing.T) {
+	data := bootstrapRandomWalk(10, 1, 1800)
+
+	for _, WindowSeconds := range []uint64{5, 30, 300} {
+		rate := (&TickRateFeature{}).New(WindowSeconds, &storage.LinkedListDataStorage{})
+		interArrival := (&InterArrivalFeature{}).New(WindowSeconds, &storage.LinkedListDataStorage{})
+
+		replayTicks([]Feature{rate, interArrival}, data, 1800, func(TimeCurrent uint64, seen []*dfedata.InputData) {
+			var window []uint64
+
+			for _, log := range seen {
+				if log.IsInWindow(TimeCurrent, WindowSeconds) {
+					window = append(window, log.Timestamp)
+				}
+			}
+
+			expectedRate := float64(len(window)) / float64(WindowSeconds)
+
+			if math.Abs(rate.GetValue().InexactFloat64() - expectedRate) > 1e-9 || rate.GetAmount() != uint64(len(window)) {
+				t.Fatalf("TickRateFeature.Update(%d, %d) should be %g of %d, got %s of %d",
+					WindowSeconds, TimeCurrent, expectedRate, len(window), rate.GetValue(), rate.GetAmount())
+			}
+
+			// Empty window carries the last data for InterArrivalFeature, it is covered by the carry test
+			if len(window) == 0 {
+				return
+			}
+
+			sum, max := uint64(0), uint64(0)
+
+			for i := 1; i < len(window); i++ {
+				gap := window[i] - window[i-1]
+				sum += gap
+
+				if gap > max {
+					max = gap
+				}
+			}
+
+			mean := 0.0
+
+			if len(window) > 1 {
+				mean = float64(sum) / float64(len(window) - 1)
+			}
+
+			values := interArrival.GetValues()
+
+			if math.Abs(values[0].InexactFloat64() - mean) > 1e-9 || values[1].IntPart() != int64(max) {
+				t.Fatalf("InterArrivalFeature.Update(%d, %d) should be [%g %d], got %v", WindowSeconds, TimeCurrent, mean, max, values)
+			}
+		})
+	}
+}
+
+func TestInterArrivalFeature_Update_Carry(t *testing.T) {
+	f := (&InterArrivalFeature{}).New(10, &storage.LinkedListDataStorage{})
+	since := (&TimeSinceLastTickFeature{}).New(10)
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expected []int64
+		since int64
+	}{
+		{5, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(1), Timestamp: 1},
+			{DecimalCost: decimal.NewFromInt(1), Timestamp: 4},
+			{DecimalCost: decimal.NewFromInt(1), Timestamp: 5},
+		}, []int64 { 2, 3 }, 0},
+		{10, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(1), Timestamp: 7},
+		}, []int64 { 2, 3 }, 3},
+		// Gaps opened by 1 and 4 are evicted with them
+		{15, []*dfedata.InputData {}, []int64 { 2, 2 }, 8},
+		// Only the last tick is carried, it has no gaps, window has no ticks, so the last one is at least window old
+		{40, []*dfedata.InputData {}, []int64 { 0, 0 }, 10},
+		// Gap from carried tick is not in window
+		{45, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(1), Timestamp: 41},
+			{DecimalCost: decimal.NewFromInt(1), Timestamp: 45},
+		}, []int64 { 4, 4 }, 0},
+	}
+
+	for _, tick := range ticks {
+		f.Update(tick.TimeCurrent, tick.input)
+		since.Update(tick.TimeCurrent, tick.input)
+
+		for i := range tick.expected {
+			if !f.GetValues()[i].Equal(decimal.NewFromInt(tick.expected[i])) {
+				t.Fatalf("InterArrivalFeature.Update(%d) should be %v, got %v", tick.TimeCurrent, tick.expected, f.GetValues())
+			}
+		}
+
+		if !since.GetValue().Equal(decimal.NewFromInt(tick.since)) {
+			t.Errorf("TimeSinceLastTickFeature.Update(%d) should be %d, got %s", tick.TimeCurrent, tick.since, since.GetValue())
+		}
+	}
+}
+
+// Window idle for longer than itself has no ticks, that is a signal too, so nothing is carried
+func TestTickRateFeature_Update_Idle(t *testing.T) {
+	rate, count := (&TickRateFeature{}).New(60, &storage.LinkedListDataStorage{}), (&TradeCountFeature{}).New(60, &storage.LinkedListDataStorage{})
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expectedRate decimal.Decimal
+		expectedCount int64
+	}{
+		{60, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(1), Timestamp: 30},
+			{DecimalCost: decimal.NewFromInt(1), Timestamp: 60},
+		}, decimal.RequireFromString("0.0333333333333333"), 2},
+		// Tick at 30 is on the window edge
+		{90, []*dfedata.InputData {}, decimal.RequireFromString("0.0333333333333333"), 2},
+		{91, []*dfedata.InputData {}, decimal.RequireFromString("0.0166666666666667"), 1},
+		{660, []*dfedata.InputData {}, decimal.NewFromInt(0), 0},
+		{665, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(1), Timestamp: 665},
+		}, decimal.RequireFromString("0.0166666666666667"), 1},
+	}
+
+	for _, tick := range ticks {
+		rate.Update(tick.TimeCurrent, tick.input)
+		count.Update(tick.TimeCurrent, tick.input)
+
+		if !rate.GetValue().Equal(tick.expectedRate) || !count.GetValue().Equal(decimal.NewFromInt(tick.expectedCount)) {
+			t.Errorf("TickRateFeature.Update(%d) should be %s and count %d, got %s and %s",
+				tick.TimeCurrent, tick.expectedRate, tick.expectedCount, rate.GetValue(), count.GetValue())
+		}
+	}
+}
+
+func TestTimeSinceLastTickFeature_Update(t *testing.T) {
+	f := (&TimeSinceLastTickFeature{}).New(10)
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expected int64
+	}{
+		// Nothing came yet
+		{3, []*dfedata.InputData {}, 0},
+		{5, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(1), Timestamp: 5},
+			{DecimalCost: decimal.NewFromInt(2), Timestamp: 5},
+		}, 0},
+		{12, []*dfedata.InputData {}, 7},
+		// Tick at 5 is on the window edge
+		{15, []*dfedata.InputData {}, 10},
+		{100, []*dfedata.InputData {}, 10},
+		{101, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(1), Timestamp: 98},
+		}, 3},
+	}
+
+	for _, tick := range ticks {
+		f.Update(tick.TimeCurrent, tick.input)
+
+		if !f.GetValue().Equal(decimal.NewFromInt(tick.expected)) {
+			t.Errorf("TimeSinceLastTickFeature.Update(%d) should be %d, got %s", tick.TimeCurrent, tick.expected, f.GetValue())
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/gammazero/deque"
+	"github.com/shopspring/decimal"
+	"math"
+)
+
+// madScale makes MAD of normal distribution equal to its standard deviation
+var madScale = decimal.RequireFromString("0.6745")
+
+// flagOf boolean as value of vector
+func flagOf(flag bool) decimal.Decimal {
+	if flag {
+		return decimal.NewFromInt(1)
+	}
+
+	return decimal.NewFromInt(0)
+}
+
+// RobustZScoreFeature flags outlier ticks by robust z-score 0.6745 * (price - median) / MAD of window,
+// values are [score, flag], score is of the most deviating data of this tick, flag is 1 when its |score| exceeds Threshold.
+// Ticks without new data have no outliers. Median and MAD are exact, they are taken from PercentileFeature,
+// score is 0 when MAD is 0, since more than half of window has the same price
+type RobustZScoreFeature struct {
+	Threshold decimal.Decimal
+	LastValues []decimal.Decimal
+
+	PercentileFeature
+}
+
+func (f *RobustZScoreFeature) New(WindowSeconds uint64, dataStorage storage.InputDataStorage, Threshold decimal.Decimal) *RobustZScoreFeature {
+	f.PercentileFeature.New(WindowSeconds, dataStorage, 0.5)
+	f.Threshold = Threshold
+	f.LastValues = []decimal.Decimal{decimal.NewFromInt(0), decimal.NewFromInt(0)}
+	return f
+}
+
+func (f *RobustZScoreFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	f.updateWindow(TimeCurrent, data)
+
+	score := decimal.Zero
+	median, mad := f.GetPercentile(0.5), f.GetMedianAbsoluteDeviation()
+
+	if mad.IsPositive() {
+		for _, log := range data {
+			if !log.IsInWindow(TimeCurrent, f.WindowSeconds) {
+				continue
+			}
+
+			if logScore := madScale.Mul(log.DecimalCost.Sub(median)).Div(mad); logScore.Abs().GreaterThan(score.Abs()) {
+				score = logScore
+			}
+		}
+	}
+
+	f.LastValues[0] = score
+	f.LastValues[1] = flagOf(score.Abs().GreaterThan(f.Threshold))
+	f.LastValue = score
+	f.OnUpdated(TimeCurrent, data)
+}
+
+// GetValues score and flag
+func (f *RobustZScoreFeature) GetValues() []decimal.Decimal {
+	return f.LastValues
+}
+
+// noReturn return of the first data
+const noReturn = math.MaxFloat64
+
+// cusumWarmUp amount of returns in window before they are standardized, deviation of fewer is too noisy
+const cusumWarmUp = 30
+
+// CUSUMFeature two-sided CUSUM of log returns standardized by mean and standard deviation of returns in window,
+// values are [score, flag]. Every return z adds z - Drift to upper sum and -z - Drift to lower sum, sums don't go below 0,
+// when one of them exceeds Threshold a change of regime is detected, flag is 1 for that tick and sums start over.
+// Score is upper sum if it is larger, otherwise lower sum negated, Drift and Threshold are in standard deviations
+type CUSUMFeature struct {
+	Drift float64
+	Threshold float64
+
+	// returns of data in window, eviction goes from the oldest data like in histogramCounts
+	returns deque.Deque
+	lastPrice decimal.Decimal
+	moments
+
+	upper float64
+	lower float64
+	detected bool
+	LastValues []decimal.Decimal
+
+	BasicRunningFeature
+}
+
+func (f *CUSUMFeature) New(WindowSeconds uint64, dataStorage storage.InputDataStorage, Drift float64, Threshold float64) *CUSUMFeature {
+	f.Drift = Drift
+	f.Threshold = Threshold
+	f.reset()
+	f.LastValue = decimal.NewFromInt(0)
+	f.LastValues = []decimal.Decimal{decimal.NewFromInt(0), decimal.NewFromInt(0)}
+	f.DataStorage = dataStorage
+	f.RunningFeature = f
+	f.WindowSeconds = WindowSeconds
+	return f
+}
+
+func (f *CUSUMFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	f.detected = false
+	f.updateWindow(TimeCurrent, data)
+
+	if f.upper >= f.lower {
+		f.LastValues[0] = decimal.NewFromFloat(f.upper)
+	} else {
+		f.LastValues[0] = decimal.NewFromFloat(-f.lower)
+	}
+
+	f.LastValues[1] = flagOf(f.detected)
+	f.LastValue = f.LastValues[0]
+	f.OnUpdated(TimeCurrent, data)
+}
+
+func (f *CUSUMFeature) InvalidateData(data *dfedata.InputData) {
+	if f.returns.Len() > 0 {
+		if r := f.returns.PopFront().(float64); r != noReturn {
+			f.remove(decimal.NewFromFloat(r))
+		}
+	}
+
+	f.LastAmount -= 1
+}
+
+func (f *CUSUMFeature) CalculateData(data *dfedata.InputData) {
+	// Window was reset, while only carried data was there, sums go on, they are not about window
+	if f.LastAmount == 0 {
+		f.returns.Clear()
+		f.reset()
+	}
+
+	r := noReturn
+
+	if f.lastPrice.IsPositive() && data.DecimalCost.IsPositive() {
+		// In basis points, returns are tiny for precision of decimal division, while z-score doesn't depend on scale
+		r = math.Log(data.DecimalCost.Div(f.lastPrice).InexactFloat64()) * 10000
+		f.accumulate(r)
+		f.add(decimal.NewFromFloat(r))
+	}
+
+	if data.DecimalCost.IsPositive() {
+		f.lastPrice = data.DecimalCost
+	}
+
+	f.returns.PushBack(r)
+	f.LastAmount += 1
+}
+
+// accumulate return is standardized by returns before it, so a jump is not hidden by itself
+func (f *CUSUMFeature) accumulate(r float64) {
+	stdDev := f.sampleStdDev().InexactFloat64()
+
+	if f.n < cusumWarmUp || stdDev <= 0 {
+		return
+	}
+
+	z := (r - f.mean.InexactFloat64()) / stdDev
+	f.upper = math.Max(0, f.upper + z - f.Drift)
+	f.lower = math.Max(0, f.lower - z - f.Drift)
+
+	if f.upper > f.Threshold || f.lower > f.Threshold {
+		f.detected = true
+		f.upper, f.lower = 0, 0
+	}
+}
+
+// GetValues score and flag
+func (f *CUSUMFeature) GetValues() []decimal.Decimal {
+	return f.LastValues
+}
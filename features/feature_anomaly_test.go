@@ -0,0 +1,188 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/shopspring/decimal"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func bruteForceMedian(sorted []float64) float64 {
+	if len(sorted) % 2 == 1 {
+		return sorted[len(sorted) / 2]
+	}
+
+	return (sorted[len(sorted) / 2 - 1] + sorted[len(sorted) / 2]) / 2
+}
+
+func TestPercentileFeature_GetMedianAbsoluteDeviation(t *testing.T) {
+	data := bootstrapRandomWalk(17, 1, 1800)
+
+	for _, WindowSeconds := range []uint64{5, 30, 300} {
+		f := (&PercentileFeature{}).New(WindowSeconds, &storage.LinkedListDataStorage{}, 0.5)
+
+		replayTicks([]Feature{f}, data, 1800, func(TimeCurrent uint64, seen []*dfedata.InputData) {
+			values := bruteForceWindow(seen, TimeCurrent, WindowSeconds)
+
+			if len(values) == 0 {
+				return
+			}
+
+			median := bruteForceMedian(values)
+			deviations := make([]float64, len(values))
+
+			for i, value := range values {
+				deviations[i] = math.Abs(value - median)
+			}
+
+			sort.Float64s(deviations)
+
+			if expected := bruteForceMedian(deviations); math.Abs(f.GetMedianAbsoluteDeviation().InexactFloat64() - expected) > 1e-9 {
+				t.Fatalf("PercentileFeature.GetMedianAbsoluteDeviation(%d, %d) should be %g, got %s",
+					WindowSeconds, TimeCurrent, expected, f.GetMedianAbsoluteDeviation())
+			}
+		})
+	}
+}
+
+func TestRobustZScoreFeature_Update(t *testing.T) {
+	f := (&RobustZScoreFeature{}).New(30, &storage.LinkedListDataStorage{}, decimal.RequireFromString("3.5"))
+	var data []*dfedata.InputData
+
+	for i, price := range []int64{100, 101, 99, 102, 98, 100, 101, 99} {
+		data = append(data, &dfedata.InputData{DecimalCost: decimal.NewFromInt(price), Timestamp: uint64(i + 1)})
+	}
+
+	price := func(value int64, timestamp uint64) *dfedata.InputData {
+		return &dfedata.InputData{DecimalCost: decimal.NewFromInt(value), Timestamp: timestamp}
+	}
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expected []string
+	}{
+		// Empty window without data before it
+		{0, nil, []string{"0", "0"}},
+		// Median is 100, MAD is 1, so nothing deviates more than 0.6745 * 2, 102 is the first of the most deviating
+		{10, data, []string{"1.349", "0"}},
+		// Median is still 100, MAD is 1, the outlier is 0.6745 * 20 from it
+		{15, []*dfedata.InputData{price(120, 12)}, []string{"13.49", "1"}},
+		// No new data, no outliers
+		{20, nil, []string{"0", "0"}},
+		// Data with equal timestamps are all scored, median is 100 and MAD is 1 of 11 prices, 95 is 0.6745 * -5 from it
+		{21, []*dfedata.InputData{price(100, 21), price(95, 21)}, []string{"-3.3725", "0"}},
+		// Empty window carries 95 only
+		{60, nil, []string{"0", "0"}},
+		// Carried data is dropped, single price has MAD 0
+		{61, []*dfedata.InputData{price(200, 61)}, []string{"0", "0"}},
+	}
+
+	for _, tick := range ticks {
+		f.Update(tick.TimeCurrent, tick.input)
+
+		for i := range tick.expected {
+			if !f.GetValues()[i].Equal(decimal.RequireFromString(tick.expected[i])) {
+				t.Fatalf("RobustZScoreFeature.Update(%d) should be %v, got %v", tick.TimeCurrent, tick.expected, f.GetValues())
+			}
+		}
+	}
+
+	if f.GetAmount() != 1 {
+		t.Errorf("RobustZScoreFeature.Update should drop carried data, got %d data", f.GetAmount())
+	}
+}
+
+// Chained features must get the score of this tick, not the median
+func TestRobustZScoreFeature_Update_Chain(t *testing.T) {
+	f := (&RobustZScoreFeature{}).New(30, &storage.LinkedListDataStorage{}, decimal.RequireFromString("3.5"))
+	var chained Feature = &chainedValues{values: make(chan decimal.Decimal, 1)}
+	f.Chain(&chained)
+
+	for i, price := range []int64{100, 101, 99, 102, 98, 120} {
+		f.Update(uint64(i + 1), []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(price), Timestamp: uint64(i + 1)}})
+
+		if value := <-chained.(*chainedValues).values; !value.Equal(f.GetValue()) {
+			t.Fatalf("RobustZScoreFeature.Update(%d) should send %s to chained features, got %s", i + 1, f.GetValue(), value)
+		}
+	}
+}
+
+func TestCUSUMFeature_Update(t *testing.T) {
+	random := rand.New(rand.NewSource(18))
+	f := (&CUSUMFeature{}).New(300, &storage.LinkedListDataStorage{}, 0.5, 12)
+	price := int64(6537200)
+	var detections []uint64
+
+	for TimeCurrent := uint64(5); TimeCurrent <= 1200; TimeCurrent += 5 {
+		var batch []*dfedata.InputData
+
+		for second := TimeCurrent - 4; second <= TimeCurrent; second++ {
+			// Noise around the same level, then the price starts to climb
+			step := random.Int63n(101) - 50
+
+			if TimeCurrent > 900 {
+				step += 40
+			}
+
+			price += step
+			batch = append(batch, &dfedata.InputData{DecimalCost: decimal.New(price, -2), Timestamp: second})
+		}
+
+		f.Update(TimeCurrent, batch)
+
+		if f.GetValues()[1].Equal(decimal.NewFromInt(1)) {
+			detections = append(detections, TimeCurrent)
+		}
+	}
+
+	if len(detections) == 0 || detections[0] <= 900 || detections[0] > 960 {
+		t.Errorf("CUSUMFeature.Update should detect change soon after 900, detected at %v", detections)
+	}
+}
+
+func TestCUSUMFeature_Update_Table(t *testing.T) {
+	f := (&CUSUMFeature{}).New(300, &storage.LinkedListDataStorage{}, 0, 0.3)
+	price := func(value int64, timestamp uint64) *dfedata.InputData {
+		return &dfedata.InputData{DecimalCost: decimal.NewFromInt(value), Timestamp: timestamp}
+	}
+
+	var flat []*dfedata.InputData
+
+	for second := uint64(1); second <= cusumWarmUp + 1; second++ {
+		flat = append(flat, price(100, second))
+	}
+
+	// Jump J follows 30 returns of 0, so the next return of 0 after k returns of 0 in all has mean J / (k + 1)
+	// and deviation J / √(k + 1), its z-score is -1 / √(k + 1)
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		score float64
+		flag int64
+	}{
+		// Empty window without data before it
+		{0, nil, 0, 0},
+		// Returns of 0 have no deviation
+		{31, flat, 0, 0},
+		// Jump is standardized by returns before it, which have no deviation
+		{32, []*dfedata.InputData{price(200, 32)}, 0, 0},
+		{33, []*dfedata.InputData{price(200, 33)}, -1 / math.Sqrt(31), 0},
+		// Data with equal timestamps are accumulated in order, lower sum 1 / √31 + 1 / √32 exceeds 0.3,
+		// sums start over from the second one
+		{34, []*dfedata.InputData{price(200, 34), price(200, 34)}, -1 / math.Sqrt(33), 1},
+		// Flag is for the tick of detection only
+		{35, nil, -1 / math.Sqrt(33), 0},
+	}
+
+	for _, tick := range ticks {
+		f.Update(tick.TimeCurrent, tick.input)
+
+		if math.Abs(f.GetValues()[0].InexactFloat64() - tick.score) > 1e-9 || !f.GetValues()[1].Equal(decimal.NewFromInt(tick.flag)) {
+			t.Errorf("CUSUMFeature.Update(%d) should be [%g %d], got %v", tick.TimeCurrent, tick.score, tick.flag, f.GetValues())
+		}
+	}
+}
@@ -78,3 +78,35 @@ func TestAvgFeature_Update(t *testing.T) {
 		}
 	}
 }
+
+// Storage is trimmed only when data are appended, so data invalidated on a tick without new data
+// are still stored on the next one and must not be invalidated again
+func TestAvgFeature_Update_EmptyTicks(t *testing.T) {
+	f := bootstrapAvgFeature(10)
+	f.Update(4, []*dfedata.InputData{
+		{DecimalCost: decimal.NewFromInt(10), Timestamp: 1},
+		{DecimalCost: decimal.NewFromInt(20), Timestamp: 2},
+		{DecimalCost: decimal.NewFromInt(30), Timestamp: 3},
+		{DecimalCost: decimal.NewFromInt(40), Timestamp: 4},
+	})
+
+	var tests = []struct {
+		TimeCurrent uint64
+		expected decimal.Decimal
+		amount uint64
+	}{
+		{12, decimal.NewFromInt(30), 3},
+		{13, decimal.NewFromInt(35), 2},
+		{14, decimal.NewFromInt(40), 1},
+		// The last data is preserved
+		{30, decimal.NewFromInt(40), 1},
+	}
+
+	for _, tt := range tests {
+		f.Update(tt.TimeCurrent, nil)
+
+		if !f.GetValue().Equal(tt.expected) || f.GetAmount() != tt.amount {
+			t.Errorf("AvgFeature.Update(%d) should be %s of %d, got %s of %d", tt.TimeCurrent, tt.expected, tt.amount, f.GetValue(), f.GetAmount())
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"github.com/shopspring/decimal"
+)
+
+// Bar is open, high, low, close and count of data between OpenTimestamp and CloseTimestamp
+type Bar struct {
+	Open decimal.Decimal
+	High decimal.Decimal
+	Low decimal.Decimal
+	Close decimal.Decimal
+	Count uint64
+
+	OpenTimestamp uint64
+	CloseTimestamp uint64
+}
+
+func barOf(data *dfedata.InputData) *Bar {
+	return &Bar{
+		Open: data.DecimalCost, High: data.DecimalCost, Low: data.DecimalCost, Close: data.DecimalCost, Count: 1,
+		OpenTimestamp: data.Timestamp, CloseTimestamp: data.Timestamp,
+	}
+}
+
+// merge merges newer bar into b in place, bars are a monoid: open of older, close of newer, extremes of both
+func (b *Bar) merge(newer *Bar) {
+	b.Close = newer.Close
+	b.Count += newer.Count
+	b.CloseTimestamp = newer.CloseTimestamp
+
+	if newer.High.GreaterThan(b.High) {
+		b.High = newer.High
+	}
+
+	if newer.Low.LessThan(b.Low) {
+		b.Low = newer.Low
+	}
+}
+
+// add merges data into b in place, late data don't move CloseTimestamp back
+func (b *Bar) add(data *dfedata.InputData) {
+	b.Close = data.DecimalCost
+	b.Count += 1
+
+	if data.Timestamp > b.CloseTimestamp {
+		b.CloseTimestamp = data.Timestamp
+	}
+
+	if data.DecimalCost.GreaterThan(b.High) {
+		b.High = data.DecimalCost
+	}
+
+	if data.DecimalCost.LessThan(b.Low) {
+		b.Low = data.DecimalCost
+	}
+}
+
+// mergeBars combine of slidingAggregate, it must not modify bars, so result is a new one
+func mergeBars(older interface{}, newer interface{}) interface{} {
+	result := *older.(*Bar)
+	result.merge(newer.(*Bar))
+	return &result
+}
+
+// paneBars merges data into bar of the current pane of PaneSeconds in place, pane ending at E holds data of (E - PaneSeconds, E],
+// like ticks of scheduler, so with PaneSeconds equal to tick every tick closes one pane
+type paneBars struct {
+	PaneSeconds uint64
+
+	current *Bar
+	currentEnd uint64
+}
+
+// end end of pane of timestamp
+func (p *paneBars) end(timestamp uint64) uint64 {
+	return (timestamp + p.PaneSeconds - 1) / p.PaneSeconds * p.PaneSeconds
+}
+
+func (p *paneBars) add(data *dfedata.InputData, onClosed func(bar *Bar, end uint64)) {
+	end := p.end(data.Timestamp)
+
+	if p.current != nil && end > p.currentEnd {
+		p.close(end - 1, onClosed)
+	}
+
+	if p.current == nil {
+		p.current, p.currentEnd = barOf(data), end
+		return
+	}
+
+	p.current.add(data)
+}
+
+// close closes current pane if it has ended by TimeCurrent, closed bar belongs to onClosed
+func (p *paneBars) close(TimeCurrent uint64, onClosed func(bar *Bar, end uint64)) {
+	if p.current == nil || p.currentEnd > TimeCurrent {
+		return
+	}
+
+	onClosed(p.current, p.currentEnd)
+	p.current = nil
+}
+
+// barWindow bars of panes in window, data are merged into bar of the current pane (see paneBars) and closed panes
+// are kept in slidingAggregate, so there is one bar per pane, not per data, and window bar is their aggregate
+// merged with the current pane
+type barWindow struct {
+	paneBars
+	panes slidingAggregate
+}
+
+func (w *barWindow) reset(PaneSeconds uint64) {
+	w.PaneSeconds = PaneSeconds
+	w.panes.combine = mergeBars
+}
+
+func (w *barWindow) push(bar *Bar, end uint64) {
+	w.panes.Push(bar)
+}
+
+// Len amount of panes including the current one
+func (w *barWindow) Len() int {
+	if w.current != nil {
+		return w.panes.Len() + 1
+	}
+
+	return w.panes.Len()
+}
+
+// Front the oldest pane
+func (w *barWindow) Front() *Bar {
+	if w.panes.Len() > 0 {
+		return w.panes.Front().(*Bar)
+	}
+
+	return w.current
+}
+
+// Pop removes the oldest pane
+func (w *barWindow) Pop() {
+	if w.panes.Len() > 0 {
+		w.panes.Pop()
+		return
+	}
+
+	w.current = nil
+}
+
+// carry replaces the only pane with its last data, by TZ it is the only value of window without data
+func (w *barWindow) carry() {
+	last := w.Front()
+
+	if last.Count == 1 {
+		return
+	}
+
+	w.Pop()
+	w.current = &Bar{
+		Open: last.Close, High: last.Close, Low: last.Close, Close: last.Close, Count: 1,
+		OpenTimestamp: last.CloseTimestamp, CloseTimestamp: last.CloseTimestamp,
+	}
+	w.currentEnd = w.end(last.CloseTimestamp)
+}
+
+// bar merges window bar into result, false if there are no panes
+func (w *barWindow) bar(result *Bar) bool {
+	aggregate := w.panes.Aggregate()
+
+	switch {
+	case aggregate != nil:
+		*result = *aggregate.(*Bar)
+	case w.current != nil:
+		*result = *w.current
+		return true
+	default:
+		return false
+	}
+
+	if w.current != nil {
+		result.merge(w.current)
+	}
+
+	return true
+}
+
+// BarFeature calculates OHLC bar and count of data in window, values are [open, high, low, close, count].
+// Data are merged into bars of seconds in barWindow, so window bar is their merge and eviction is just Pop.
+// If there are no data in window the last data is carried and considered the only one, like in the rest of features
+type BarFeature struct {
+	bars barWindow
+
+	LastBar Bar
+	LastValues []decimal.Decimal
+
+	BasicFeature
+}
+
+func (f *BarFeature) New(WindowSeconds uint64) *BarFeature {
+	f.WindowSeconds = WindowSeconds
+	f.bars.reset(1)
+	f.LastValue = decimal.NewFromInt(0)
+	f.LastValues = make([]decimal.Decimal, 5)
+	return f
+}
+
+func (f *BarFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	willAppend := dfedata.IsThereAreAnyDataToProcess(TimeCurrent, f.WindowSeconds, data)
+
+	// The last data is preserved until new data come, it is in the newest bar
+	for f.bars.Len() > 0 && (f.bars.Len() > 1 || willAppend) {
+		if f.bars.Front().CloseTimestamp + f.WindowSeconds >= TimeCurrent {
+			break
+		}
+
+		f.bars.Pop()
+	}
+
+	if f.bars.Len() == 1 && !willAppend && f.bars.Front().CloseTimestamp + f.WindowSeconds < TimeCurrent {
+		f.bars.carry()
+	}
+
+	for _, log := range data {
+		if !log.IsInWindow(TimeCurrent, f.WindowSeconds) {
+			continue
+		}
+
+		f.bars.add(log, f.bars.push)
+	}
+
+	if f.bars.bar(&f.LastBar) {
+		f.LastValues[0] = f.LastBar.Open
+		f.LastValues[1] = f.LastBar.High
+		f.LastValues[2] = f.LastBar.Low
+		f.LastValues[3] = f.LastBar.Close
+		f.LastValues[4] = decimal.NewFromInt(int64(f.LastBar.Count))
+		f.LastValue = f.LastBar.Close
+	}
+
+	f.OnUpdated(TimeCurrent, data)
+}
+
+// GetBar bar of the window after the last update
+func (f *BarFeature) GetBar() Bar {
+	return f.LastBar
+}
+
+// GetValues open, high, low, close, count
+func (f *BarFeature) GetValues() []decimal.Decimal {
+	return f.LastValues
+}
+
+func (f *BarFeature) GetAmount() uint64 {
+	return f.LastBar.Count
+}
@@ -0,0 +1,92 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"github.com/shopspring/decimal"
+	"testing"
+)
+
+// bruteForceBar bar of data in [TimeCurrent - WindowSeconds, TimeCurrent], data are sorted
+func bruteForceBar(data []*dfedata.InputData, TimeCurrent uint64, WindowSeconds uint64) (bar Bar, ok bool) {
+	for _, log := range data {
+		if !log.IsInWindow(TimeCurrent, WindowSeconds) || log.Timestamp > TimeCurrent {
+			continue
+		}
+
+		if !ok {
+			bar, ok = *barOf(log), true
+			continue
+		}
+
+		bar = *mergeBars(&bar, barOf(log)).(*Bar)
+	}
+
+	return
+}
+
+func TestBarFeature_Update(t *testing.T) {
+	data := bootstrapRandomWalk(5, 1, 1800)
+
+	for _, WindowSeconds := range []uint64{5, 30, 300} {
+		f := (&BarFeature{}).New(WindowSeconds)
+
+		replayTicks([]Feature{f}, data, 1800, func(TimeCurrent uint64, seen []*dfedata.InputData) {
+			expected, ok := bruteForceBar(seen, TimeCurrent, WindowSeconds)
+
+			if !ok {
+				return
+			}
+
+			bar := f.GetBar()
+
+			if !bar.Open.Equal(expected.Open) || !bar.High.Equal(expected.High) || !bar.Low.Equal(expected.Low) ||
+				!bar.Close.Equal(expected.Close) || bar.Count != expected.Count {
+				t.Fatalf("BarFeature_Update(%d, %d) should be %v, got %v", WindowSeconds, TimeCurrent, expected, bar)
+			}
+		})
+	}
+}
+
+func TestBarFeature_Update_Carry(t *testing.T) {
+	f := (&BarFeature{}).New(5)
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expected []int64
+	}{
+		// Empty window without data before it
+		{2, []*dfedata.InputData {}, []int64 { 0, 0, 0, 0, 0 }},
+		{5, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(3), Timestamp: 1},
+			{DecimalCost: decimal.NewFromInt(5), Timestamp: 2},
+			{DecimalCost: decimal.NewFromInt(1), Timestamp: 3},
+			{DecimalCost: decimal.NewFromInt(2), Timestamp: 5},
+		}, []int64 { 3, 5, 1, 2, 4 }},
+		// Open is the first data in window [5, 15]
+		{10, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(4), Timestamp: 8},
+		}, []int64 { 2, 4, 2, 4, 2 }},
+		// Window is empty, the last data is carried as the only one
+		{20, []*dfedata.InputData {}, []int64 { 4, 4, 4, 4, 1 }},
+		{25, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(6), Timestamp: 25},
+		}, []int64 { 6, 6, 6, 6, 1 }},
+		// Data of the same second share a bar, only the last of them is carried
+		{30, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(7), Timestamp: 28},
+			{DecimalCost: decimal.NewFromInt(3), Timestamp: 28},
+		}, []int64 { 6, 7, 3, 3, 3 }},
+		{40, []*dfedata.InputData {}, []int64 { 3, 3, 3, 3, 1 }},
+	}
+
+	for _, tick := range ticks {
+		f.Update(tick.TimeCurrent, tick.input)
+
+		for i, expected := range tick.expected {
+			if !f.GetValues()[i].Equal(decimal.NewFromInt(expected)) {
+				t.Fatalf("BarFeature_Update(%d) should be %v, got %v", tick.TimeCurrent, tick.expected, f.GetValues())
+			}
+		}
+	}
+}
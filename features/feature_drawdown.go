@@ -0,0 +1,217 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"github.com/gammazero/deque"
+	"github.com/shopspring/decimal"
+)
+
+// swingPrice price of window, seq numbers prices in order of arrival, so they are found in deque of window by it
+type swingPrice struct {
+	seq uint64
+	timestamp uint64
+	price decimal.Decimal
+}
+
+// swingGroup prices after end of the previous group up to its own end, end is suffix extreme of window,
+// so every price of group swings to it and start is the best price of group to swing from
+type swingGroup struct {
+	end *swingPrice
+	start decimal.Decimal
+	value decimal.Decimal
+}
+
+// swings the largest fall (or rise when rising) from a price to any later one in window with monotonic deques.
+// groups is the sliding window minimum deque (maximum for rises), every price between two of its prices falls
+// at most to the later one, so prices are grouped by it and only the highest price of group matters.
+// New price pops groups it is beyond and merges them, merged group falls at least as far as any of them,
+// so values keeps sliding maximum of values of groups like the same deque of BasicMinMaxFeature.
+// The first group is the only one losing prices on eviction, its start is maximum of its part of window,
+// which is another monotonic deque, front, prices are pushed there when the first group grows to them
+type swings struct {
+	rising bool
+
+	groups deque.Deque
+	values deque.Deque
+	front deque.Deque
+	// pushed seq of the next price to be pushed to front
+	pushed uint64
+}
+
+// beyond a is further than b in direction of swing, lower for falls and higher for rises
+func (s *swings) beyond(a decimal.Decimal, b decimal.Decimal) bool {
+	if s.rising {
+		return a.GreaterThan(b)
+	}
+
+	return a.LessThan(b)
+}
+
+// swing fraction of the price it starts from
+func (s *swings) swing(start decimal.Decimal, end decimal.Decimal) decimal.Decimal {
+	if !start.IsPositive() {
+		return decimal.NewFromInt(0)
+	}
+
+	return end.Sub(start).Abs().Div(start)
+}
+
+// add price must be already pushed to prices
+func (s *swings) add(price *swingPrice, prices *deque.Deque) {
+	group := &swingGroup{end: price, start: price.price}
+
+	for s.groups.Len() > 0 && !s.beyond(s.groups.Back().(*swingGroup).end.price, price.price) {
+		popped := s.groups.PopBack().(*swingGroup)
+
+		if s.beyond(group.start, popped.start) {
+			group.start = popped.start
+		}
+	}
+
+	group.value = s.swing(group.start, price.price)
+	s.groups.PushBack(group)
+
+	// The first group takes its start from front, values are only of the rest
+	if s.groups.Len() == 1 {
+		s.values.Clear()
+		s.grow(prices)
+		return
+	}
+
+	for s.values.Len() > 0 && !s.values.Back().(*swingGroup).value.GreaterThan(group.value) {
+		s.values.PopBack()
+	}
+
+	s.values.PushBack(group)
+}
+
+// evict drops everything before the oldest price left in prices
+func (s *swings) evict(prices *deque.Deque) {
+	for s.groups.Len() > 0 && (prices.Len() == 0 || s.groups.Front().(*swingGroup).end.seq < prices.Front().(*swingPrice).seq) {
+		s.groups.PopFront()
+
+		// The next group becomes the first one, it is the oldest in values if it is there
+		if s.groups.Len() > 0 && s.values.Len() > 0 && s.values.Front() == s.groups.Front() {
+			s.values.PopFront()
+		}
+	}
+
+	for s.front.Len() > 0 && (prices.Len() == 0 || s.front.Front().(*swingPrice).seq < prices.Front().(*swingPrice).seq) {
+		s.front.PopFront()
+	}
+
+	s.grow(prices)
+}
+
+// grow pushes prices of the first group to front, every price is pushed once
+func (s *swings) grow(prices *deque.Deque) {
+	if s.groups.Len() == 0 {
+		return
+	}
+
+	first := prices.Front().(*swingPrice).seq
+
+	if s.pushed < first {
+		s.pushed = first
+	}
+
+	for ; s.pushed <= s.groups.Front().(*swingGroup).end.seq; s.pushed++ {
+		price := prices.At(int(s.pushed - first)).(*swingPrice)
+
+		for s.front.Len() > 0 && !s.beyond(price.price, s.front.Back().(*swingPrice).price) {
+			s.front.PopBack()
+		}
+
+		s.front.PushBack(price)
+	}
+}
+
+// largest swing in window, 0 without prices
+func (s *swings) largest() decimal.Decimal {
+	if s.groups.Len() == 0 {
+		return decimal.NewFromInt(0)
+	}
+
+	value := s.swing(s.front.Front().(*swingPrice).price, s.groups.Front().(*swingGroup).end.price)
+
+	if s.values.Len() > 0 && s.values.Front().(*swingGroup).value.GreaterThan(value) {
+		value = s.values.Front().(*swingGroup).value
+	}
+
+	return value
+}
+
+// DrawdownFeature max drawdown, max run-up and current drawdown from high of window, values are
+// [max drawdown, max run-up, current drawdown], all are fractions of the price they are measured from.
+// Drawdown is a fall from a price to any later one, run-up is a rise, so they are not inverse of each other.
+// Like sliding window min and max of BasicMinMaxFeature they are kept with monotonic deques, see swings,
+// so every data is pushed and popped amortized O(1) times
+type DrawdownFeature struct {
+	prices deque.Deque
+	nextSeq uint64
+	falls swings
+	rises swings
+
+	LastValues []decimal.Decimal
+
+	BasicFeature
+}
+
+func (f *DrawdownFeature) New(WindowSeconds uint64) *DrawdownFeature {
+	f.WindowSeconds = WindowSeconds
+	f.rises.rising = true
+	f.LastValue = decimal.NewFromInt(0)
+	f.LastValues = []decimal.Decimal{decimal.NewFromInt(0), decimal.NewFromInt(0), decimal.NewFromInt(0)}
+	return f
+}
+
+func (f *DrawdownFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	willAppend := dfedata.IsThereAreAnyDataToProcess(TimeCurrent, f.WindowSeconds, data)
+	evicted := false
+
+	// The last data is preserved until new data come, like in BasicMinMaxFeature
+	for f.prices.Len() > 0 && (f.prices.Len() > 1 || willAppend) {
+		if f.prices.Front().(*swingPrice).timestamp + f.WindowSeconds >= TimeCurrent {
+			break
+		}
+
+		f.prices.PopFront()
+		evicted = true
+	}
+
+	if evicted {
+		f.falls.evict(&f.prices)
+		f.rises.evict(&f.prices)
+	}
+
+	for _, log := range data {
+		if !log.IsInWindow(TimeCurrent, f.WindowSeconds) {
+			continue
+		}
+
+		price := &swingPrice{seq: f.nextSeq, timestamp: log.Timestamp, price: log.DecimalCost}
+		f.nextSeq++
+		f.prices.PushBack(price)
+		f.falls.add(price, &f.prices)
+		f.rises.add(price, &f.prices)
+	}
+
+	if f.prices.Len() > 0 {
+		f.LastValues[0] = f.falls.largest()
+		f.LastValues[1] = f.rises.largest()
+		// The first group of rises ends with maximum of window
+		f.LastValues[2] = f.falls.swing(f.rises.groups.Front().(*swingGroup).end.price, f.prices.Back().(*swingPrice).price)
+		f.LastValue = f.LastValues[0]
+	}
+
+	f.OnUpdated(TimeCurrent, data)
+}
+
+// GetValues max drawdown, max run-up and current drawdown
+func (f *DrawdownFeature) GetValues() []decimal.Decimal {
+	return f.LastValues
+}
+
+func (f *DrawdownFeature) GetAmount() uint64 {
+	return uint64(f.prices.Len())
+}
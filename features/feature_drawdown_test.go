@@ -0,0 +1,114 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"github.com/shopspring/decimal"
+	"math"
+	"testing"
+)
+
+// bruteForceDrawdown checks every pair of prices in order
+func bruteForceDrawdown(prices []float64) (drawdown float64, runUp float64, current float64) {
+	high := prices[0]
+
+	for i := range prices {
+		for j := i + 1; j < len(prices); j++ {
+			drawdown = math.Max(drawdown, (prices[i] - prices[j]) / prices[i])
+			runUp = math.Max(runUp, (prices[j] - prices[i]) / prices[i])
+		}
+
+		high = math.Max(high, prices[i])
+	}
+
+	return drawdown, runUp, (high - prices[len(prices)-1]) / high
+}
+
+// bootstrapTrends price rises and falls for minutes in turn, so groups of swings are long and merge often
+func bootstrapTrends(to uint64) []*dfedata.InputData {
+	var result []*dfedata.InputData
+	price := int64(10000)
+
+	for second := uint64(1); second < to; second++ {
+		if second / 200 % 2 == 0 {
+			price += int64(second % 7) - 1
+		} else {
+			price -= int64(second % 5) - 1
+		}
+
+		result = append(result, &dfedata.InputData{DecimalCost: decimal.NewFromInt(price), Timestamp: second})
+	}
+
+	return result
+}
+
+func TestDrawdownFeature_Update(t *testing.T) {
+	for _, data := range [][]*dfedata.InputData{bootstrapRandomWalk(15, 1, 1800), bootstrapTrends(1800)} {
+		for _, WindowSeconds := range []uint64{5, 30, 300} {
+			f := (&DrawdownFeature{}).New(WindowSeconds)
+
+			replayTicks([]Feature{f}, data, 1800, func(TimeCurrent uint64, seen []*dfedata.InputData) {
+				var prices []float64
+
+				for _, log := range seen {
+					if log.IsInWindow(TimeCurrent, WindowSeconds) {
+						prices = append(prices, log.DecimalCost.InexactFloat64())
+					}
+				}
+
+				if len(prices) == 0 {
+					return
+				}
+
+				drawdown, runUp, current := bruteForceDrawdown(prices)
+				expected := []float64{drawdown, runUp, current}
+
+				for i := range expected {
+					if math.Abs(f.GetValues()[i].InexactFloat64() - expected[i]) > 1e-12 {
+						t.Fatalf("DrawdownFeature.Update(%d, %d) should be %v, got %v", WindowSeconds, TimeCurrent, expected, f.GetValues())
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestDrawdownFeature_Update_Carry(t *testing.T) {
+	f := (&DrawdownFeature{}).New(10)
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expected []string
+	}{
+		// Empty window without data before it
+		{1, []*dfedata.InputData {}, []string { "0", "0", "0" }},
+		// Fall from 100 to 50, rise from 50 to 80
+		{5, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(100), Timestamp: 1},
+			{DecimalCost: decimal.NewFromInt(50), Timestamp: 2},
+			{DecimalCost: decimal.NewFromInt(80), Timestamp: 5},
+		}, []string { "0.5", "0.6", "0.2" }},
+		// 100 and 50 left window [5, 15]
+		{15, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(60), Timestamp: 12},
+		}, []string { "0.25", "0", "0.25" }},
+		// Only the last price is carried
+		{40, []*dfedata.InputData {}, []string { "0", "0", "0" }},
+		// Carried price is dropped, data with equal timestamps are in order of arrival
+		{41, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(50), Timestamp: 41},
+			{DecimalCost: decimal.NewFromInt(25), Timestamp: 41},
+			{DecimalCost: decimal.NewFromInt(40), Timestamp: 41},
+		}, []string { "0.5", "0.6", "0.2" }},
+	}
+
+	for _, tick := range ticks {
+		f.Update(tick.TimeCurrent, tick.input)
+
+		for i := range tick.expected {
+			if !f.GetValues()[i].Equal(decimal.RequireFromString(tick.expected[i])) {
+				t.Fatalf("DrawdownFeature.Update(%d) should be %v, got %v", tick.TimeCurrent, tick.expected, f.GetValues())
+			}
+		}
+	}
+}
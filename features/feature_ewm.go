@@ -0,0 +1,139 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"math"
+)
+
+// ewm keeps exponentially weighted mean and variance with time decay, weight of data halves every HalfLifeSeconds.
+// Ticks are irregular, so on arrival all previous weights are decayed by 2^(-dt/HalfLife) and new data gets weight 1,
+// mean and variance are normalized by total weight, then gap of any length is just one decay
+// and data with equal timestamps are weighted equally. West's weighted algorithm keeps variance stable.
+// Nothing is evicted, so there is no DataStorage, features are unwindowed and get every data once
+type ewm struct {
+	HalfLifeSeconds uint64
+
+	weight decimal.Decimal
+	mean decimal.Decimal
+	// s weighted sum of squared deviations from mean
+	s decimal.Decimal
+
+	lastTimestamp uint64
+	amount uint64
+}
+
+func (e *ewm) reset(HalfLifeSeconds uint64) {
+	e.HalfLifeSeconds = HalfLifeSeconds
+	e.weight = decimal.NewFromInt(0)
+	e.mean = decimal.NewFromInt(0)
+	e.s = decimal.NewFromInt(0)
+	e.lastTimestamp = 0
+	e.amount = 0
+}
+
+func (e *ewm) add(data *dfedata.InputData) {
+	if e.amount > 0 && data.Timestamp > e.lastTimestamp {
+		decay := decimal.NewFromFloat(math.Exp2(-float64(data.Timestamp - e.lastTimestamp) / float64(e.HalfLifeSeconds)))
+		e.weight = e.weight.Mul(decay)
+		e.s = e.s.Mul(decay)
+	}
+
+	// Data out of order is treated as data of the last timestamp
+	if data.Timestamp > e.lastTimestamp {
+		e.lastTimestamp = data.Timestamp
+	}
+
+	e.weight = e.weight.Add(decimal.NewFromInt(1))
+	delta := data.DecimalCost.Sub(e.mean)
+	e.mean = e.mean.Add(delta.Div(e.weight))
+	e.s = e.s.Add(delta.Mul(data.DecimalCost.Sub(e.mean)))
+	e.amount += 1
+
+	// Decimal multiplication is exact, without rounding digits would grow on every data
+	precision := int32(decimal.DivisionPrecision)
+	e.weight = e.weight.Round(precision)
+	e.mean = e.mean.Round(precision)
+	e.s = e.s.Round(precision)
+}
+
+func (e *ewm) variance() decimal.Decimal {
+	if e.amount == 0 {
+		return decimal.NewFromInt(0)
+	}
+
+	return e.s.Div(e.weight)
+}
+
+// EMAFeature exponential moving average with time decay, it is unwindowed, see ewm
+// Without data it keeps the last value, weights of all data decay equally, so average doesn't change.
+// Half life of 0 seconds would decay everything but the last data, it is returned by GetError and feature is not updated
+type EMAFeature struct {
+	ewm
+	err error
+
+	BasicFeature
+}
+
+func (f *EMAFeature) New(HalfLifeSeconds uint64) *EMAFeature {
+	f.reset(HalfLifeSeconds)
+	f.LastValue = decimal.NewFromInt(0)
+	f.WindowSeconds = UnwindowedSeconds
+
+	if HalfLifeSeconds == 0 {
+		f.err = fmt.Errorf("ema feature: half life must be at least 1 second")
+	}
+
+	return f
+}
+
+func (f *EMAFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	if f.err != nil {
+		return
+	}
+
+	for _, log := range data {
+		f.add(log)
+	}
+
+	f.LastValue = f.mean
+	f.OnUpdated(TimeCurrent, data)
+}
+
+// GetAmount amount of data ever seen
+func (f *EMAFeature) GetAmount() uint64 {
+	return f.amount
+}
+
+func (f *EMAFeature) GetError() error {
+	return f.err
+}
+
+// EWMVarianceFeature exponentially weighted variance with time decay around EMA, it is unwindowed.
+// Mean and variance are one ewm state, so it is taken from EMAFeature, which is updated before, see DependentFeature
+type EWMVarianceFeature struct {
+	EMA *EMAFeature
+
+	BasicFeature
+}
+
+func (f *EWMVarianceFeature) New(EMA *EMAFeature) *EWMVarianceFeature {
+	f.EMA = EMA
+	f.LastValue = decimal.NewFromInt(0)
+	f.WindowSeconds = UnwindowedSeconds
+	return f
+}
+
+func (f *EWMVarianceFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	f.LastValue = f.EMA.variance()
+	f.OnUpdated(TimeCurrent, data)
+}
+
+func (f *EWMVarianceFeature) GetAmount() uint64 {
+	return f.EMA.GetAmount()
+}
+
+func (f *EWMVarianceFeature) GetDependencies() []Feature {
+	return []Feature{f.EMA}
+}
@@ -0,0 +1,126 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"github.com/shopspring/decimal"
+	"math"
+	"testing"
+)
+
+// bruteForceEWM weighted mean and variance of all data, weight halves every halfLife back from the last data
+func bruteForceEWM(data []*dfedata.InputData, halfLife float64) (mean float64, variance float64) {
+	last := data[len(data) - 1].Timestamp
+	weights, sum := 0.0, 0.0
+
+	for _, log := range data {
+		weight := math.Exp2(-float64(last - log.Timestamp) / halfLife)
+		weights += weight
+		sum += weight * log.DecimalCost.InexactFloat64()
+	}
+
+	mean = sum / weights
+
+	for _, log := range data {
+		weight := math.Exp2(-float64(last - log.Timestamp) / halfLife)
+		variance += weight * math.Pow(log.DecimalCost.InexactFloat64() - mean, 2)
+	}
+
+	variance /= weights
+
+	return
+}
+
+func TestEWMFeatures_Update(t *testing.T) {
+	data := bootstrapRandomWalk(3, 1, 1800)
+
+	for _, HalfLifeSeconds := range []uint64{1, 30, 600} {
+		ema := (&EMAFeature{}).New(HalfLifeSeconds)
+		variance := (&EWMVarianceFeature{}).New(ema)
+
+		replayTicks([]Feature{ema, variance}, data, 1800, func(TimeCurrent uint64, seen []*dfedata.InputData) {
+			if len(seen) == 0 {
+				return
+			}
+
+			mean, expectedVariance := bruteForceEWM(seen, float64(HalfLifeSeconds))
+
+			if math.Abs(ema.GetValue().InexactFloat64() - mean) > 1e-6 {
+				t.Fatalf("EMAFeature_Update(%d, %d) should be %f, got %s", HalfLifeSeconds, TimeCurrent, mean, ema.GetValue())
+			}
+
+			if math.Abs(variance.GetValue().InexactFloat64() - expectedVariance) > 1e-6 * math.Max(1, expectedVariance) {
+				t.Fatalf("EWMVarianceFeature_Update(%d, %d) should be %f, got %s",
+					HalfLifeSeconds, TimeCurrent, expectedVariance, variance.GetValue())
+			}
+
+			if ema.GetAmount() != uint64(len(seen)) {
+				t.Fatalf("EMAFeature_Update(%d, %d) amount should be %d, got %d", HalfLifeSeconds, TimeCurrent, len(seen), ema.GetAmount())
+			}
+		})
+	}
+}
+
+func TestEWMFeatures_Update_Gap(t *testing.T) {
+	f := (&EMAFeature{}).New(10)
+	variance := (&EWMVarianceFeature{}).New(f)
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expected decimal.Decimal
+		variance decimal.Decimal
+	}{
+		// No data yet
+		{2, []*dfedata.InputData {}, decimal.Zero, decimal.Zero},
+		// Data with equal timestamps are weighted equally
+		{5, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(10), Timestamp: 5},
+			{DecimalCost: decimal.NewFromInt(20), Timestamp: 5},
+		}, decimal.NewFromInt(15), decimal.NewFromInt(25)},
+		// No data for a minute, values are kept
+		{65, []*dfedata.InputData {}, decimal.NewFromInt(15), decimal.NewFromInt(25)},
+		// 60 seconds gap is 6 half lives, previous data weigh 2 / 64 together, so mean is 1519 / 33 and
+		// variance is (1 / 32 * (25 + (1024 / 33)^2) + (32 / 33)^2) / (33 / 32)
+		{70, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(47), Timestamp: 65},
+		}, decimal.NewFromInt(15 * 2 + 47 * 64).Div(decimal.NewFromInt(66)), decimal.NewFromInt(25 * 33 + 32768).Div(decimal.NewFromInt(1089))},
+	}
+
+	for _, tick := range ticks {
+		f.Update(tick.TimeCurrent, tick.input)
+		variance.Update(tick.TimeCurrent, tick.input)
+
+		if math.Abs(f.GetValue().Sub(tick.expected).InexactFloat64()) > 1e-9 {
+			t.Errorf("EMAFeature_Update(%d) should be %s, got %s", tick.TimeCurrent, tick.expected, f.GetValue())
+		}
+
+		if math.Abs(variance.GetValue().Sub(tick.variance).InexactFloat64()) > 1e-9 {
+			t.Errorf("EWMVarianceFeature_Update(%d) should be %s, got %s", tick.TimeCurrent, tick.variance, variance.GetValue())
+		}
+	}
+}
+
+func TestEMAFeature_New_HalfLife(t *testing.T) {
+	for _, test := range []struct {
+		HalfLifeSeconds uint64
+		fails bool
+	}{
+		{10, false},
+		{1, false},
+		{0, true},
+	} {
+		f := (&EMAFeature{}).New(test.HalfLifeSeconds)
+
+		if (f.GetError() != nil) != test.fails {
+			t.Errorf("EMAFeature.New(%d) error is %v", test.HalfLifeSeconds, f.GetError())
+		}
+
+		// Invalid feature is not updated, so it doesn't turn into the last price
+		f.Update(5, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(10), Timestamp: 1}})
+		f.Update(10, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(20), Timestamp: 6}})
+
+		if test.fails && (f.GetAmount() != 0 || !f.GetValue().IsZero()) {
+			t.Errorf("EMAFeature.New(%d) should not be updated, got %s of %d data", test.HalfLifeSeconds, f.GetValue(), f.GetAmount())
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/gammazero/deque"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"math"
+)
+
+// HistogramSource what is put into bins
+type HistogramSource uint8
+
+const (
+	// PriceSource prices of data
+	PriceSource HistogramSource = iota
+	// ReturnSource simple returns from the previous data, the first data has no return
+	ReturnSource
+)
+
+// noBin bin of data without value, like the first data of returns
+const noBin = math.MinInt
+
+// histogramCounts counts of bins of data in window, bin of every data is queued,
+// eviction goes from the oldest data, so evicted bin is at the front and nothing is recalculated
+type histogramCounts struct {
+	Source HistogramSource
+	binOf func(value decimal.Decimal) int
+
+	counts map[int]uint64
+	total uint64
+	bins deque.Deque
+	lastPrice decimal.Decimal
+}
+
+func (h *histogramCounts) reset() {
+	h.counts = make(map[int]uint64)
+	h.total = 0
+	h.bins.Clear()
+}
+
+func (h *histogramCounts) insert(data *dfedata.InputData) {
+	bin := noBin
+
+	switch h.Source {
+	case PriceSource:
+		bin = h.binOf(data.DecimalCost)
+	case ReturnSource:
+		if h.lastPrice.IsPositive() {
+			bin = h.binOf(data.DecimalCost.Sub(h.lastPrice).Div(h.lastPrice))
+		}
+
+		h.lastPrice = data.DecimalCost
+	}
+
+	h.bins.PushBack(bin)
+
+	if bin != noBin {
+		h.counts[bin]++
+		h.total++
+	}
+}
+
+func (h *histogramCounts) evict() {
+	if h.bins.Len() == 0 {
+		return
+	}
+
+	bin := h.bins.PopFront().(int)
+
+	if bin == noBin {
+		return
+	}
+
+	h.total--
+
+	if h.counts[bin]--; h.counts[bin] == 0 {
+		delete(h.counts, bin)
+	}
+}
+
+// entropy Shannon entropy in bits of distribution of values over bins
+func (h *histogramCounts) entropy() decimal.Decimal {
+	if h.total == 0 {
+		return decimal.NewFromInt(0)
+	}
+
+	result := 0.0
+	total := float64(h.total)
+
+	for _, count := range h.counts {
+		probability := float64(count) / total
+		result -= probability * math.Log2(probability)
+	}
+
+	return decimal.NewFromFloat(result)
+}
+
+// HistogramFeature counts of values in Bins equal bins between Lower and Upper in window and entropy of them,
+// values are [count of bin 0, ..., count of bin Bins - 1, entropy]. Values out of range go to the edge bins.
+// Bins below 1 or Lower not below Upper is returned by GetError and feature is not updated
+type HistogramFeature struct {
+	Lower decimal.Decimal
+	Upper decimal.Decimal
+	Bins int
+
+	histogramCounts
+	LastValues []decimal.Decimal
+	err error
+
+	BasicRunningFeature
+}
+
+func (f *HistogramFeature) New(WindowSeconds uint64, dataStorage storage.InputDataStorage, Source HistogramSource,
+	Lower decimal.Decimal, Upper decimal.Decimal, Bins int) *HistogramFeature {
+	f.Lower = Lower
+	f.Upper = Upper
+	f.Bins = Bins
+	f.Source = Source
+	f.binOf = f.fixedBinOf
+	f.reset()
+	f.LastValue = decimal.NewFromInt(0)
+	f.DataStorage = dataStorage
+	f.RunningFeature = f
+	f.WindowSeconds = WindowSeconds
+
+	if Bins < 1 {
+		f.err = fmt.Errorf("histogram feature: bins must be at least 1, got %d", Bins)
+		return f
+	}
+
+	if !Lower.LessThan(Upper) {
+		f.err = fmt.Errorf("histogram feature: lower %s must be below upper %s", Lower, Upper)
+		return f
+	}
+
+	f.LastValues = make([]decimal.Decimal, Bins + 1)
+	return f
+}
+
+func (f *HistogramFeature) fixedBinOf(value decimal.Decimal) int {
+	width := f.Upper.Sub(f.Lower)
+
+	if !value.GreaterThan(f.Lower) {
+		return 0
+	}
+
+	// Division goes last, so edges of bins are exact
+	if bin := int(value.Sub(f.Lower).Mul(decimal.NewFromInt(int64(f.Bins))).Div(width).IntPart()); bin < f.Bins {
+		return bin
+	}
+
+	return f.Bins - 1
+}
+
+func (f *HistogramFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	if f.err != nil {
+		return
+	}
+
+	f.updateWindow(TimeCurrent, data)
+
+	for bin := 0; bin < f.Bins; bin++ {
+		f.LastValues[bin] = decimal.NewFromInt(int64(f.counts[bin]))
+	}
+
+	f.LastValues[f.Bins] = f.entropy()
+	f.LastValue = f.LastValues[f.Bins]
+	f.OnUpdated(TimeCurrent, data)
+}
+
+func (f *HistogramFeature) InvalidateData(data *dfedata.InputData) {
+	f.evict()
+	f.LastAmount -= 1
+}
+
+func (f *HistogramFeature) CalculateData(data *dfedata.InputData) {
+	// Window was reset, while only carried data was there
+	if f.LastAmount == 0 {
+		f.reset()
+	}
+
+	f.insert(data)
+	f.LastAmount += 1
+}
+
+// GetValues counts of bins and entropy
+func (f *HistogramFeature) GetValues() []decimal.Decimal {
+	return f.LastValues
+}
+
+// GetCounts counts of occupied bins
+func (f *HistogramFeature) GetCounts() map[int]uint64 {
+	return f.counts
+}
+
+func (f *HistogramFeature) GetError() error {
+	return f.err
+}
+
+// EntropyFeature Shannon entropy in bits of values in window over adaptive bins of BinWidth,
+// bins are not limited by range, they are created for values which come and dropped when they are empty.
+// BinWidth which is not positive is returned by GetError and feature is not updated
+type EntropyFeature struct {
+	BinWidth decimal.Decimal
+
+	histogramCounts
+	err error
+
+	BasicRunningFeature
+}
+
+func (f *EntropyFeature) New(WindowSeconds uint64, dataStorage storage.InputDataStorage, Source HistogramSource, BinWidth decimal.Decimal) *EntropyFeature {
+	f.BinWidth = BinWidth
+	f.Source = Source
+	f.binOf = f.adaptiveBinOf
+	f.reset()
+	f.LastValue = decimal.NewFromInt(0)
+	f.DataStorage = dataStorage
+	f.RunningFeature = f
+	f.WindowSeconds = WindowSeconds
+
+	if !BinWidth.IsPositive() {
+		f.err = fmt.Errorf("entropy feature: bin width must be positive, got %s", BinWidth)
+	}
+
+	return f
+}
+
+func (f *EntropyFeature) adaptiveBinOf(value decimal.Decimal) int {
+	return int(value.Div(f.BinWidth).Floor().IntPart())
+}
+
+func (f *EntropyFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	if f.err != nil {
+		return
+	}
+
+	f.updateWindow(TimeCurrent, data)
+	f.LastValue = f.entropy()
+	f.OnUpdated(TimeCurrent, data)
+}
+
+func (f *EntropyFeature) InvalidateData(data *dfedata.InputData) {
+	f.evict()
+	f.LastAmount -= 1
+}
+
+func (f *EntropyFeature) CalculateData(data *dfedata.InputData) {
+	if f.LastAmount == 0 {
+		f.reset()
+	}
+
+	f.insert(data)
+	f.LastAmount += 1
+}
+
+// GetCounts counts of occupied bins, bin i holds values in [i * BinWidth, (i + 1) * BinWidth)
+func (f *EntropyFeature) GetCounts() map[int]uint64 {
+	return f.counts
+}
+
+func (f *EntropyFeature) GetError() error {
+	return f.err
+}
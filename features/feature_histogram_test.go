@@ -0,0 +1,196 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/shopspring/decimal"
+	"math"
+	"testing"
+)
+
+func bruteForceEntropy(counts map[int]int, total int) float64 {
+	result := 0.0
+
+	for _, count := range counts {
+		probability := float64(count) / float64(total)
+		result -= probability * math.Log2(probability)
+	}
+
+	return result
+}
+
+func TestHistogramFeatures_Update(t *testing.T) {
+	data := bootstrapRandomWalk(16, 1, 1800)
+	lower, upper := decimal.NewFromInt(65300), decimal.NewFromInt(65400)
+
+	for _, WindowSeconds := range []uint64{30, 300} {
+		histogram := (&HistogramFeature{}).New(WindowSeconds, &storage.LinkedListDataStorage{}, PriceSource, lower, upper, 8)
+		entropy := (&EntropyFeature{}).New(WindowSeconds, &storage.LinkedListDataStorage{}, ReturnSource, decimal.RequireFromString("0.000002"))
+
+		replayTicks([]Feature{histogram, entropy}, data, 1800, func(TimeCurrent uint64, seen []*dfedata.InputData) {
+			bins, returnBins := map[int]int{}, map[int]int{}
+			total, returnsTotal := 0, 0
+
+			for i, log := range seen {
+				if !log.IsInWindow(TimeCurrent, WindowSeconds) {
+					continue
+				}
+
+				bin := int(math.Floor((log.DecimalCost.InexactFloat64() - 65300) / 12.5))
+				bins[int(math.Max(0, math.Min(7, float64(bin))))]++
+				total++
+
+				if i > 0 {
+					r := log.DecimalCost.Sub(seen[i-1].DecimalCost).Div(seen[i-1].DecimalCost)
+					returnBins[int(r.Div(decimal.RequireFromString("0.000002")).Floor().IntPart())]++
+					returnsTotal++
+				}
+			}
+
+			if total == 0 {
+				return
+			}
+
+			for bin := 0; bin < 8; bin++ {
+				if histogram.GetValues()[bin].IntPart() != int64(bins[bin]) {
+					t.Fatalf("HistogramFeature.Update(%d, %d) bin %d should be %d, got %v", WindowSeconds, TimeCurrent, bin, bins[bin], histogram.GetValues())
+				}
+			}
+
+			if math.Abs(histogram.GetValue().InexactFloat64() - bruteForceEntropy(bins, total)) > 1e-9 {
+				t.Fatalf("HistogramFeature.Update(%d, %d) entropy should be %g, got %s",
+					WindowSeconds, TimeCurrent, bruteForceEntropy(bins, total), histogram.GetValue())
+			}
+
+			if returnsTotal > 0 && math.Abs(entropy.GetValue().InexactFloat64() - bruteForceEntropy(returnBins, returnsTotal)) > 1e-9 {
+				t.Fatalf("EntropyFeature.Update(%d, %d) should be %g, got %s",
+					WindowSeconds, TimeCurrent, bruteForceEntropy(returnBins, returnsTotal), entropy.GetValue())
+			}
+		})
+	}
+}
+
+func TestHistogramFeature_Update_Carry(t *testing.T) {
+	f := (&HistogramFeature{}).New(10, &storage.LinkedListDataStorage{}, PriceSource, decimal.NewFromInt(0), decimal.NewFromInt(4), 2)
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expected []string
+	}{
+		// Empty window without data before it
+		{1, []*dfedata.InputData {}, []string { "0", "0", "0" }},
+		// Edge of bins goes to the upper bin, out of range goes to the edge bins
+		{5, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(1), Timestamp: 1},
+			{DecimalCost: decimal.NewFromInt(2), Timestamp: 2},
+			{DecimalCost: decimal.NewFromInt(-5), Timestamp: 3},
+			{DecimalCost: decimal.NewFromInt(9), Timestamp: 4},
+		}, []string { "2", "2", "1" }},
+		// 1 and 2 left window [3, 13]
+		{13, []*dfedata.InputData {}, []string { "1", "1", "1" }},
+		// Only the last data is carried
+		{30, []*dfedata.InputData {}, []string { "0", "1", "0" }},
+		// Carried data is dropped, data with equal timestamps are all in window
+		{31, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(0), Timestamp: 31},
+			{DecimalCost: decimal.NewFromInt(1), Timestamp: 31},
+			{DecimalCost: decimal.NewFromInt(3), Timestamp: 31},
+			{DecimalCost: decimal.NewFromInt(4), Timestamp: 31},
+		}, []string { "2", "2", "1" }},
+	}
+
+	for _, tick := range ticks {
+		f.Update(tick.TimeCurrent, tick.input)
+
+		for i := range tick.expected {
+			if !f.GetValues()[i].Equal(decimal.RequireFromString(tick.expected[i])) {
+				t.Fatalf("HistogramFeature.Update(%d) should be %v, got %v", tick.TimeCurrent, tick.expected, f.GetValues())
+			}
+		}
+	}
+}
+
+func TestEntropyFeature_Update_Table(t *testing.T) {
+	f := (&EntropyFeature{}).New(10, &storage.LinkedListDataStorage{}, ReturnSource, decimal.RequireFromString("0.5"))
+	price := func(value int64, timestamp uint64) *dfedata.InputData {
+		return &dfedata.InputData{DecimalCost: decimal.NewFromInt(value), Timestamp: timestamp}
+	}
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expected float64
+		amount uint64
+	}{
+		// Empty window without data before it
+		{1, nil, 0, 0},
+		// The first data has no return
+		{2, []*dfedata.InputData{price(100, 1)}, 0, 1},
+		// Returns 1, -0.5, 1, -0.5 are in bins 2 and -1 equally, data with equal timestamps are in order of arrival
+		{5, []*dfedata.InputData{price(200, 2), price(100, 3), price(200, 3), price(100, 4)}, 1, 5},
+		// Returns of 3 and 4 are left, -0.5 twice and 1 once
+		{13, nil, math.Log2(3) - 2.0 / 3, 3},
+		// Only the last data is carried
+		{30, nil, 0, 1},
+		// Carried data is dropped, its price is the previous one for returns 2 and -0.5
+		{31, []*dfedata.InputData{price(300, 31), price(150, 31)}, 1, 2},
+		// Return 0.5 is in the third bin
+		{32, []*dfedata.InputData{price(225, 32)}, math.Log2(3), 3},
+	}
+
+	for _, tick := range ticks {
+		f.Update(tick.TimeCurrent, tick.input)
+
+		if math.Abs(f.GetValue().InexactFloat64() - tick.expected) > 1e-9 || f.GetAmount() != tick.amount {
+			t.Errorf("EntropyFeature.Update(%d) should be %g of %d data, got %s of %d",
+				tick.TimeCurrent, tick.expected, tick.amount, f.GetValue(), f.GetAmount())
+		}
+	}
+}
+
+func TestHistogramFeature_New(t *testing.T) {
+	for _, test := range []struct {
+		Lower int64
+		Upper int64
+		Bins int
+		fails bool
+	}{
+		{0, 4, 2, false},
+		{0, 4, 1, false},
+		{0, 4, 0, true},
+		{0, 4, -2, true},
+		{4, 4, 2, true},
+		{5, 4, 2, true},
+	} {
+		f := (&HistogramFeature{}).New(10, &storage.LinkedListDataStorage{}, PriceSource,
+			decimal.NewFromInt(test.Lower), decimal.NewFromInt(test.Upper), test.Bins)
+
+		if (f.GetError() != nil) != test.fails {
+			t.Errorf("HistogramFeature.New(%d, %d, %d) error is %v", test.Lower, test.Upper, test.Bins, f.GetError())
+		}
+
+		// Invalid feature is not updated, so it doesn't put data out of bins
+		f.Update(5, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(1), Timestamp: 1}})
+	}
+}
+
+func TestEntropyFeature_New(t *testing.T) {
+	for _, test := range []struct {
+		BinWidth string
+		fails bool
+	}{
+		{"0.5", false},
+		{"0", true},
+		{"-1", true},
+	} {
+		f := (&EntropyFeature{}).New(10, &storage.LinkedListDataStorage{}, PriceSource, decimal.RequireFromString(test.BinWidth))
+
+		if (f.GetError() != nil) != test.fails {
+			t.Errorf("EntropyFeature.New(%s) error is %v", test.BinWidth, f.GetError())
+		}
+
+		// Invalid feature is not updated, so it doesn't divide by 0
+		f.Update(5, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(1), Timestamp: 1}})
+	}
+}
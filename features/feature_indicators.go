@@ -0,0 +1,276 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"fmt"
+	"github.com/shopspring/decimal"
+)
+
+// lastInWindow the newest data of batch in window, nil if there are none
+func lastInWindow(TimeCurrent uint64, WindowSeconds uint64, data []*dfedata.InputData) *dfedata.InputData {
+	for i := len(data) - 1; i >= 0; i-- {
+		if data[i].IsInWindow(TimeCurrent, WindowSeconds) {
+			return data[i]
+		}
+	}
+
+	return nil
+}
+
+// ZScoreFeature how many standard deviations the last price is from mean of window, mean and stddev are taken
+// from StdDevFeature of the same window, which is updated before, see DependentFeature
+type ZScoreFeature struct {
+	StdDev *StdDevFeature
+	LastPrice decimal.Decimal
+
+	BasicFeature
+}
+
+func (f *ZScoreFeature) New(StdDev *StdDevFeature) *ZScoreFeature {
+	f.StdDev = StdDev
+	f.LastPrice = decimal.NewFromInt(0)
+	f.LastValue = decimal.NewFromInt(0)
+	f.WindowSeconds = StdDev.WindowSeconds
+	return f
+}
+
+func (f *ZScoreFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	if last := lastInWindow(TimeCurrent, f.WindowSeconds, data); last != nil {
+		f.LastPrice = last.DecimalCost
+	}
+
+	stdDev := f.StdDev.GetValue()
+
+	// Equal prices are not deviating
+	if stdDev.IsZero() {
+		f.LastValue = decimal.NewFromInt(0)
+	} else {
+		f.LastValue = f.LastPrice.Sub(f.StdDev.LastMean).Div(stdDev)
+	}
+
+	f.OnUpdated(TimeCurrent, data)
+}
+
+func (f *ZScoreFeature) GetDependencies() []Feature {
+	return []Feature{f.StdDev}
+}
+
+// BollingerFeature bands K standard deviations above and below mean of window, values are [upper, lower],
+// mean and stddev are taken from StdDevFeature, like in ZScoreFeature
+type BollingerFeature struct {
+	StdDev *StdDevFeature
+	K decimal.Decimal
+
+	LastValues []decimal.Decimal
+
+	BasicFeature
+}
+
+func (f *BollingerFeature) New(StdDev *StdDevFeature, K decimal.Decimal) *BollingerFeature {
+	f.StdDev = StdDev
+	f.K = K
+	f.LastValues = []decimal.Decimal{decimal.NewFromInt(0), decimal.NewFromInt(0)}
+	f.LastValue = decimal.NewFromInt(0)
+	f.WindowSeconds = StdDev.WindowSeconds
+	return f
+}
+
+func (f *BollingerFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	width := f.StdDev.GetValue().Mul(f.K)
+	f.LastValues[0] = f.StdDev.LastMean.Add(width)
+	f.LastValues[1] = f.StdDev.LastMean.Sub(width)
+	f.LastValue = f.LastValues[0]
+	f.OnUpdated(TimeCurrent, data)
+}
+
+// GetValues upper and lower bands
+func (f *BollingerFeature) GetValues() []decimal.Decimal {
+	return f.LastValues
+}
+
+func (f *BollingerFeature) GetDependencies() []Feature {
+	return []Feature{f.StdDev}
+}
+
+// RSIFeature relative strength index of changes between closes of periods of PeriodSeconds with Wilder's smoothing
+// over Periods periods, until there are Periods changes they are just averaged.
+// Period without data closes at the carried price, so its change is 0. Period ending at E holds data of (E - PeriodSeconds, E],
+// like ticks. RSI is unwindowed, it gets every data. Periods below 1 or PeriodSeconds of 0 are returned by GetError
+// and feature is not updated
+type RSIFeature struct {
+	Periods int64
+	PeriodSeconds uint64
+
+	lastPrice decimal.Decimal
+	lastClose decimal.Decimal
+	hasClose bool
+	// started periodEnd is set by the first data, timestamp 0 is a valid one
+	started bool
+	periodEnd uint64
+	changes int64
+	averageGain decimal.Decimal
+	averageLoss decimal.Decimal
+	err error
+
+	BasicFeature
+}
+
+func (f *RSIFeature) New(Periods int64, PeriodSeconds uint64) *RSIFeature {
+	f.Periods = Periods
+	f.PeriodSeconds = PeriodSeconds
+	f.averageGain = decimal.NewFromInt(0)
+	f.averageLoss = decimal.NewFromInt(0)
+	f.LastValue = decimal.NewFromInt(0)
+	f.WindowSeconds = UnwindowedSeconds
+
+	if Periods < 1 {
+		f.err = fmt.Errorf("rsi feature: periods must be at least 1, got %d", Periods)
+		return f
+	}
+
+	if PeriodSeconds == 0 {
+		f.err = fmt.Errorf("rsi feature: period must be at least 1 second")
+	}
+
+	return f
+}
+
+func (f *RSIFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	if f.err != nil {
+		return
+	}
+
+	for _, log := range data {
+		if !f.started {
+			f.periodEnd = (log.Timestamp + f.PeriodSeconds - 1) / f.PeriodSeconds * f.PeriodSeconds
+			f.started = true
+		}
+
+		for f.periodEnd < log.Timestamp {
+			f.closePeriod()
+		}
+
+		f.lastPrice = log.DecimalCost
+	}
+
+	for f.started && f.periodEnd <= TimeCurrent {
+		f.closePeriod()
+	}
+
+	f.OnUpdated(TimeCurrent, data)
+}
+
+func (f *RSIFeature) closePeriod() {
+	// The first close has nothing to change from
+	if !f.hasClose {
+		f.lastClose = f.lastPrice
+		f.hasClose = true
+		f.periodEnd += f.PeriodSeconds
+		return
+	}
+
+	change := f.lastPrice.Sub(f.lastClose)
+	gain, loss := decimal.Zero, decimal.Zero
+
+	if change.IsPositive() {
+		gain = change
+	} else {
+		loss = change.Neg()
+	}
+
+	f.changes++
+	n := decimal.NewFromInt(f.changes)
+
+	if f.changes > f.Periods {
+		n = decimal.NewFromInt(f.Periods)
+	}
+
+	// Running average while there are less than Periods changes, then Wilder's smoothing with the same formula
+	f.averageGain = f.averageGain.Add(gain.Sub(f.averageGain).Div(n))
+	f.averageLoss = f.averageLoss.Add(loss.Sub(f.averageLoss).Div(n))
+
+	f.lastClose = f.lastPrice
+	f.periodEnd += f.PeriodSeconds
+
+	switch {
+	case f.averageLoss.IsZero() && f.averageGain.IsZero():
+		f.LastValue = decimal.NewFromInt(50)
+	case f.averageLoss.IsZero():
+		f.LastValue = decimal.NewFromInt(100)
+	default:
+		relativeStrength := f.averageGain.Div(f.averageLoss)
+		f.LastValue = decimal.NewFromInt(100).Sub(decimal.NewFromInt(100).Div(relativeStrength.Add(decimal.NewFromInt(1))))
+	}
+}
+
+// GetAmount amount of closed periods
+func (f *RSIFeature) GetAmount() uint64 {
+	return uint64(f.changes)
+}
+
+func (f *RSIFeature) GetError() error {
+	return f.err
+}
+
+// MACDFeature difference of fast and slow EMAFeature, signal is EMA of that difference sampled every tick
+// with SignalHalfLifeSeconds and histogram is difference minus signal, values are [macd, signal, histogram].
+// Signal half life of 0 seconds is returned by GetError and feature is not updated
+type MACDFeature struct {
+	Fast *EMAFeature
+	Slow *EMAFeature
+
+	signal ewm
+	LastValues []decimal.Decimal
+	err error
+
+	BasicFeature
+}
+
+func (f *MACDFeature) New(Fast *EMAFeature, Slow *EMAFeature, SignalHalfLifeSeconds uint64) *MACDFeature {
+	f.Fast = Fast
+	f.Slow = Slow
+	f.signal.reset(SignalHalfLifeSeconds)
+	f.LastValues = []decimal.Decimal{decimal.NewFromInt(0), decimal.NewFromInt(0), decimal.NewFromInt(0)}
+	f.LastValue = decimal.NewFromInt(0)
+	f.WindowSeconds = UnwindowedSeconds
+
+	if SignalHalfLifeSeconds == 0 {
+		f.err = fmt.Errorf("macd feature: signal half life must be at least 1 second")
+	}
+
+	return f
+}
+
+func (f *MACDFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	if f.err != nil {
+		return
+	}
+
+	// Nothing to average before the first data, values stay 0, chained features still get the tick
+	if f.Fast.GetAmount() == 0 || f.Slow.GetAmount() == 0 {
+		f.OnUpdated(TimeCurrent, data)
+		return
+	}
+
+	line := f.Fast.GetValue().Sub(f.Slow.GetValue())
+	f.signal.add(&dfedata.InputData{DecimalCost: line, Timestamp: TimeCurrent})
+
+	f.LastValues[0] = line
+	f.LastValues[1] = f.signal.mean
+	f.LastValues[2] = line.Sub(f.signal.mean)
+	f.LastValue = line
+	f.OnUpdated(TimeCurrent, data)
+}
+
+// GetValues macd, signal and histogram
+func (f *MACDFeature) GetValues() []decimal.Decimal {
+	return f.LastValues
+}
+
+func (f *MACDFeature) GetDependencies() []Feature {
+	return []Feature{f.Fast, f.Slow}
+}
+
+func (f *MACDFeature) GetError() error {
+	return f.err
+}
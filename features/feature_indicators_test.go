@@ -0,0 +1,262 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/shopspring/decimal"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestZScoreBollingerFeatures_Update(t *testing.T) {
+	data := bootstrapRandomWalk(8, 1, 1800)
+	stdDev := (&StdDevFeature{}).New(60, &storage.LinkedListDataStorage{})
+	zScore := (&ZScoreFeature{}).New(stdDev)
+	bollinger := (&BollingerFeature{}).New(stdDev, decimal.NewFromInt(2))
+
+	// Dependency goes first, like FeatureEngineer does
+	replayTicks([]Feature{stdDev, zScore, bollinger}, data, 1800, func(TimeCurrent uint64, seen []*dfedata.InputData) {
+		values := bruteForceWindow(seen, TimeCurrent, 60)
+
+		if len(values) < 2 {
+			return
+		}
+
+		mean := 0.0
+
+		for _, value := range values {
+			mean += value / float64(len(values))
+		}
+
+		expectedStdDev, _, _ := bruteForceMoments(values)
+		last := seen[len(seen)-1].DecimalCost.InexactFloat64()
+		expectedZScore := 0.0
+
+		if expectedStdDev > 0 {
+			expectedZScore = (last - mean) / expectedStdDev
+		}
+
+		if math.Abs(zScore.GetValue().InexactFloat64() - expectedZScore) > 1e-6 {
+			t.Fatalf("ZScoreFeature.Update(%d) should be %g, got %s", TimeCurrent, expectedZScore, zScore.GetValue())
+		}
+
+		upper, lower := bollinger.GetValues()[0].InexactFloat64(), bollinger.GetValues()[1].InexactFloat64()
+
+		if math.Abs(upper - mean - 2 * expectedStdDev) > 1e-6 || math.Abs(mean - 2 * expectedStdDev - lower) > 1e-6 {
+			t.Fatalf("BollingerFeature.Update(%d) should be [%g, %g], got %v",
+				TimeCurrent, mean + 2 * expectedStdDev, mean - 2 * expectedStdDev, bollinger.GetValues())
+		}
+	})
+}
+
+func TestZScoreBollingerFeatures_Update_Table(t *testing.T) {
+	stdDev := (&StdDevFeature{}).New(10, &storage.LinkedListDataStorage{})
+	zScore := (&ZScoreFeature{}).New(stdDev)
+	bollinger := (&BollingerFeature{}).New(stdDev, decimal.NewFromInt(2))
+
+	var tests = []struct {
+		input []*dfedata.InputData
+		TimeCurrent uint64
+		zScore float64
+		upper float64
+		lower float64
+	}{
+		// Empty window without data before it
+		{[]*dfedata.InputData{}, 1, 0, 0, 0},
+		// Data with equal timestamps are all in window, 1 and 3 have mean 2 and sample deviation √2
+		{[]*dfedata.InputData{{DecimalCost: decimal.NewFromInt(1), Timestamp: 2}, {DecimalCost: decimal.NewFromInt(3), Timestamp: 2}},
+			2, 1 / math.Sqrt2, 2 + 2 * math.Sqrt2, 2 - 2 * math.Sqrt2},
+		// 1, 3, 2 have deviation 1, the last one is the mean
+		{[]*dfedata.InputData{{DecimalCost: decimal.NewFromInt(2), Timestamp: 3}}, 3, 0, 4, 0},
+		{[]*dfedata.InputData{}, 12, 0, 4, 0},
+		// 1 and 3 left window, single value has no deviation
+		{[]*dfedata.InputData{}, 13, 0, 2, 2},
+		// Empty window carries the last value only
+		{[]*dfedata.InputData{}, 30, 0, 2, 2},
+		// Carried value is dropped
+		{[]*dfedata.InputData{{DecimalCost: decimal.NewFromInt(8), Timestamp: 31}}, 31, 0, 8, 8},
+	}
+
+	for _, tt := range tests {
+		// Dependency goes first, like FeatureEngineer does
+		for _, f := range []Feature{stdDev, zScore, bollinger} {
+			f.Update(tt.TimeCurrent, tt.input)
+		}
+
+		if math.Abs(zScore.GetValue().InexactFloat64() - tt.zScore) > 1e-9 {
+			t.Errorf("ZScoreFeature.Update(%d) should be %g, got %s", tt.TimeCurrent, tt.zScore, zScore.GetValue())
+		}
+
+		upper, lower := bollinger.GetValues()[0].InexactFloat64(), bollinger.GetValues()[1].InexactFloat64()
+
+		if math.Abs(upper - tt.upper) > 1e-9 || math.Abs(lower - tt.lower) > 1e-9 {
+			t.Errorf("BollingerFeature.Update(%d) should be [%g, %g], got %v", tt.TimeCurrent, tt.upper, tt.lower, bollinger.GetValues())
+		}
+	}
+}
+
+func TestRSIFeature_Update(t *testing.T) {
+	f := (&RSIFeature{}).New(2, 5)
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expected decimal.Decimal
+	}{
+		// The first close
+		{5, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(10), Timestamp: 3},
+		}, decimal.NewFromInt(0)},
+		// Gain 2
+		{10, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(15), Timestamp: 7},
+			{DecimalCost: decimal.NewFromInt(12), Timestamp: 10},
+		}, decimal.NewFromInt(100)},
+		// Loss 4, gains 1 and losses 2 on average
+		{15, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(8), Timestamp: 15},
+		}, decimal.RequireFromString("33.3333333333333333")},
+		// Two periods without data have zero changes, Wilder's smoothing halves averages on each
+		{25, []*dfedata.InputData {}, decimal.RequireFromString("33.3333333333333333")},
+		// Gain 4 after gains 0.25 and losses 0.5 on average
+		{30, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(12), Timestamp: 28},
+		}, decimal.RequireFromString("89.4736842105263158")},
+	}
+
+	for _, tick := range ticks {
+		f.Update(tick.TimeCurrent, tick.input)
+
+		if !f.GetValue().Round(10).Equal(tick.expected.Round(10)) {
+			t.Errorf("RSIFeature.Update(%d) should be %s, got %s", tick.TimeCurrent, tick.expected, f.GetValue())
+		}
+	}
+
+	if f.GetAmount() != 5 {
+		t.Errorf("RSIFeature.GetAmount should be 5 changes, got %d", f.GetAmount())
+	}
+}
+
+// Period ending at 0 is a valid one, its close is the first
+func TestRSIFeature_Update_TimestampZero(t *testing.T) {
+	f := (&RSIFeature{}).New(2, 5)
+	f.Update(0, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(10), Timestamp: 0}})
+	f.Update(5, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(12), Timestamp: 3}})
+
+	if !f.GetValue().Equal(decimal.NewFromInt(100)) || f.GetAmount() != 1 {
+		t.Errorf("RSIFeature.Update should have gain 2 after close at 0, got %s of %d changes", f.GetValue(), f.GetAmount())
+	}
+
+	if (&RSIFeature{}).New(2, 0).GetError() == nil {
+		t.Errorf("RSIFeature.New with period of 0 seconds should fail")
+	}
+}
+
+func TestRSIFeature_New(t *testing.T) {
+	for _, test := range []struct {
+		Periods int64
+		PeriodSeconds uint64
+		fails bool
+	}{
+		{14, 5, false},
+		{1, 5, false},
+		{0, 5, true},
+		{-3, 5, true},
+		{14, 0, true},
+		{0, 0, true},
+	} {
+		f := (&RSIFeature{}).New(test.Periods, test.PeriodSeconds)
+
+		if (f.GetError() != nil) != test.fails {
+			t.Errorf("RSIFeature.New(%d, %d) error is %v", test.Periods, test.PeriodSeconds, f.GetError())
+		}
+
+		// The first error is reported
+		if test.Periods < 1 && !strings.Contains(f.GetError().Error(), "periods") {
+			t.Errorf("RSIFeature.New(%d, %d) should report periods, got %v", test.Periods, test.PeriodSeconds, f.GetError())
+		}
+
+		// Invalid feature is not updated, so it doesn't divide by 0
+		for TimeCurrent := uint64(5); TimeCurrent <= 20; TimeCurrent += 5 {
+			f.Update(TimeCurrent, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(int64(TimeCurrent)), Timestamp: TimeCurrent}})
+		}
+	}
+}
+
+func TestMACDFeature_Update(t *testing.T) {
+	data := bootstrapRandomWalk(9, 1, 600)
+	fast, slow := (&EMAFeature{}).New(10), (&EMAFeature{}).New(60)
+	macd := (&MACDFeature{}).New(fast, slow, 20)
+	signal := (&EMAFeature{}).New(20)
+
+	replayTicks([]Feature{fast, slow, macd}, data, 600, func(TimeCurrent uint64, seen []*dfedata.InputData) {
+		if len(seen) == 0 {
+			return
+		}
+
+		line := fast.GetValue().Sub(slow.GetValue())
+		signal.Update(TimeCurrent, []*dfedata.InputData{{DecimalCost: line, Timestamp: TimeCurrent}})
+
+		expected := []decimal.Decimal{line, signal.GetValue(), line.Sub(signal.GetValue())}
+
+		for i := range expected {
+			if !macd.GetValues()[i].Equal(expected[i]) {
+				t.Fatalf("MACDFeature.Update(%d) should be %v, got %v", TimeCurrent, expected, macd.GetValues())
+			}
+		}
+	})
+}
+
+// Chained features get every tick, including the ones before EMAs have data
+func TestMACDFeature_Update_Chain(t *testing.T) {
+	fast, slow := (&EMAFeature{}).New(10), (&EMAFeature{}).New(60)
+	macd := (&MACDFeature{}).New(fast, slow, 20)
+	var chained Feature = &chainedValues{values: make(chan decimal.Decimal, 1)}
+	macd.Chain(&chained)
+
+	ticks := [][]*dfedata.InputData{
+		{},
+		{{DecimalCost: decimal.NewFromInt(10), Timestamp: 7}},
+	}
+
+	for i, input := range ticks {
+		TimeCurrent := uint64(i + 1) * 5
+
+		for _, f := range []Feature{fast, slow, macd} {
+			f.Update(TimeCurrent, input)
+		}
+
+		select {
+		case value := <-chained.(*chainedValues).values:
+			if !value.Equal(macd.GetValue()) {
+				t.Fatalf("MACDFeature.Update(%d) should send %s to chained features, got %s", TimeCurrent, macd.GetValue(), value)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("MACDFeature.Update(%d) should notify chained features", TimeCurrent)
+		}
+	}
+}
+
+func TestMACDFeature_New_SignalHalfLife(t *testing.T) {
+	fast, slow := (&EMAFeature{}).New(10), (&EMAFeature{}).New(60)
+
+	if (&MACDFeature{}).New(fast, slow, 20).GetError() != nil {
+		t.Errorf("MACDFeature.New with signal half life of 20 seconds should not fail")
+	}
+
+	macd := (&MACDFeature{}).New(fast, slow, 0)
+
+	if macd.GetError() == nil {
+		t.Errorf("MACDFeature.New with signal half life of 0 seconds should fail")
+	}
+
+	for _, f := range []Feature{fast, slow, macd} {
+		f.Update(5, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(10), Timestamp: 5}})
+	}
+
+	if !macd.GetValues()[1].IsZero() {
+		t.Errorf("Invalid MACDFeature should not be updated, got %v", macd.GetValues())
+	}
+}
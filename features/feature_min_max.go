@@ -28,13 +28,12 @@ type BasicMinMaxFeature struct {
 // So we compute from lowest to widest then we just skip already computed fragment and take its value as LastValue from lower
 // Also deque for both min and max?
 func (f *BasicMinMaxFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData)  {
-	// We can escape there since no updates will be made to LastValue deque will be cleared when there will be data available
-	// Minimal value will stay the same as in the last period
-	if !dfedata.IsThereAreAnyDataToProcess(TimeCurrent, f.WindowSeconds, data) {
-		return
-	}
+	willAppend := dfedata.IsThereAreAnyDataToProcess(TimeCurrent, f.WindowSeconds, data)
 
-	for f.dq.Len() > 0 && int64(f.dq.Front().(*dfedata.InputData).Timestamp) <= int64(TimeCurrent) - int64(f.WindowSeconds) {
+	// Back of deque is always the last data, so without new data we evict until only it is left,
+	// it is preserved as the only value of window by TZ, new data replace it
+	for f.dq.Len() > 0 && (f.dq.Len() > 1 || willAppend) &&
+		int64(f.dq.Front().(*dfedata.InputData).Timestamp) < int64(TimeCurrent) - int64(f.WindowSeconds) {
 		f.dq.PopFront()
 	}
 
@@ -51,10 +50,22 @@ func (f *BasicMinMaxFeature) Update(TimeCurrent uint64, data []*dfedata.InputDat
 		f.dq.PushBack(log)
 	}
 
-	f.LastValue = f.dq.Front().(*dfedata.InputData).DecimalCost
+	if f.dq.Len() > 0 {
+		f.LastValue = f.dq.Front().(*dfedata.InputData).DecimalCost
+	}
+
 	f.OnUpdated(TimeCurrent, data)
 }
 
+// GetExtreme data with the extreme price in window, the latest of them when price is repeated, nil before any data
+func (f *BasicMinMaxFeature) GetExtreme() *dfedata.InputData {
+	if f.dq.Len() == 0 {
+		return nil
+	}
+
+	return f.dq.Front().(*dfedata.InputData)
+}
+
 type MaxFeature struct {
 	BasicMinMaxFeature
 }
@@ -83,4 +94,51 @@ func (f *MinFeature) New(WindowSeconds uint64) *MinFeature {
 
 func (f *MinFeature) Compare(decimal1 decimal.Decimal, decimal2 decimal.Decimal) bool {
 	return decimal1.GreaterThanOrEqual(decimal2)
-}
\ No newline at end of file
+}
+
+// ExtremeFeature is MinFeature or MaxFeature
+type ExtremeFeature interface {
+	Feature
+	GetExtreme() *dfedata.InputData
+}
+
+// ExtremeTimeFeature when the extreme of window occurred, values are [timestamp, seconds since],
+// it is computed from MinFeature or MaxFeature, which is updated before, see DependentFeature
+type ExtremeTimeFeature struct {
+	Extreme ExtremeFeature
+	LastValues []decimal.Decimal
+
+	BasicFeature
+}
+
+func (f *ExtremeTimeFeature) New(Extreme ExtremeFeature) *ExtremeTimeFeature {
+	f.Extreme = Extreme
+	f.LastValue = decimal.NewFromInt(0)
+	f.LastValues = []decimal.Decimal{decimal.NewFromInt(0), decimal.NewFromInt(0)}
+	f.WindowSeconds = Extreme.GetWindowSeconds()
+	return f
+}
+
+func (f *ExtremeTimeFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	if extreme := f.Extreme.GetExtreme(); extreme != nil {
+		f.LastValues[0] = decimal.NewFromInt(int64(extreme.Timestamp))
+		f.LastValues[1] = decimal.NewFromInt(0)
+
+		if TimeCurrent > extreme.Timestamp {
+			f.LastValues[1] = decimal.NewFromInt(int64(TimeCurrent - extreme.Timestamp))
+		}
+
+		f.LastValue = f.LastValues[0]
+	}
+
+	f.OnUpdated(TimeCurrent, data)
+}
+
+// GetValues timestamp of extreme and seconds since it
+func (f *ExtremeTimeFeature) GetValues() []decimal.Decimal {
+	return f.LastValues
+}
+
+func (f *ExtremeTimeFeature) GetDependencies() []Feature {
+	return []Feature{f.Extreme}
+}
@@ -94,4 +94,92 @@ func TestMinFeature_GetValue(t *testing.T) {
 			t.Errorf("TestMinFeature_GetValue(%#v): expected %s, actual %s, %d", tt.input, tt.expected, actual, i)
 		}
 	}
-}
\ No newline at end of file
+}
+
+// Ticks without new data must still evict extremes which left the window,
+// data on the window edge are in window like for IsInWindow
+func TestMinMaxFeature_Update_Eviction(t *testing.T) {
+	min, max := (&MinFeature{}).New(10), (&MaxFeature{}).New(10)
+	data := []*dfedata.InputData{
+		{DecimalCost: decimal.NewFromInt(30), Timestamp: 1},
+		{DecimalCost: decimal.NewFromInt(10), Timestamp: 2},
+		{DecimalCost: decimal.NewFromInt(40), Timestamp: 3},
+		{DecimalCost: decimal.NewFromInt(20), Timestamp: 4},
+	}
+
+	var tests = []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expectedMin decimal.Decimal
+		expectedMax decimal.Decimal
+	}{
+		{4, data, decimal.NewFromInt(10), decimal.NewFromInt(40)},
+		{12, nil, decimal.NewFromInt(10), decimal.NewFromInt(40)},
+		{13, nil, decimal.NewFromInt(20), decimal.NewFromInt(40)},
+		{14, nil, decimal.NewFromInt(20), decimal.NewFromInt(20)},
+		// The last data is preserved until new data come
+		{30, nil, decimal.NewFromInt(20), decimal.NewFromInt(20)},
+		{31, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(25), Timestamp: 31}}, decimal.NewFromInt(25), decimal.NewFromInt(25)},
+	}
+
+	for _, tt := range tests {
+		min.Update(tt.TimeCurrent, tt.input)
+		max.Update(tt.TimeCurrent, tt.input)
+
+		if !min.GetValue().Equal(tt.expectedMin) || !max.GetValue().Equal(tt.expectedMax) {
+			t.Errorf("MinMaxFeature.Update(%d) should be %s and %s, got %s and %s",
+				tt.TimeCurrent, tt.expectedMin, tt.expectedMax, min.GetValue(), max.GetValue())
+		}
+	}
+}
+
+func TestExtremeTimeFeature_Update(t *testing.T) {
+	max := (&MaxFeature{}).New(10)
+	min := (&MinFeature{}).New(10)
+	argMax, argMin := (&ExtremeTimeFeature{}).New(max), (&ExtremeTimeFeature{}).New(min)
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		max []int64
+		min []int64
+	}{
+		// Empty window without data before it
+		{2, []*dfedata.InputData {}, []int64 { 0, 0 }, []int64 { 0, 0 }},
+		{5, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(3), Timestamp: 1},
+			{DecimalCost: decimal.NewFromInt(9), Timestamp: 2},
+			{DecimalCost: decimal.NewFromInt(1), Timestamp: 4},
+		}, []int64 { 2, 3 }, []int64 { 4, 1 }},
+		// Repeated max is the latest one
+		{10, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(9), Timestamp: 8},
+		}, []int64 { 8, 2 }, []int64 { 4, 6 }},
+		// 1 left window [5, 15], so minimum is the next one
+		{15, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(5), Timestamp: 14},
+		}, []int64 { 8, 7 }, []int64 { 14, 1 }},
+		// Carried data is both extremes, time since it keeps growing
+		{40, []*dfedata.InputData {}, []int64 { 14, 26 }, []int64 { 14, 26 }},
+		// Carried data is dropped, data with equal timestamps share it
+		{41, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(6), Timestamp: 41},
+			{DecimalCost: decimal.NewFromInt(8), Timestamp: 41},
+			{DecimalCost: decimal.NewFromInt(7), Timestamp: 41},
+		}, []int64 { 41, 0 }, []int64 { 41, 0 }},
+	}
+
+	for _, tick := range ticks {
+		// Dependencies go first, like FeatureEngineer does
+		for _, f := range []Feature{max, min, argMax, argMin} {
+			f.Update(tick.TimeCurrent, tick.input)
+		}
+
+		for i := range tick.max {
+			if !argMax.GetValues()[i].Equal(decimal.NewFromInt(tick.max[i])) || !argMin.GetValues()[i].Equal(decimal.NewFromInt(tick.min[i])) {
+				t.Fatalf("ExtremeTimeFeature.Update(%d) should be %v and %v, got %v and %v",
+					tick.TimeCurrent, tick.max, tick.min, argMax.GetValues(), argMin.GetValues())
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/shopspring/decimal"
+)
+
+// SkewnessFeature population skewness of prices in window, see moments
+type SkewnessFeature struct {
+	moments
+
+	BasicRunningFeature
+}
+
+func (f *SkewnessFeature) New(WindowSeconds uint64, dataStorage storage.InputDataStorage) *SkewnessFeature {
+	f.reset()
+	f.LastValue = decimal.NewFromInt(0)
+	f.WindowSeconds = WindowSeconds
+	f.RunningFeature = f
+	f.DataStorage = dataStorage
+	return f
+}
+
+func (f *SkewnessFeature) InvalidateData(data *dfedata.InputData) {
+	f.remove(data.DecimalCost)
+	f.LastAmount -= 1
+	f.LastValue = f.skewness()
+}
+
+func (f *SkewnessFeature) CalculateData(data *dfedata.InputData) {
+	// Window was reset, while only carried data was there
+	if f.LastAmount == 0 {
+		f.reset()
+	}
+
+	f.add(data.DecimalCost)
+	f.LastAmount += 1
+	f.LastValue = f.skewness()
+}
+
+// KurtosisFeature population excess kurtosis of prices in window, see moments
+type KurtosisFeature struct {
+	moments
+
+	BasicRunningFeature
+}
+
+func (f *KurtosisFeature) New(WindowSeconds uint64, dataStorage storage.InputDataStorage) *KurtosisFeature {
+	f.reset()
+	f.LastValue = decimal.NewFromInt(0)
+	f.WindowSeconds = WindowSeconds
+	f.RunningFeature = f
+	f.DataStorage = dataStorage
+	return f
+}
+
+func (f *KurtosisFeature) InvalidateData(data *dfedata.InputData) {
+	f.remove(data.DecimalCost)
+	f.LastAmount -= 1
+	f.LastValue = f.excessKurtosis()
+}
+
+func (f *KurtosisFeature) CalculateData(data *dfedata.InputData) {
+	// Window was reset, while only carried data was there
+	if f.LastAmount == 0 {
+		f.reset()
+	}
+
+	f.add(data.DecimalCost)
+	f.LastAmount += 1
+	f.LastValue = f.excessKurtosis()
+}
@@ -0,0 +1,111 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/shopspring/decimal"
+	"math"
+	"testing"
+)
+
+// bruteForceMoments sample stddev, population skewness and excess kurtosis of values
+func bruteForceMoments(values []float64) (stdDev float64, skewness float64, kurtosis float64) {
+	n := float64(len(values))
+	mean := 0.0
+
+	for _, value := range values {
+		mean += value / n
+	}
+
+	var m2, m3, m4 float64
+
+	for _, value := range values {
+		delta := value - mean
+		m2 += delta * delta
+		m3 += delta * delta * delta
+		m4 += delta * delta * delta * delta
+	}
+
+	if len(values) < 2 || m2 == 0 {
+		return 0, 0, 0
+	}
+
+	return math.Sqrt(m2 / (n - 1)), math.Sqrt(n) * m3 / math.Pow(m2, 1.5), n * m4 / (m2 * m2) - 3
+}
+
+func TestMomentsFeatures_Update_Table(t *testing.T) {
+	skewness := (&SkewnessFeature{}).New(10, &storage.LinkedListDataStorage{})
+	kurtosis := (&KurtosisFeature{}).New(10, &storage.LinkedListDataStorage{})
+
+	var tests = []struct {
+		input []*dfedata.InputData
+		TimeCurrent uint64
+		skewness float64
+		kurtosis float64
+	}{
+		// Single value has no deviations
+		{[]*dfedata.InputData{{DecimalCost: decimal.NewFromInt(1), Timestamp: 1}}, 1, 0, 0},
+		// Data with equal timestamps are all in window, deviations of 1, 1, 4 are -1, -1, 2
+		{[]*dfedata.InputData{{DecimalCost: decimal.NewFromInt(1), Timestamp: 2}, {DecimalCost: decimal.NewFromInt(4), Timestamp: 2}},
+			2, 1 / math.Sqrt(2), -1.5},
+		// 1 of timestamp 1 left window, 1 and 4 are symmetric
+		{[]*dfedata.InputData{}, 12, 0, -2},
+		// Empty window carries the last value only
+		{[]*dfedata.InputData{}, 30, 0, 0},
+		// Carried value is dropped, deviations of 2, 3, 7 are -2, -1, 3
+		{[]*dfedata.InputData{
+			{DecimalCost: decimal.NewFromInt(2), Timestamp: 31},
+			{DecimalCost: decimal.NewFromInt(3), Timestamp: 31},
+			{DecimalCost: decimal.NewFromInt(7), Timestamp: 31},
+		}, 31, math.Sqrt(3) * 18 / math.Pow(14, 1.5), -1.5},
+	}
+
+	for _, tt := range tests {
+		skewness.Update(tt.TimeCurrent, tt.input)
+		kurtosis.Update(tt.TimeCurrent, tt.input)
+
+		if math.Abs(skewness.GetValue().InexactFloat64() - tt.skewness) > 1e-9 {
+			t.Errorf("SkewnessFeature.Update(%d) should be %g, got %s", tt.TimeCurrent, tt.skewness, skewness.GetValue())
+		}
+
+		if math.Abs(kurtosis.GetValue().InexactFloat64() - tt.kurtosis) > 1e-9 {
+			t.Errorf("KurtosisFeature.Update(%d) should be %g, got %s", tt.TimeCurrent, tt.kurtosis, kurtosis.GetValue())
+		}
+	}
+}
+
+// TestMomentsFeatures_Update every window is updated with add and remove for many ticks, so errors would accumulate
+func TestMomentsFeatures_Update(t *testing.T) {
+	data := bootstrapRandomWalk(7, 1, 3600)
+
+	for _, WindowSeconds := range []uint64{5, 60, 300} {
+		skewness := (&SkewnessFeature{}).New(WindowSeconds, &storage.LinkedListDataStorage{})
+		kurtosis := (&KurtosisFeature{}).New(WindowSeconds, &storage.LinkedListDataStorage{})
+
+		replayTicks([]Feature{skewness, kurtosis}, data, 3600, func(TimeCurrent uint64, seen []*dfedata.InputData) {
+			values := bruteForceWindow(seen, TimeCurrent, WindowSeconds)
+
+			// Empty window carries the last value, it is covered by TestMomentsFeatures_Update_Table
+			if len(values) == 0 {
+				return
+			}
+
+			_, expectedSkewness, expectedKurtosis := bruteForceMoments(values)
+
+			tests := []struct {
+				name string
+				feature Feature
+				expected float64
+			}{
+				{"SkewnessFeature", skewness, expectedSkewness},
+				{"KurtosisFeature", kurtosis, expectedKurtosis},
+			}
+
+			for _, tt := range tests {
+				if math.Abs(tt.feature.GetValue().InexactFloat64() - tt.expected) > 1e-6 * math.Max(1, math.Abs(tt.expected)) {
+					t.Fatalf("%s.Update(%d, %d) should be %g, got %s", tt.name, WindowSeconds, TimeCurrent, tt.expected, tt.feature.GetValue())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"github.com/gammazero/deque"
+	"github.com/shopspring/decimal"
+	"math"
+)
+
+// PairFeature is computed from two streams, like beta of ETH to BTC, StreamEngine feeds it with data of both keys
+// every tick, data of a key may be empty
+type PairFeature interface {
+	UpdatePair(TimeCurrent uint64, first []*dfedata.InputData, second []*dfedata.InputData)
+	GetValues() []decimal.Decimal
+	GetWindowSeconds() uint64
+}
+
+// coMomentsPrecision returns between ticks are tiny and their products are tinier, so default precision is not enough
+const coMomentsPrecision = 32
+
+// coMoments keeps means, M2 of both values and co-moment of pairs, pairs are added with bivariate Welford's update
+// and removed with its exact inverse, like moments
+type coMoments struct {
+	n int64
+	meanX decimal.Decimal
+	meanY decimal.Decimal
+	m2X decimal.Decimal
+	m2Y decimal.Decimal
+	cXY decimal.Decimal
+}
+
+func (m *coMoments) reset() {
+	m.n = 0
+	m.meanX, m.meanY = decimal.Zero, decimal.Zero
+	m.m2X, m.m2Y, m.cXY = decimal.Zero, decimal.Zero, decimal.Zero
+}
+
+func (m *coMoments) add(x decimal.Decimal, y decimal.Decimal) {
+	m.n++
+	n := decimal.NewFromInt(m.n)
+	deltaX := x.Sub(m.meanX)
+	deltaY := y.Sub(m.meanY)
+
+	m.meanX = m.meanX.Add(deltaX.DivRound(n, coMomentsPrecision))
+	m.meanY = m.meanY.Add(deltaY.DivRound(n, coMomentsPrecision))
+	// Co-moment takes delta to the previous mean of one value and to the new mean of another
+	m.m2X = m.m2X.Add(deltaX.Mul(x.Sub(m.meanX)))
+	m.m2Y = m.m2Y.Add(deltaY.Mul(y.Sub(m.meanY)))
+	m.cXY = m.cXY.Add(deltaX.Mul(y.Sub(m.meanY)))
+	m.round()
+}
+
+// remove is add backwards: pair is added to the set without it, which gives the current one
+func (m *coMoments) remove(x decimal.Decimal, y decimal.Decimal) {
+	if m.n <= 1 {
+		m.reset()
+		return
+	}
+
+	n := decimal.NewFromInt(m.n)
+	previous := decimal.NewFromInt(m.n - 1)
+	meanX := m.meanX.Mul(n).Sub(x).DivRound(previous, coMomentsPrecision)
+	meanY := m.meanY.Mul(n).Sub(y).DivRound(previous, coMomentsPrecision)
+
+	m.m2X = m.m2X.Sub(x.Sub(meanX).Mul(x.Sub(m.meanX)))
+	m.m2Y = m.m2Y.Sub(y.Sub(meanY).Mul(y.Sub(m.meanY)))
+	m.cXY = m.cXY.Sub(x.Sub(meanX).Mul(y.Sub(m.meanY)))
+	m.meanX, m.meanY = meanX, meanY
+	m.n--
+	m.round()
+}
+
+func (m *coMoments) round() {
+	m.meanX, m.meanY = m.meanX.Round(coMomentsPrecision), m.meanY.Round(coMomentsPrecision)
+	m.m2X, m.m2Y, m.cXY = m.m2X.Round(coMomentsPrecision), m.m2Y.Round(coMomentsPrecision), m.cXY.Round(coMomentsPrecision)
+}
+
+type pairSample struct {
+	timestamp uint64
+	x decimal.Decimal
+	y decimal.Decimal
+}
+
+// CrossMomentsFeature rolling covariance, correlation and beta of the first stream to the second, values are
+// [covariance, correlation, beta]. Streams are aligned on the tick grid: on every tick both streams have the last price,
+// carried forward when there were no data, and log returns of these prices between ticks are the pairs.
+// Pair of tick T is return over the tick ending at T, so window holds pairs of ticks in (T - WindowSeconds, T]
+type CrossMomentsFeature struct {
+	lastFirst decimal.Decimal
+	lastSecond decimal.Decimal
+	// previous prices of the previous tick, pair needs prices of both streams on both ticks
+	previousFirst decimal.Decimal
+	previousSecond decimal.Decimal
+
+	samples deque.Deque
+	coMoments
+
+	LastValues []decimal.Decimal
+	WindowSeconds uint64
+}
+
+func (f *CrossMomentsFeature) New(WindowSeconds uint64) *CrossMomentsFeature {
+	f.WindowSeconds = WindowSeconds
+	f.lastFirst, f.lastSecond = decimal.Zero, decimal.Zero
+	f.previousFirst, f.previousSecond = decimal.Zero, decimal.Zero
+	f.reset()
+	f.LastValues = []decimal.Decimal{decimal.NewFromInt(0), decimal.NewFromInt(0), decimal.NewFromInt(0)}
+	return f
+}
+
+func (f *CrossMomentsFeature) UpdatePair(TimeCurrent uint64, first []*dfedata.InputData, second []*dfedata.InputData) {
+	if last := lastInWindow(TimeCurrent, f.WindowSeconds, first); last != nil && last.DecimalCost.IsPositive() {
+		f.lastFirst = last.DecimalCost
+	}
+
+	if last := lastInWindow(TimeCurrent, f.WindowSeconds, second); last != nil && last.DecimalCost.IsPositive() {
+		f.lastSecond = last.DecimalCost
+	}
+
+	if f.previousFirst.IsPositive() && f.previousSecond.IsPositive() {
+		sample := pairSample{
+			timestamp: TimeCurrent,
+			x: decimal.NewFromFloat(math.Log(f.lastFirst.Div(f.previousFirst).InexactFloat64())),
+			y: decimal.NewFromFloat(math.Log(f.lastSecond.Div(f.previousSecond).InexactFloat64())),
+		}
+
+		f.samples.PushBack(sample)
+		f.add(sample.x, sample.y)
+	}
+
+	f.previousFirst, f.previousSecond = f.lastFirst, f.lastSecond
+
+	for f.samples.Len() > 0 && f.samples.Front().(pairSample).timestamp + f.WindowSeconds <= TimeCurrent {
+		sample := f.samples.PopFront().(pairSample)
+		f.remove(sample.x, sample.y)
+	}
+
+	f.calculateValues()
+}
+
+func (f *CrossMomentsFeature) calculateValues() {
+	if f.n < 2 {
+		f.LastValues[0], f.LastValues[1], f.LastValues[2] = decimal.Zero, decimal.Zero, decimal.Zero
+		return
+	}
+
+	f.LastValues[0] = f.cXY.DivRound(decimal.NewFromInt(f.n - 1), coMomentsPrecision)
+	f.LastValues[1], f.LastValues[2] = decimal.Zero, decimal.Zero
+
+	// Correlation and beta are not defined for a stream without moves
+	if f.m2X.IsPositive() && f.m2Y.IsPositive() {
+		f.LastValues[1] = decimal.NewFromFloat(f.cXY.InexactFloat64() / math.Sqrt(f.m2X.InexactFloat64() * f.m2Y.InexactFloat64()))
+	}
+
+	if f.m2Y.IsPositive() {
+		f.LastValues[2] = f.cXY.DivRound(f.m2Y, coMomentsPrecision).Round(int32(decimal.DivisionPrecision))
+	}
+}
+
+// GetValues covariance, correlation and beta
+func (f *CrossMomentsFeature) GetValues() []decimal.Decimal {
+	return f.LastValues
+}
+
+func (f *CrossMomentsFeature) GetWindowSeconds() uint64 {
+	return f.WindowSeconds
+}
+
+// GetAmount amount of pairs in window
+func (f *CrossMomentsFeature) GetAmount() uint64 {
+	return uint64(f.samples.Len())
+}
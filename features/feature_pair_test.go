@@ -0,0 +1,159 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"github.com/shopspring/decimal"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// bruteForceCrossMoments sample covariance, correlation and beta of x to y
+func bruteForceCrossMoments(x []float64, y []float64) (covariance float64, correlation float64, beta float64) {
+	n := float64(len(x))
+	var meanX, meanY float64
+
+	for i := range x {
+		meanX += x[i] / n
+		meanY += y[i] / n
+	}
+
+	var cXY, m2X, m2Y float64
+
+	for i := range x {
+		cXY += (x[i] - meanX) * (y[i] - meanY)
+		m2X += (x[i] - meanX) * (x[i] - meanX)
+		m2Y += (y[i] - meanY) * (y[i] - meanY)
+	}
+
+	return cXY / (n - 1), cXY / math.Sqrt(m2X * m2Y), cXY / m2Y
+}
+
+// bootstrapFollower prices following leader at a twentieth of its price with noise of their own,
+// every fourth data of leader on average has no follower data, so some ticks of follower are empty
+func bootstrapFollower(seed int64, leader []*dfedata.InputData) []*dfedata.InputData {
+	random := rand.New(rand.NewSource(seed))
+	noise := int64(0)
+	var result []*dfedata.InputData
+
+	for _, log := range leader {
+		noise += random.Int63n(5) - 2
+
+		if random.Intn(4) == 0 {
+			continue
+		}
+
+		price := log.DecimalCost.Shift(2).IntPart() / 20 + noise
+		result = append(result, &dfedata.InputData{DecimalCost: decimal.New(price, -2), Timestamp: log.Timestamp})
+	}
+
+	return result
+}
+
+func TestCrossMomentsFeature_UpdatePair(t *testing.T) {
+	// ETH follows BTC with noise of its own, some ticks of ETH are empty
+	first := bootstrapRandomWalk(11, 1, 1800)
+	second := bootstrapFollower(12, first)
+	const WindowSeconds = 300
+
+	f := (&CrossMomentsFeature{}).New(WindowSeconds)
+	nextFirst, nextSecond := 0, 0
+	var pricesFirst, pricesSecond []float64
+	var returnsFirst, returnsSecond []float64
+
+	for TimeCurrent := uint64(5); TimeCurrent < 1800; TimeCurrent += 5 {
+		fromFirst, fromSecond := nextFirst, nextSecond
+
+		for nextFirst < len(first) && first[nextFirst].Timestamp <= TimeCurrent {
+			nextFirst++
+		}
+
+		for nextSecond < len(second) && second[nextSecond].Timestamp <= TimeCurrent {
+			nextSecond++
+		}
+
+		f.UpdatePair(TimeCurrent, first[fromFirst:nextFirst], second[fromSecond:nextSecond])
+
+		if nextFirst == 0 || nextSecond == 0 {
+			continue
+		}
+
+		// Prices on tick grid are carried forward
+		pricesFirst = append(pricesFirst, first[nextFirst-1].DecimalCost.InexactFloat64())
+		pricesSecond = append(pricesSecond, second[nextSecond-1].DecimalCost.InexactFloat64())
+
+		if len(pricesFirst) > 1 {
+			returnsFirst = append(returnsFirst, math.Log(pricesFirst[len(pricesFirst)-1] / pricesFirst[len(pricesFirst)-2]))
+			returnsSecond = append(returnsSecond, math.Log(pricesSecond[len(pricesSecond)-1] / pricesSecond[len(pricesSecond)-2]))
+		}
+
+		from := len(returnsFirst) - WindowSeconds / 5
+
+		if from < 0 {
+			from = 0
+		}
+
+		if len(returnsFirst) - from < 2 {
+			continue
+		}
+
+		covariance, correlation, beta := bruteForceCrossMoments(returnsFirst[from:], returnsSecond[from:])
+		values := f.GetValues()
+
+		if f.GetAmount() != uint64(len(returnsFirst) - from) {
+			t.Fatalf("CrossMomentsFeature.UpdatePair(%d) amount should be %d, got %d", TimeCurrent, len(returnsFirst) - from, f.GetAmount())
+		}
+
+		if math.Abs(values[0].InexactFloat64() - covariance) > 1e-12 || math.Abs(values[1].InexactFloat64() - correlation) > 1e-6 ||
+			math.Abs(values[2].InexactFloat64() - beta) > 1e-6 {
+			t.Fatalf("CrossMomentsFeature.UpdatePair(%d) should be [%g %g %g], got %v", TimeCurrent, covariance, correlation, beta, values)
+		}
+	}
+
+	// Streams are correlated, so the case above doesn't pass only on values near 0
+	if correlation := f.GetValues()[1].InexactFloat64(); correlation < 0.5 {
+		t.Errorf("CrossMomentsFeature.UpdatePair correlation of following streams should be above 0.5, got %g", correlation)
+	}
+}
+
+func TestCrossMomentsFeature_UpdatePair_Table(t *testing.T) {
+	f := (&CrossMomentsFeature{}).New(15)
+	price := func(value int64, timestamp uint64) *dfedata.InputData {
+		return &dfedata.InputData{DecimalCost: decimal.NewFromInt(value), Timestamp: timestamp}
+	}
+	a := math.Ln2
+
+	var tests = []struct {
+		TimeCurrent uint64
+		first []*dfedata.InputData
+		second []*dfedata.InputData
+		expected []float64
+		amount uint64
+	}{
+		// The first prices have no returns
+		{5, []*dfedata.InputData{price(100, 5)}, []*dfedata.InputData{price(100, 5)}, []float64{0, 0, 0}, 0},
+		// Single return pair has no deviations
+		{10, []*dfedata.InputData{price(400, 10)}, []*dfedata.InputData{price(200, 10)}, []float64{0, 0, 0}, 1},
+		// The first stream moves twice as much as the second one in the same direction, returns are 2a, -2a and a, -a
+		{15, []*dfedata.InputData{price(100, 15)}, []*dfedata.InputData{price(100, 15)}, []float64{4 * a * a, 1, 2}, 2},
+		// No data, both prices are carried, their returns are 0
+		{20, nil, nil, []float64{2 * a * a, 1, 2}, 3},
+		// Returns of 10 left window, only the last of equal timestamps is the price, returns are -2a, 0, a and -a, 0, 0
+		{25, []*dfedata.InputData{price(100, 25), price(200, 25)}, nil, []float64{5 * a * a / 6, 5 / math.Sqrt(28), 2.5}, 3},
+	}
+
+	for _, tt := range tests {
+		f.UpdatePair(tt.TimeCurrent, tt.first, tt.second)
+
+		for i, expected := range tt.expected {
+			if math.Abs(f.GetValues()[i].InexactFloat64() - expected) > 1e-9 {
+				t.Errorf("CrossMomentsFeature.UpdatePair(%d) should be %v, got %v", tt.TimeCurrent, tt.expected, f.GetValues())
+				break
+			}
+		}
+
+		if f.GetAmount() != tt.amount {
+			t.Errorf("CrossMomentsFeature.UpdatePair(%d) amount should be %d, got %d", tt.TimeCurrent, tt.amount, f.GetAmount())
+		}
+	}
+}
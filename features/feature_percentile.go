@@ -0,0 +1,166 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/shopspring/decimal"
+)
+
+// PercentileFeature calculates exact percentiles of prices in window, data of window are kept sorted
+// in indexable skip list, so insert, eviction and lookup of any rank are O(log N). Window itself is in DataStorage
+// of BasicRunningFeature, LinkedListDataStorage clones its list on every Append, so with it tick is still O(N)
+// Percentiles are interpolated linearly between closest ranks, 0.5 is the median, they are looked up once per tick
+type PercentileFeature struct {
+	Percentiles []float64
+	LastValues []decimal.Decimal
+
+	sorted *storage.IndexableSkipList
+
+	BasicRunningFeature
+}
+
+func (f *PercentileFeature) New(WindowSeconds uint64, dataStorage storage.InputDataStorage, Percentiles ...float64) *PercentileFeature {
+	f.DataStorage = dataStorage
+	f.LastValue = decimal.NewFromInt(0)
+	f.Percentiles = append([]float64{}, Percentiles...)
+	f.LastValues = make([]decimal.Decimal, len(Percentiles))
+	f.sorted = (&storage.IndexableSkipList{}).New()
+	f.RunningFeature = f
+	f.WindowSeconds = WindowSeconds
+	return f
+}
+
+func (f *PercentileFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	f.updateWindow(TimeCurrent, data)
+	f.calculateValues()
+	f.OnUpdated(TimeCurrent, data)
+}
+
+func (f *PercentileFeature) InvalidateData(data *dfedata.InputData) {
+	f.sorted.Remove(data)
+	f.LastAmount -= 1
+}
+
+func (f *PercentileFeature) CalculateData(data *dfedata.InputData) {
+	// Window was reset, while only carried data was there
+	if f.LastAmount == 0 {
+		f.sorted = (&storage.IndexableSkipList{}).New()
+	}
+
+	f.sorted.Insert(data)
+	f.LastAmount += 1
+}
+
+func (f *PercentileFeature) calculateValues() {
+	if f.sorted.Len() == 0 {
+		return
+	}
+
+	for i, percentile := range f.Percentiles {
+		f.LastValues[i] = f.GetPercentile(percentile)
+	}
+
+	if len(f.LastValues) > 0 {
+		f.LastValue = f.LastValues[0]
+	}
+}
+
+// GetPercentile any percentile of current window, not only configured ones, zero when window is empty
+func (f *PercentileFeature) GetPercentile(percentile float64) decimal.Decimal {
+	if f.sorted.Len() == 0 {
+		return decimal.Zero
+	}
+
+	if percentile <= 0 {
+		return f.sorted.Get(0).DecimalCost
+	}
+
+	if percentile >= 1 {
+		return f.sorted.Get(f.sorted.Len() - 1).DecimalCost
+	}
+
+	position := decimal.NewFromFloat(percentile).Mul(decimal.NewFromInt(int64(f.sorted.Len() - 1)))
+	index := position.IntPart()
+	lower := f.sorted.Get(int(index)).DecimalCost
+
+	if int(index) + 1 >= f.sorted.Len() {
+		return lower
+	}
+
+	upper := f.sorted.Get(int(index) + 1).DecimalCost
+
+	return lower.Add(upper.Sub(lower).Mul(position.Sub(decimal.NewFromInt(index))))
+}
+
+// GetValues values in order of Percentiles
+func (f *PercentileFeature) GetValues() []decimal.Decimal {
+	return f.LastValues
+}
+
+// GetMedianAbsoluteDeviation exact median of absolute deviations from median of window, zero when window is empty.
+// Deviations of data below median and of the rest are two sorted sequences read from the skip list by rank,
+// so their k-th smallest is found with binary search in O(log² N), without building deviations
+func (f *PercentileFeature) GetMedianAbsoluteDeviation() decimal.Decimal {
+	n := f.sorted.Len()
+
+	if n == 0 {
+		return decimal.Zero
+	}
+
+	median := f.GetPercentile(0.5)
+	below := f.sorted.CountLess(median)
+
+	// Deviations grow away from median on both sides
+	left := func(i int) decimal.Decimal {
+		return median.Sub(f.sorted.Get(below - 1 - i).DecimalCost)
+	}
+	right := func(i int) decimal.Decimal {
+		return f.sorted.Get(below + i).DecimalCost.Sub(median)
+	}
+
+	if n % 2 == 1 {
+		return kthOfTwoSorted(n / 2, below, n - below, left, right)
+	}
+
+	lower := kthOfTwoSorted(n / 2 - 1, below, n - below, left, right)
+	upper := kthOfTwoSorted(n / 2, below, n - below, left, right)
+
+	return lower.Add(upper).Div(decimal.NewFromInt(2))
+}
+
+// kthOfTwoSorted k-th smallest (from 0) of two sorted sequences, it searches how many of k + 1 smallest are from the first one
+func kthOfTwoSorted(k int, lengthA int, lengthB int, a func(int) decimal.Decimal, b func(int) decimal.Decimal) decimal.Decimal {
+	from, to := k + 1 - lengthB, k + 1
+
+	if from < 0 {
+		from = 0
+	}
+
+	if to > lengthA {
+		to = lengthA
+	}
+
+	for {
+		taken := (from + to) / 2
+		takenB := k + 1 - taken
+
+		switch {
+		// Taken too many from the first one
+		case taken > 0 && takenB < lengthB && a(taken - 1).GreaterThan(b(takenB)):
+			to = taken - 1
+		// Taken too few from the first one
+		case takenB > 0 && taken < lengthA && b(takenB - 1).GreaterThan(a(taken)):
+			from = taken + 1
+		default:
+			if taken == 0 {
+				return b(takenB - 1)
+			}
+
+			if takenB == 0 {
+				return a(taken - 1)
+			}
+
+			return decimal.Max(a(taken - 1), b(takenB - 1))
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/shopspring/decimal"
+	"math"
+	"testing"
+)
+
+// bruteForcePercentile linear interpolation between closest ranks of sorted values
+func bruteForcePercentile(sorted []float64, percentile float64) float64 {
+	position := percentile * float64(len(sorted) - 1)
+	index := int(math.Floor(position))
+
+	if index + 1 >= len(sorted) {
+		return sorted[index]
+	}
+
+	return sorted[index] + (sorted[index+1] - sorted[index]) * (position - float64(index))
+}
+
+func TestPercentileFeature_Update(t *testing.T) {
+	percentiles := []float64{0.5, 0.05, 0.95, 0, 1}
+	data := bootstrapRandomWalk(2, 1, 1800)
+
+	for _, WindowSeconds := range []uint64{5, 30, 300} {
+		f := (&PercentileFeature{}).New(WindowSeconds, &storage.LinkedListDataStorage{}, percentiles...)
+
+		replayTicks([]Feature{f}, data, 1800, func(TimeCurrent uint64, seen []*dfedata.InputData) {
+			exact := bruteForceWindow(seen, TimeCurrent, WindowSeconds)
+
+			// Empty window carries the last data, it is covered by the carry test
+			if len(exact) == 0 {
+				return
+			}
+
+			if f.GetAmount() != uint64(len(exact)) {
+				t.Fatalf("PercentileFeature_Update(%d, %d) amount should be %d, got %d", WindowSeconds, TimeCurrent, len(exact), f.GetAmount())
+			}
+
+			for i, percentile := range percentiles {
+				expected := bruteForcePercentile(exact, percentile)
+
+				if math.Abs(f.GetValues()[i].InexactFloat64() - expected) > 1e-9 {
+					t.Fatalf("PercentileFeature_Update(%d, %d) percentile %f should be %f, got %s",
+						WindowSeconds, TimeCurrent, percentile, expected, f.GetValues()[i])
+				}
+			}
+
+			if !f.GetValue().Equal(f.GetValues()[0]) {
+				t.Fatalf("PercentileFeature_Update GetValue should be the first percentile")
+			}
+		})
+	}
+}
+
+func TestPercentileFeature_Update_Carry(t *testing.T) {
+	f := (&PercentileFeature{}).New(5, &storage.LinkedListDataStorage{}, 0.5)
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expected decimal.Decimal
+		amount uint64
+	}{
+		// Empty window without data before it
+		{2, []*dfedata.InputData {}, decimal.Zero, 0},
+		{5, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(3), Timestamp: 3},
+			{DecimalCost: decimal.NewFromInt(1), Timestamp: 4},
+			{DecimalCost: decimal.NewFromInt(2), Timestamp: 5},
+		}, decimal.NewFromInt(2), 3},
+		{10, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(10), Timestamp: 10},
+		}, decimal.NewFromFloat(6), 2},
+		// Nothing new, only the last data are preserved
+		{20, []*dfedata.InputData {}, decimal.NewFromInt(10), 1},
+		// New data replace preserved one
+		{25, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(7), Timestamp: 25},
+			{DecimalCost: decimal.NewFromInt(8), Timestamp: 25},
+		}, decimal.NewFromFloat(7.5), 2},
+		// Equal values are kept apart, median of 7 7 8 is 7
+		{26, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(7), Timestamp: 26},
+		}, decimal.NewFromInt(7), 3},
+		{30, []*dfedata.InputData {}, decimal.NewFromInt(7), 3},
+		// Data of equal timestamps leave window together, one of equal values stays
+		{31, []*dfedata.InputData {}, decimal.NewFromInt(7), 1},
+	}
+
+	for _, tick := range ticks {
+		f.Update(tick.TimeCurrent, tick.input)
+
+		if !f.GetValue().Equal(tick.expected) || f.GetAmount() != tick.amount {
+			t.Errorf("PercentileFeature_Update(%d) should be %s of %d, got %s of %d",
+				tick.TimeCurrent, tick.expected, tick.amount, f.GetValue(), f.GetAmount())
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/sketch"
+	"fmt"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultQuantiles p05, median and p95
+var DefaultQuantiles = []float64{0.05, 0.5, 0.95}
+
+// QuantileFeature calculates approximate quantiles in window with t-digest, values are Quantiles followed by IQR.
+// Window is split into panes of PaneSeconds, every pane has its own digest and window digest is merge of panes,
+// so eviction is dropping the oldest pane, which digests can't do for a single value.
+// Finished panes are kept in slidingAggregate, thus every one of them is merged amortized O(1) times, but the current
+// pane is merged into a clone of their aggregate on every tick, so tick costs one clone and merge of a window digest.
+// Window edge is precise to PaneSeconds, so it should divide tick and window sizes, PaneSeconds which is 0
+// or doesn't divide window is returned by GetError and feature is not updated
+type QuantileFeature struct {
+	Quantiles []float64
+	PaneSeconds uint64
+	Compression float64
+
+	// panes are finished panes, their starts are kept in the same order
+	panes slidingAggregate
+	paneStarts []uint64
+	// currentPane is not finished yet, it is merged with panes on every update
+	currentPane *sketch.TDigest
+	currentPaneStart uint64
+
+	lastData *dfedata.InputData
+	windowDigest *sketch.TDigest
+	LastValues []decimal.Decimal
+	err error
+
+	BasicFeature
+}
+
+func (f *QuantileFeature) New(WindowSeconds uint64, PaneSeconds uint64, Compression float64, Quantiles ...float64) *QuantileFeature {
+	f.WindowSeconds = WindowSeconds
+	f.PaneSeconds = PaneSeconds
+	f.Compression = Compression
+	f.Quantiles = Quantiles
+	f.panes.combine = mergeDigests
+	f.LastValues = make([]decimal.Decimal, len(Quantiles) + 1)
+	f.LastValue = decimal.NewFromInt(0)
+
+	if err := checkPaneSeconds(WindowSeconds, PaneSeconds); err != nil {
+		f.err = fmt.Errorf("quantile feature: %w", err)
+	}
+
+	return f
+}
+
+func mergeDigests(older interface{}, newer interface{}) interface{} {
+	result := older.(*sketch.TDigest).Clone()
+	result.Merge(newer.(*sketch.TDigest))
+	return result
+}
+
+func (f *QuantileFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	if f.err != nil {
+		return
+	}
+
+	for _, log := range data {
+		if !log.IsInWindow(TimeCurrent, f.WindowSeconds) {
+			continue
+		}
+
+		paneStart := log.Timestamp - log.Timestamp % f.PaneSeconds
+
+		// Late data of an already finished pane are added to the current one, it is precise enough
+		if f.currentPane == nil || paneStart > f.currentPaneStart {
+			f.finishCurrentPane()
+			f.currentPane = (&sketch.TDigest{}).New(f.Compression)
+			f.currentPaneStart = paneStart
+		}
+
+		f.currentPane.Add(log.DecimalCost.InexactFloat64())
+		f.lastData = log
+	}
+
+	// Pane is evicted when all its seconds are before the window
+	windowStart := int64(TimeCurrent) - int64(f.WindowSeconds)
+
+	if f.currentPane != nil && int64(f.currentPaneStart + f.PaneSeconds) <= windowStart {
+		f.finishCurrentPane()
+	}
+
+	for f.panes.Len() > 0 && int64(f.paneStarts[0] + f.PaneSeconds) <= windowStart {
+		f.panes.Pop()
+		f.paneStarts = f.paneStarts[1:]
+	}
+
+	f.calculateValues()
+	f.OnUpdated(TimeCurrent, data)
+}
+
+func (f *QuantileFeature) finishCurrentPane() {
+	if f.currentPane == nil {
+		return
+	}
+
+	f.panes.Push(f.currentPane)
+	f.paneStarts = append(f.paneStarts, f.currentPaneStart)
+	f.currentPane = nil
+}
+
+func (f *QuantileFeature) calculateValues() {
+	if f.lastData == nil {
+		return
+	}
+
+	digest, _ := f.panes.Aggregate().(*sketch.TDigest)
+
+	switch {
+	case digest == nil && f.currentPane == nil:
+		// There is no data in window, by TZ the last data is the only one
+		digest = (&sketch.TDigest{}).New(f.Compression)
+		digest.Add(f.lastData.DecimalCost.InexactFloat64())
+	case digest == nil:
+		digest = f.currentPane
+	case f.currentPane != nil:
+		digest = mergeDigests(digest, f.currentPane).(*sketch.TDigest)
+	}
+
+	f.windowDigest = digest
+
+	for i, q := range f.Quantiles {
+		f.LastValues[i] = decimal.NewFromFloat(digest.Quantile(q))
+	}
+
+	f.LastValues[len(f.Quantiles)] = decimal.NewFromFloat(digest.Quantile(0.75) - digest.Quantile(0.25))
+
+	if len(f.LastValues) > 0 {
+		f.LastValue = f.LastValues[0]
+	}
+}
+
+// GetQuantile returns any quantile of the current window, not only configured ones
+func (f *QuantileFeature) GetQuantile(q float64) decimal.Decimal {
+	if f.windowDigest == nil {
+		return decimal.NewFromInt(0)
+	}
+
+	return decimal.NewFromFloat(f.windowDigest.Quantile(q))
+}
+
+// GetValues returns Quantiles followed by IQR
+func (f *QuantileFeature) GetValues() []decimal.Decimal {
+	return f.LastValues
+}
+
+func (f *QuantileFeature) GetAmount() uint64 {
+	if f.windowDigest == nil {
+		return 0
+	}
+
+	return uint64(f.windowDigest.Count())
+}
+
+func (f *QuantileFeature) GetError() error {
+	return f.err
+}
@@ -0,0 +1,155 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/sketch"
+	"github.com/shopspring/decimal"
+	"sort"
+	"testing"
+)
+
+func TestQuantileFeature_Update(t *testing.T) {
+	data := bootstrapRandomWalk(1, 1, 3600)
+	f := (&QuantileFeature{}).New(300, 5, sketch.DefaultCompression, DefaultQuantiles...)
+
+	replayTicks([]Feature{f}, data, 3600, func(TimeCurrent uint64, seen []*dfedata.InputData) {
+		exact := bruteForceWindow(seen, TimeCurrent, 300)
+
+		if len(exact) == 0 {
+			return
+		}
+
+		for i, q := range DefaultQuantiles {
+			value := f.GetValues()[i].InexactFloat64()
+			rank := float64(sort.SearchFloat64s(exact, value)) / float64(len(exact))
+			rankTo := float64(sort.Search(len(exact), func(j int) bool { return exact[j] > value })) / float64(len(exact))
+
+			if q < rank - 0.02 || q > rankTo + 0.02 {
+				t.Fatalf("QuantileFeature_Update(%d) quantile %f is %f with rank [%f, %f] of %d values",
+					TimeCurrent, q, value, rank, rankTo, len(exact))
+			}
+		}
+
+		if f.GetAmount() != uint64(len(exact)) {
+			t.Fatalf("QuantileFeature_Update(%d) amount should be %d, got %d", TimeCurrent, len(exact), f.GetAmount())
+		}
+	})
+}
+
+func TestQuantileFeature_Update_Carry(t *testing.T) {
+	f := (&QuantileFeature{}).New(30, 5, sketch.DefaultCompression, 0.5)
+
+	f.Update(5, []*dfedata.InputData{
+		{DecimalCost: decimal.NewFromInt(10), Timestamp: 1},
+		{DecimalCost: decimal.NewFromInt(30), Timestamp: 2},
+		{DecimalCost: decimal.NewFromInt(20), Timestamp: 3},
+		{DecimalCost: decimal.NewFromInt(40), Timestamp: 4},
+	})
+
+	// median of 10 20 30 40 and IQR
+	if !f.GetValue().Equal(decimal.NewFromInt(30)) || len(f.GetValues()) != 2 {
+		t.Errorf("QuantileFeature_Update median should be 30, got %v", f.GetValues())
+	}
+
+	// There is no data in window, the last data is the only one
+	f.Update(100, []*dfedata.InputData{})
+
+	if !f.GetValue().Equal(decimal.NewFromInt(40)) || !f.GetValues()[1].Equal(decimal.Zero) {
+		t.Errorf("QuantileFeature_Update should carry the last data, got %v", f.GetValues())
+	}
+}
+
+// TestQuantileFeature_Update_Table digest of few single values is exact, quantile q of n values is the one of rank floor(q * n)
+func TestQuantileFeature_Update_Table(t *testing.T) {
+	f := (&QuantileFeature{}).New(10, 5, sketch.DefaultCompression, 0.5)
+
+	var tests = []struct {
+		input []*dfedata.InputData
+		TimeCurrent uint64
+		median int64
+		iqr int64
+		amount uint64
+	}{
+		// Empty window without data before it
+		{[]*dfedata.InputData{}, 4, 0, 0, 0},
+		// Data with equal timestamps are all in pane [0, 5), IQR is 30 - 10
+		{[]*dfedata.InputData{
+			{DecimalCost: decimal.NewFromInt(10), Timestamp: 3},
+			{DecimalCost: decimal.NewFromInt(30), Timestamp: 3},
+			{DecimalCost: decimal.NewFromInt(20), Timestamp: 3},
+		}, 5, 20, 20, 3},
+		// Median of 10 20 30 60 is of rank 2, IQR is 60 - 20
+		{[]*dfedata.InputData{{DecimalCost: decimal.NewFromInt(60), Timestamp: 11}}, 11, 30, 40, 4},
+		// Pane [0, 5) is in window while any of its seconds is
+		{[]*dfedata.InputData{}, 14, 30, 40, 4},
+		{[]*dfedata.InputData{}, 15, 60, 0, 1},
+		// Empty window carries the last data only
+		{[]*dfedata.InputData{}, 30, 60, 0, 1},
+		// Carried data is dropped
+		{[]*dfedata.InputData{{DecimalCost: decimal.NewFromInt(50), Timestamp: 31}}, 31, 50, 0, 1},
+	}
+
+	for _, tt := range tests {
+		f.Update(tt.TimeCurrent, tt.input)
+
+		if !f.GetValues()[0].Equal(decimal.NewFromInt(tt.median)) || !f.GetValues()[1].Equal(decimal.NewFromInt(tt.iqr)) {
+			t.Errorf("QuantileFeature.Update(%d) should be [%d %d], got %v", tt.TimeCurrent, tt.median, tt.iqr, f.GetValues())
+		}
+
+		if f.GetAmount() != tt.amount {
+			t.Errorf("QuantileFeature.Update(%d) amount should be %d, got %d", tt.TimeCurrent, tt.amount, f.GetAmount())
+		}
+	}
+}
+
+func TestQuantileFeature_New_PaneSeconds(t *testing.T) {
+	for _, test := range []struct {
+		WindowSeconds uint64
+		PaneSeconds uint64
+		fails bool
+	}{
+		{30, 5, false},
+		{30, 30, false},
+		{30, 0, true},
+		{30, 7, true},
+	} {
+		f := (&QuantileFeature{}).New(test.WindowSeconds, test.PaneSeconds, sketch.DefaultCompression, 0.5)
+
+		if (f.GetError() != nil) != test.fails {
+			t.Errorf("QuantileFeature.New(%d, %d) error is %v", test.WindowSeconds, test.PaneSeconds, f.GetError())
+		}
+
+		// Invalid feature is not updated, so it doesn't divide by 0
+		f.Update(5, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(10), Timestamp: 1}})
+	}
+}
+
+func TestSlidingAggregate(t *testing.T) {
+	s := slidingAggregate{combine: func(older interface{}, newer interface{}) interface{} {
+		return older.(string) + newer.(string)
+	}}
+
+	if s.Aggregate() != nil {
+		t.Errorf("slidingAggregate.Aggregate of empty queue should be nil")
+	}
+
+	expected := ""
+
+	for i, value := range []string{"a", "b", "c", "d", "e", "f"} {
+		s.Push(value)
+		expected += value
+
+		// Pop every third, so both stacks are used
+		if i % 3 == 2 {
+			if popped := s.Pop(); popped != string(expected[0]) {
+				t.Errorf("slidingAggregate.Pop should be %s, got %s", string(expected[0]), popped)
+			}
+
+			expected = expected[1:]
+		}
+
+		if s.Aggregate() != expected || s.Len() != len(expected) || s.Front() != string(expected[0]) {
+			t.Errorf("slidingAggregate.Aggregate should be %s, got %s", expected, s.Aggregate())
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/shopspring/decimal"
+)
+
+// LinearRegressionFeature ordinary least squares of price against time in window, values are [slope, intercept, R²].
+// Slope is price per second, intercept is the fitted price at TimeCurrent, so it is comparable between ticks.
+// Fit is kept as running sums which are exact for decimals, so eviction is subtraction and nothing drifts.
+// Slope is 0 when all data have the same timestamp, R² is 0 when it is not defined
+type LinearRegressionFeature struct {
+	sumT decimal.Decimal
+	sumP decimal.Decimal
+	sumTT decimal.Decimal
+	sumTP decimal.Decimal
+	sumPP decimal.Decimal
+
+	LastValues []decimal.Decimal
+
+	BasicRunningFeature
+}
+
+func (f *LinearRegressionFeature) New(WindowSeconds uint64, dataStorage storage.InputDataStorage) *LinearRegressionFeature {
+	f.DataStorage = dataStorage
+	f.LastValue = decimal.NewFromInt(0)
+	f.LastValues = []decimal.Decimal{decimal.NewFromInt(0), decimal.NewFromInt(0), decimal.NewFromInt(0)}
+	f.reset()
+	f.RunningFeature = f
+	f.WindowSeconds = WindowSeconds
+	return f
+}
+
+func (f *LinearRegressionFeature) reset() {
+	f.sumT, f.sumP = decimal.Zero, decimal.Zero
+	f.sumTT, f.sumTP, f.sumPP = decimal.Zero, decimal.Zero, decimal.Zero
+}
+
+func (f *LinearRegressionFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	f.updateWindow(TimeCurrent, data)
+	f.calculateValues(TimeCurrent)
+	f.OnUpdated(TimeCurrent, data)
+}
+
+func (f *LinearRegressionFeature) InvalidateData(data *dfedata.InputData) {
+	t := decimal.NewFromInt(int64(data.Timestamp))
+	f.sumT = f.sumT.Sub(t)
+	f.sumP = f.sumP.Sub(data.DecimalCost)
+	f.sumTT = f.sumTT.Sub(t.Mul(t))
+	f.sumTP = f.sumTP.Sub(t.Mul(data.DecimalCost))
+	f.sumPP = f.sumPP.Sub(data.DecimalCost.Mul(data.DecimalCost))
+	f.LastAmount -= 1
+}
+
+func (f *LinearRegressionFeature) CalculateData(data *dfedata.InputData) {
+	// Window was reset, while only carried data was there
+	if f.LastAmount == 0 {
+		f.reset()
+	}
+
+	t := decimal.NewFromInt(int64(data.Timestamp))
+	f.sumT = f.sumT.Add(t)
+	f.sumP = f.sumP.Add(data.DecimalCost)
+	f.sumTT = f.sumTT.Add(t.Mul(t))
+	f.sumTP = f.sumTP.Add(t.Mul(data.DecimalCost))
+	f.sumPP = f.sumPP.Add(data.DecimalCost.Mul(data.DecimalCost))
+	f.LastAmount += 1
+}
+
+// calculateValues uses sums multiplied by n, which keeps them exact until the final division
+func (f *LinearRegressionFeature) calculateValues(TimeCurrent uint64) {
+	if f.LastAmount == 0 {
+		return
+	}
+
+	n := decimal.NewFromInt(int64(f.LastAmount))
+	sxx := n.Mul(f.sumTT).Sub(f.sumT.Mul(f.sumT))
+	sxy := n.Mul(f.sumTP).Sub(f.sumT.Mul(f.sumP))
+	syy := n.Mul(f.sumPP).Sub(f.sumP.Mul(f.sumP))
+
+	slope, r2 := decimal.Zero, decimal.Zero
+
+	if sxx.IsPositive() {
+		slope = sxy.Div(sxx)
+
+		if syy.IsPositive() {
+			r2 = sxy.Mul(sxy).Div(sxx.Mul(syy))
+		}
+	}
+
+	meanT := f.sumT.Div(n)
+	meanP := f.sumP.Div(n)
+
+	f.LastValues[0] = slope
+	f.LastValues[1] = meanP.Add(slope.Mul(decimal.NewFromInt(int64(TimeCurrent)).Sub(meanT)))
+	f.LastValues[2] = r2
+	f.LastValue = slope
+}
+
+// GetValues slope, intercept and R²
+func (f *LinearRegressionFeature) GetValues() []decimal.Decimal {
+	return f.LastValues
+}
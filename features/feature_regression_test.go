@@ -0,0 +1,132 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/shopspring/decimal"
+	"math"
+	"testing"
+)
+
+func TestLinearRegressionFeature_Update(t *testing.T) {
+	data := bootstrapRandomWalk(13, 1, 1800)
+
+	for _, WindowSeconds := range []uint64{5, 60, 300} {
+		f := (&LinearRegressionFeature{}).New(WindowSeconds, &storage.LinkedListDataStorage{})
+
+		replayTicks([]Feature{f}, data, 1800, func(TimeCurrent uint64, seen []*dfedata.InputData) {
+			var ts, ps []float64
+
+			for _, log := range seen {
+				if log.IsInWindow(TimeCurrent, WindowSeconds) {
+					// Relative to TimeCurrent, so float doesn't lose precision on large timestamps
+					ts = append(ts, float64(log.Timestamp) - float64(TimeCurrent))
+					ps = append(ps, log.DecimalCost.InexactFloat64())
+				}
+			}
+
+			if len(ts) == 0 {
+				return
+			}
+
+			n := float64(len(ts))
+			var meanT, meanP float64
+
+			for i := range ts {
+				meanT += ts[i] / n
+				meanP += ps[i] / n
+			}
+
+			var sxx, sxy, syy float64
+
+			for i := range ts {
+				sxx += (ts[i] - meanT) * (ts[i] - meanT)
+				sxy += (ts[i] - meanT) * (ps[i] - meanP)
+				syy += (ps[i] - meanP) * (ps[i] - meanP)
+			}
+
+			slope, r2 := 0.0, 0.0
+
+			if sxx > 0 {
+				slope = sxy / sxx
+
+				if syy > 0 {
+					r2 = sxy * sxy / (sxx * syy)
+				}
+			}
+
+			expected := []float64{slope, meanP - slope * meanT, r2}
+
+			for i := range expected {
+				if math.Abs(f.GetValues()[i].InexactFloat64() - expected[i]) > 1e-6 {
+					t.Fatalf("LinearRegressionFeature.Update(%d, %d) should be %v, got %v", WindowSeconds, TimeCurrent, expected, f.GetValues())
+				}
+			}
+		})
+	}
+}
+
+func TestLinearRegressionFeature_Update_Table(t *testing.T) {
+	f := (&LinearRegressionFeature{}).New(10, &storage.LinkedListDataStorage{})
+	price := func(value int64, timestamp uint64) *dfedata.InputData {
+		return &dfedata.InputData{DecimalCost: decimal.NewFromInt(value), Timestamp: timestamp}
+	}
+
+	// Intercept is price of the fitted line at TimeCurrent
+	var tests = []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expected []float64
+	}{
+		// Empty window without data before it
+		{1, nil, []float64{0, 0, 0}},
+		// Price grows by 2 every second from 100 at 1
+		{5, []*dfedata.InputData{price(100, 1), price(102, 2), price(104, 3), price(106, 4), price(108, 5)}, []float64{2, 108, 1}},
+		// 106 and 108 are left, the line goes on to 126 at 14
+		{14, nil, []float64{2, 126, 1}},
+		// Only the last data is carried, there is no trend in one point
+		{16, nil, []float64{0, 108, 0}},
+		// Carried data is dropped, data with equal timestamps have no trend either, intercept is their mean
+		{20, []*dfedata.InputData{price(100, 20), price(120, 20)}, []float64{0, 110, 0}},
+		// Deviations of time are -5/3, -5/3, 10/3 and of price -20, 0, 20, so slope is 100 / (50 / 3)
+		// and R² is 100² / (50 / 3 * 800)
+		{25, []*dfedata.InputData{price(140, 25)}, []float64{6, 140, 0.75}},
+	}
+
+	for _, tt := range tests {
+		f.Update(tt.TimeCurrent, tt.input)
+
+		for i, expected := range tt.expected {
+			if math.Abs(f.GetValues()[i].InexactFloat64() - expected) > 1e-9 {
+				t.Errorf("LinearRegressionFeature.Update(%d) should be %v, got %v", tt.TimeCurrent, tt.expected, f.GetValues())
+				break
+			}
+		}
+	}
+}
+
+// chainedValues passes values sent to chained feature to the test
+type chainedValues struct {
+	values chan decimal.Decimal
+
+	BasicFeature
+}
+
+func (f *chainedValues) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	f.values <- (<-connectionChannel[0]).Value
+}
+
+// Chained features must get the slope of this tick, not of the previous one
+func TestLinearRegressionFeature_Update_Chain(t *testing.T) {
+	f := (&LinearRegressionFeature{}).New(10, &storage.LinkedListDataStorage{})
+	var chained Feature = &chainedValues{values: make(chan decimal.Decimal, 1)}
+	f.Chain(&chained)
+
+	for second := int64(1); second <= 5; second++ {
+		f.Update(uint64(second), []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(98 + 2 * second), Timestamp: uint64(second)}})
+
+		if value := <-chained.(*chainedValues).values; !value.Equal(f.GetValue()) {
+			t.Fatalf("LinearRegressionFeature.Update(%d) should send %s to chained features, got %s", second, f.GetValue(), value)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"fmt"
+	"github.com/gammazero/deque"
+	"github.com/shopspring/decimal"
+	"math"
+)
+
+// SecondsPerYear markets we work with trade around the clock
+const SecondsPerYear = 365 * 24 * 3600
+
+type windowedTerm struct {
+	timestamp uint64
+	value decimal.Decimal
+	squared decimal.Decimal
+}
+
+// windowedSums keeps sums of terms (and their squares) with timestamps in window,
+// terms are decimals, so adding and subtracting them doesn't drift.
+// For every lag of Lags it also keeps sum of products of terms that far apart, see autocorrelation
+type windowedSums struct {
+	Lags []int
+
+	terms deque.Deque
+	sum decimal.Decimal
+	sumSquared decimal.Decimal
+	lagSums []decimal.Decimal
+}
+
+func (w *windowedSums) push(timestamp uint64, value float64) {
+	term := windowedTerm{timestamp: timestamp, value: decimal.NewFromFloat(value), squared: decimal.NewFromFloat(value * value)}
+
+	for i, lag := range w.Lags {
+		if w.terms.Len() >= lag {
+			w.lagSums[i] = w.lagSums[i].Add(term.value.Mul(w.at(w.terms.Len() - lag)))
+		}
+	}
+
+	w.terms.PushBack(term)
+	w.sum = w.sum.Add(term.value)
+	w.sumSquared = w.sumSquared.Add(term.squared)
+}
+
+// evict removes terms with timestamps before given one
+func (w *windowedSums) evict(before uint64) {
+	for w.terms.Len() > 0 && w.terms.Front().(windowedTerm).timestamp < before {
+		for i, lag := range w.Lags {
+			if w.terms.Len() > lag {
+				w.lagSums[i] = w.lagSums[i].Sub(w.at(0).Mul(w.at(lag)))
+			}
+		}
+
+		term := w.terms.PopFront().(windowedTerm)
+		w.sum = w.sum.Sub(term.value)
+		w.sumSquared = w.sumSquared.Sub(term.squared)
+	}
+}
+
+func (w *windowedSums) at(i int) decimal.Decimal {
+	return w.terms.At(i).(windowedTerm).value
+}
+
+// TickSampling SamplingSeconds of returns between consecutive data instead of closes of panes
+const TickSampling = math.MaxUint64
+
+// logReturns series of log returns, between consecutive data or between closes of panes of SamplingSeconds
+type logReturns struct {
+	SamplingSeconds uint64
+
+	panes paneBars
+	previous decimal.Decimal
+	returns windowedSums
+}
+
+// reset Lags are passed to windowedSums of returns
+func (l *logReturns) reset(SamplingSeconds uint64, Lags ...int) {
+	l.SamplingSeconds = SamplingSeconds
+	l.panes.PaneSeconds = SamplingSeconds
+
+	// Tick returns have no panes
+	if SamplingSeconds == TickSampling {
+		l.panes.PaneSeconds = 0
+	}
+
+	l.previous = decimal.Zero
+	l.returns.Lags = Lags
+	l.returns.sum = decimal.Zero
+	l.returns.sumSquared = decimal.Zero
+	l.returns.lagSums = make([]decimal.Decimal, len(Lags))
+
+	for i := range l.returns.lagSums {
+		l.returns.lagSums[i] = decimal.Zero
+	}
+}
+
+func (l *logReturns) update(TimeCurrent uint64, WindowSeconds uint64, data []*dfedata.InputData) {
+	for _, log := range data {
+		if !log.IsInWindow(TimeCurrent, WindowSeconds) {
+			continue
+		}
+
+		if l.panes.PaneSeconds == 0 {
+			l.push(log.Timestamp, log.DecimalCost)
+			continue
+		}
+
+		l.panes.add(log, l.onPaneClosed)
+	}
+
+	if l.panes.PaneSeconds > 0 {
+		l.panes.close(TimeCurrent, l.onPaneClosed)
+	}
+
+	l.returns.evict(windowStart(TimeCurrent, WindowSeconds, l.panes.PaneSeconds))
+}
+
+// windowStart timestamp of the first term in window [TimeCurrent - WindowSeconds, TimeCurrent],
+// pane ending at window start holds data before window, so it is not in window
+func windowStart(TimeCurrent uint64, WindowSeconds uint64, PaneSeconds uint64) uint64 {
+	if TimeCurrent < WindowSeconds {
+		return 0
+	}
+
+	if PaneSeconds > 0 {
+		return TimeCurrent - WindowSeconds + 1
+	}
+
+	return TimeCurrent - WindowSeconds
+}
+
+func (l *logReturns) onPaneClosed(bar *Bar, end uint64) {
+	l.push(end, bar.Close)
+}
+
+// push log return is not defined for non positive prices, they are skipped
+func (l *logReturns) push(timestamp uint64, price decimal.Decimal) {
+	if !price.IsPositive() {
+		return
+	}
+
+	if l.previous.IsPositive() {
+		l.returns.push(timestamp, math.Log(price.Div(l.previous).InexactFloat64()))
+	}
+
+	l.previous = price
+}
+
+// LogReturnFeature sum of log returns in window, which is log return from the price before the first return in window
+// to the last price, returns are of ticks when SamplingSeconds is TickSampling, or of closes of SamplingSeconds panes otherwise.
+// SamplingSeconds which is 0 or doesn't divide window is returned by GetError and feature is not updated
+type LogReturnFeature struct {
+	logReturns
+	err error
+
+	BasicFeature
+}
+
+func (f *LogReturnFeature) New(WindowSeconds uint64, SamplingSeconds uint64) *LogReturnFeature {
+	f.reset(SamplingSeconds)
+	f.LastValue = decimal.NewFromInt(0)
+	f.WindowSeconds = WindowSeconds
+
+	if err := checkSamplingSeconds(WindowSeconds, SamplingSeconds); err != nil {
+		f.err = fmt.Errorf("log return feature: %w", err)
+	}
+
+	return f
+}
+
+// checkSamplingSeconds tick sampling has no panes, otherwise sampling is a pane of window
+func checkSamplingSeconds(WindowSeconds uint64, SamplingSeconds uint64) error {
+	if SamplingSeconds == TickSampling {
+		return nil
+	}
+
+	return checkPaneSeconds(WindowSeconds, SamplingSeconds)
+}
+
+func (f *LogReturnFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	if f.err != nil {
+		return
+	}
+
+	f.update(TimeCurrent, f.WindowSeconds, data)
+	f.LastValue = f.returns.sum
+	f.OnUpdated(TimeCurrent, data)
+}
+
+// GetReturns log returns in window from the oldest
+func (f *LogReturnFeature) GetReturns() []decimal.Decimal {
+	result := make([]decimal.Decimal, f.returns.terms.Len())
+
+	for i := range result {
+		result[i] = f.returns.at(i)
+	}
+
+	return result
+}
+
+func (f *LogReturnFeature) GetAmount() uint64 {
+	return uint64(f.returns.terms.Len())
+}
+
+func (f *LogReturnFeature) GetError() error {
+	return f.err
+}
+
+// RealizedVolatilityFeature square root of sum of squared log returns in window, see LogReturnFeature for sampling
+// and its errors. Annualized volatility is scaled from window to year, it is comparable between windows
+type RealizedVolatilityFeature struct {
+	Annualized bool
+
+	logReturns
+	err error
+
+	BasicFeature
+}
+
+func (f *RealizedVolatilityFeature) New(WindowSeconds uint64, SamplingSeconds uint64, Annualized bool) *RealizedVolatilityFeature {
+	f.reset(SamplingSeconds)
+	f.Annualized = Annualized
+	f.LastValue = decimal.NewFromInt(0)
+	f.WindowSeconds = WindowSeconds
+
+	if err := checkSamplingSeconds(WindowSeconds, SamplingSeconds); err != nil {
+		f.err = fmt.Errorf("realized volatility feature: %w", err)
+	}
+
+	return f
+}
+
+func (f *RealizedVolatilityFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	if f.err != nil {
+		return
+	}
+
+	f.update(TimeCurrent, f.WindowSeconds, data)
+	f.LastValue = volatility(f.returns.sumSquared, f.WindowSeconds, f.Annualized)
+	f.OnUpdated(TimeCurrent, data)
+}
+
+func (f *RealizedVolatilityFeature) GetAmount() uint64 {
+	return uint64(f.returns.terms.Len())
+}
+
+func (f *RealizedVolatilityFeature) GetError() error {
+	return f.err
+}
+
+// volatility square root of variance of window, optionally scaled to year
+func volatility(variance decimal.Decimal, WindowSeconds uint64, Annualized bool) decimal.Decimal {
+	value := variance.InexactFloat64()
+
+	if value <= 0 {
+		return decimal.NewFromInt(0)
+	}
+
+	if Annualized && WindowSeconds > 0 {
+		value *= float64(SecondsPerYear) / float64(WindowSeconds)
+	}
+
+	return decimal.NewFromFloat(math.Sqrt(value))
+}
+
+type RangeEstimator uint8
+
+const (
+	// ParkinsonEstimator uses high and low of bar
+	ParkinsonEstimator RangeEstimator = iota
+	// GarmanKlassEstimator uses high and low and also open and close of bar
+	GarmanKlassEstimator
+)
+
+// rangeVariance variance of one bar by estimator
+func (e RangeEstimator) rangeVariance(bar *Bar) float64 {
+	highLow := math.Log(bar.High.Div(bar.Low).InexactFloat64())
+
+	if e == ParkinsonEstimator {
+		return highLow * highLow / (4 * math.Ln2)
+	}
+
+	closeOpen := math.Log(bar.Close.Div(bar.Open).InexactFloat64())
+
+	return 0.5 * highLow * highLow - (2 * math.Ln2 - 1) * closeOpen * closeOpen
+}
+
+// RangeVolatilityFeature range based volatility estimated from bars of PaneSeconds in window,
+// variance of window is sum of variances of its closed bars. Data are merged into bar of the current pane in place
+// (see paneBars) and only one variance per closed bar is stored.
+// PaneSeconds which is 0 or doesn't divide window is returned by GetError and feature is not updated
+type RangeVolatilityFeature struct {
+	Estimator RangeEstimator
+	Annualized bool
+
+	panes paneBars
+	variances windowedSums
+	err error
+
+	BasicFeature
+}
+
+func (f *RangeVolatilityFeature) New(WindowSeconds uint64, PaneSeconds uint64, Estimator RangeEstimator, Annualized bool) *RangeVolatilityFeature {
+	f.panes.PaneSeconds = PaneSeconds
+	f.Estimator = Estimator
+	f.Annualized = Annualized
+	f.variances.sum = decimal.Zero
+	f.variances.sumSquared = decimal.Zero
+	f.LastValue = decimal.NewFromInt(0)
+	f.WindowSeconds = WindowSeconds
+
+	if err := checkPaneSeconds(WindowSeconds, PaneSeconds); err != nil {
+		f.err = fmt.Errorf("range volatility feature: %w", err)
+	}
+
+	return f
+}
+
+func (f *RangeVolatilityFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	if f.err != nil {
+		return
+	}
+
+	for _, log := range data {
+		// Range of non positive prices is not defined
+		if !log.IsInWindow(TimeCurrent, f.WindowSeconds) || !log.DecimalCost.IsPositive() {
+			continue
+		}
+
+		f.panes.add(log, f.onPaneClosed)
+	}
+
+	f.panes.close(TimeCurrent, f.onPaneClosed)
+	f.variances.evict(windowStart(TimeCurrent, f.WindowSeconds, f.panes.PaneSeconds))
+
+	f.LastValue = volatility(f.variances.sum, f.WindowSeconds, f.Annualized)
+	f.OnUpdated(TimeCurrent, data)
+}
+
+func (f *RangeVolatilityFeature) onPaneClosed(bar *Bar, end uint64) {
+	f.variances.push(end, f.Estimator.rangeVariance(bar))
+}
+
+func (f *RangeVolatilityFeature) GetAmount() uint64 {
+	return uint64(f.variances.terms.Len())
+}
+
+func (f *RangeVolatilityFeature) GetError() error {
+	return f.err
+}
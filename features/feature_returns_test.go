@@ -0,0 +1,201 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"github.com/shopspring/decimal"
+	"math"
+	"testing"
+)
+
+// bruteForcePanes bars of non empty panes of data, keyed by pane end, in order
+func bruteForcePanes(data []*dfedata.InputData, PaneSeconds uint64) (bars []Bar, ends []uint64) {
+	for _, log := range data {
+		end := (log.Timestamp + PaneSeconds - 1) / PaneSeconds * PaneSeconds
+
+		if len(ends) > 0 && ends[len(ends) - 1] == end {
+			bars[len(bars) - 1] = *mergeBars(&bars[len(bars) - 1], barOf(log)).(*Bar)
+			continue
+		}
+
+		bars = append(bars, *barOf(log))
+		ends = append(ends, end)
+	}
+
+	return
+}
+
+func TestReturnsFeatures_Update(t *testing.T) {
+	data := bootstrapRandomWalk(6, 1, 1800)
+	const WindowSeconds, PaneSeconds = 300, 5
+
+	tickReturns := (&LogReturnFeature{}).New(WindowSeconds, TickSampling)
+	tickVolatility := (&RealizedVolatilityFeature{}).New(WindowSeconds, TickSampling, false)
+	paneVolatility := (&RealizedVolatilityFeature{}).New(WindowSeconds, PaneSeconds, true)
+	parkinson := (&RangeVolatilityFeature{}).New(WindowSeconds, PaneSeconds, ParkinsonEstimator, false)
+	garmanKlass := (&RangeVolatilityFeature{}).New(WindowSeconds, PaneSeconds, GarmanKlassEstimator, false)
+
+	replayTicks([]Feature{tickReturns, tickVolatility, paneVolatility, parkinson, garmanKlass}, data, 1800, func(TimeCurrent uint64, seen []*dfedata.InputData) {
+		var sum, sumSquared, paneSumSquared, parkinsonSum, garmanKlassSum float64
+
+		for i := 1; i < len(seen); i++ {
+			if seen[i].Timestamp + WindowSeconds >= TimeCurrent {
+				r := math.Log(seen[i].DecimalCost.InexactFloat64() / seen[i-1].DecimalCost.InexactFloat64())
+				sum += r
+				sumSquared += r * r
+			}
+		}
+
+		bars, ends := bruteForcePanes(seen, PaneSeconds)
+
+		for i := range bars {
+			if ends[i] + WindowSeconds <= TimeCurrent {
+				continue
+			}
+
+			highLow := math.Log(bars[i].High.InexactFloat64() / bars[i].Low.InexactFloat64())
+			closeOpen := math.Log(bars[i].Close.InexactFloat64() / bars[i].Open.InexactFloat64())
+			parkinsonSum += highLow * highLow / (4 * math.Ln2)
+			garmanKlassSum += 0.5 * highLow * highLow - (2 * math.Ln2 - 1) * closeOpen * closeOpen
+
+			if i > 0 {
+				r := math.Log(bars[i].Close.InexactFloat64() / bars[i-1].Close.InexactFloat64())
+				paneSumSquared += r * r
+			}
+		}
+
+		tests := []struct {
+			name string
+			feature Feature
+			expected float64
+		}{
+			{"LogReturnFeature", tickReturns, sum},
+			{"RealizedVolatilityFeature", tickVolatility, math.Sqrt(sumSquared)},
+			{"RealizedVolatilityFeature annualized", paneVolatility, math.Sqrt(paneSumSquared * SecondsPerYear / WindowSeconds)},
+			{"RangeVolatilityFeature Parkinson", parkinson, math.Sqrt(parkinsonSum)},
+			{"RangeVolatilityFeature Garman-Klass", garmanKlass, math.Sqrt(math.Max(garmanKlassSum, 0))},
+		}
+
+		for _, tt := range tests {
+			if math.Abs(tt.feature.GetValue().InexactFloat64() - tt.expected) > 1e-9 * math.Max(1, math.Abs(tt.expected)) {
+				t.Fatalf("%s.Update(%d) should be %g, got %s", tt.name, TimeCurrent, tt.expected, tt.feature.GetValue())
+			}
+		}
+	})
+
+	if len(tickReturns.GetReturns()) != int(tickReturns.GetAmount()) {
+		t.Errorf("LogReturnFeature.GetReturns should have %d returns, got %d", tickReturns.GetAmount(), len(tickReturns.GetReturns()))
+	}
+}
+
+func TestReturnsFeatures_Update_Table(t *testing.T) {
+	returns := (&LogReturnFeature{}).New(10, TickSampling)
+	volatility := (&RealizedVolatilityFeature{}).New(10, TickSampling, false)
+
+	var tests = []struct {
+		TimeCurrent uint64
+		data []*dfedata.InputData
+		logReturn float64
+		volatility float64
+	}{
+		// Empty window
+		{5, nil, 0, 0},
+		// First price has no return
+		{10, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(100), Timestamp: 10}}, 0, 0},
+		// Equal timestamps are consecutive returns, ln 2 and -ln 2
+		{12, []*dfedata.InputData{
+			{DecimalCost: decimal.NewFromInt(200), Timestamp: 12},
+			{DecimalCost: decimal.NewFromInt(100), Timestamp: 12},
+		}, 0, math.Sqrt2 * math.Ln2},
+		{22, nil, 0, math.Sqrt2 * math.Ln2},
+		// Returns are evicted, the last price is carried
+		{23, nil, 0, 0},
+		{25, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(400), Timestamp: 25}}, 2 * math.Ln2, 2 * math.Ln2},
+	}
+
+	for _, tt := range tests {
+		returns.Update(tt.TimeCurrent, tt.data)
+		volatility.Update(tt.TimeCurrent, tt.data)
+
+		if math.Abs(returns.GetValue().InexactFloat64() - tt.logReturn) > 1e-9 {
+			t.Errorf("LogReturnFeature at %d is %v, expected %v", tt.TimeCurrent, returns.GetValue(), tt.logReturn)
+		}
+
+		if math.Abs(volatility.GetValue().InexactFloat64() - tt.volatility) > 1e-9 {
+			t.Errorf("RealizedVolatilityFeature at %d is %v, expected %v", tt.TimeCurrent, volatility.GetValue(), tt.volatility)
+		}
+	}
+}
+
+func TestRealizedVolatilityFeature_Update_Sampling(t *testing.T) {
+	f := (&RealizedVolatilityFeature{}).New(10, 5, false)
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expected float64
+	}{
+		{5, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(100), Timestamp: 3},
+		}, 0},
+		// Only closes of panes matter, 50 inside pane is ignored
+		{10, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(50), Timestamp: 6},
+			{DecimalCost: decimal.NewFromInt(200), Timestamp: 10},
+		}, math.Ln2},
+		{15, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(100), Timestamp: 15},
+		}, math.Sqrt(2) * math.Ln2},
+		// Return of pane ending at 10 is out of window (10, 20]
+		{20, []*dfedata.InputData {}, math.Ln2},
+	}
+
+	for _, tick := range ticks {
+		f.Update(tick.TimeCurrent, tick.input)
+
+		if math.Abs(f.GetValue().InexactFloat64() - tick.expected) > 1e-12 {
+			t.Errorf("RealizedVolatilityFeature.Update(%d) should be %g, got %s", tick.TimeCurrent, tick.expected, f.GetValue())
+		}
+	}
+}
+
+func TestRangeVolatilityFeature_New_PaneSeconds(t *testing.T) {
+	for _, PaneSeconds := range []uint64{0, 7} {
+		f := (&RangeVolatilityFeature{}).New(30, PaneSeconds, ParkinsonEstimator, false)
+
+		if f.GetError() == nil {
+			t.Errorf("RangeVolatilityFeature.New(30, %d) should fail", PaneSeconds)
+		}
+
+		// Invalid feature is not updated, so it doesn't divide by 0
+		f.Update(5, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(10), Timestamp: 1}})
+	}
+}
+
+func TestReturnsFeatures_New_SamplingSeconds(t *testing.T) {
+	var tests = []struct {
+		SamplingSeconds uint64
+		fails bool
+	}{
+		{TickSampling, false},
+		{5, false},
+		{0, true},
+		{7, true},
+	}
+
+	for _, tt := range tests {
+		returns := (&LogReturnFeature{}).New(30, tt.SamplingSeconds)
+		volatility := (&RealizedVolatilityFeature{}).New(30, tt.SamplingSeconds, false)
+
+		if (returns.GetError() != nil) != tt.fails {
+			t.Errorf("LogReturnFeature.New(30, %d) error is %v", tt.SamplingSeconds, returns.GetError())
+		}
+
+		if (volatility.GetError() != nil) != tt.fails {
+			t.Errorf("RealizedVolatilityFeature.New(30, %d) error is %v", tt.SamplingSeconds, volatility.GetError())
+		}
+
+		// Invalid feature is not updated
+		returns.Update(5, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(10), Timestamp: 1}})
+		volatility.Update(5, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(10), Timestamp: 1}})
+	}
+}
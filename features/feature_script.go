@@ -0,0 +1,317 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"fmt"
+	"github.com/shopspring/decimal"
+	starlarkmath "go.starlark.net/lib/math"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+	"math"
+)
+
+// ScriptLimits limits of ScriptFeature, script fails when it exceeds any of them, zero limits are replaced by
+// DefaultScriptLimits
+type ScriptLimits struct {
+	// MaxSteps steps of interpreter per tick, it bounds CPU time of tick
+	MaxSteps uint64
+	// MaxTickAllocBytes approximate bytes allocated per tick by operators, slices and calls of builtins. They are
+	// checked before they run, so even a single step like "x" * 100000000 can't exceed it. Other operations allocate
+	// a few words per step at most, so memory of tick is bounded by MaxTickAllocBytes and MaxSteps together
+	MaxTickAllocBytes int
+	// MaxRetainedStateBytes approximate size of state kept between ticks. It is checked after the tick by walking
+	// the state, so it costs O(state) per tick and catches only state growing over ticks
+	MaxRetainedStateBytes int
+}
+
+// DefaultScriptLimits is enough for a few arithmetic operations per data of a busy window
+var DefaultScriptLimits = ScriptLimits{MaxSteps: 10000000, MaxTickAllocBytes: 64 << 20, MaxRetainedStateBytes: 1 << 20}
+
+// withDefaults replaces zero limits by defaults, for Starlark 0 steps is no limit at all
+// and 0 bytes would fail any tick or state
+func (l ScriptLimits) withDefaults() ScriptLimits {
+	if l.MaxSteps == 0 {
+		l.MaxSteps = DefaultScriptLimits.MaxSteps
+	}
+
+	if l.MaxTickAllocBytes <= 0 {
+		l.MaxTickAllocBytes = DefaultScriptLimits.MaxTickAllocBytes
+	}
+
+	if l.MaxRetainedStateBytes <= 0 {
+		l.MaxRetainedStateBytes = DefaultScriptLimits.MaxRetainedStateBytes
+	}
+
+	return l
+}
+
+// ScriptFeature running feature defined by Starlark script, it has the same lifecycle as other running features,
+// so script only defines what happens when data enters and leaves window:
+//
+//   def init():                              # optional, returns new state, default is {}
+//       return {"sum": 0.0, "n": 0}
+//   def calculate(state, price, timestamp):  # data entered window
+//       state["sum"] += price
+//       state["n"] += 1
+//   def invalidate(state, price, timestamp): # data left window
+//       state["sum"] -= price
+//       state["n"] -= 1
+//   def value(state):                        # number, it is read after every tick with data in window
+//       return state["sum"] / state["n"]
+//
+// State is recreated by init when window starts over, like when the carried data is replaced by new ones.
+// Script can use math module and nothing else, it can't load modules, print or reach anything outside.
+// Errors of loading script are returned by GetError and feature is not updated. Errors of tick, including exceeded
+// limits, are returned by GetError until the next tick, which recreates state by init and data in window
+type ScriptFeature struct {
+	Limits ScriptLimits
+
+	globals starlark.StringDict
+	state starlark.Value
+	thread *starlark.Thread
+	guard scriptGuard
+	// loaded script is compiled and has all required functions
+	loaded bool
+	err error
+
+	BasicRunningFeature
+}
+
+// scriptFunctions required functions of script, init is optional
+var scriptFunctions = []string{"calculate", "invalidate", "value"}
+
+func (f *ScriptFeature) New(WindowSeconds uint64, dataStorage storage.InputDataStorage, Source string, Limits ScriptLimits) *ScriptFeature {
+	f.DataStorage = dataStorage
+	f.Limits = Limits.withDefaults()
+	f.LastValue = decimal.NewFromInt(0)
+	f.RunningFeature = f
+	f.WindowSeconds = WindowSeconds
+
+	f.startThread()
+	f.globals, f.err = f.load(Source)
+
+	for _, name := range scriptFunctions {
+		if f.err != nil {
+			return f
+		}
+
+		if _, ok := f.globals[name].(starlark.Callable); !ok {
+			f.err = fmt.Errorf("script feature: function %s is not defined", name)
+		}
+	}
+
+	f.loaded = f.err == nil
+
+	return f
+}
+
+// load compiles script guarded by guard and runs its top level code
+func (f *ScriptFeature) load(Source string) (starlark.StringDict, error) {
+	predeclared := f.guard.builtins()
+	predeclared["math"] = starlarkmath.Module
+
+	file, err := syntax.Parse("feature.star", Source, 0)
+
+	if err == nil {
+		err = guardScript(file)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	program, err := starlark.FileProgram(file, predeclared.Has)
+
+	if err != nil {
+		return nil, err
+	}
+
+	globals, err := program.Init(f.thread, predeclared)
+	globals.Freeze()
+
+	return globals, err
+}
+
+// startThread every tick gets new thread and guard, so limits of steps and allocations are per tick
+func (f *ScriptFeature) startThread() {
+	f.guard.reset(f.Limits.MaxTickAllocBytes)
+
+	f.thread = &starlark.Thread{
+		Name: "feature",
+		Print: func(*starlark.Thread, string) {},
+		Load: func(*starlark.Thread, string) (starlark.StringDict, error) {
+			return nil, fmt.Errorf("load is not allowed")
+		},
+	}
+
+	f.thread.SetMaxExecutionSteps(f.Limits.MaxSteps)
+}
+
+func (f *ScriptFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	if !f.loaded {
+		return
+	}
+
+	// State of failed tick is unknown, calls are skipped while window is updated and state is recreated after that
+	failed := f.err != nil
+
+	f.startThread()
+	f.updateWindow(TimeCurrent, data)
+
+	if failed {
+		f.err = nil
+		f.restart()
+	}
+
+	if f.err == nil && f.LastAmount > 0 {
+		f.LastValue, f.err = f.value()
+	}
+
+	if f.err == nil {
+		if size := scriptValueSize(f.state, map[starlark.Value]bool{}); size > f.Limits.MaxRetainedStateBytes {
+			f.err = fmt.Errorf("script feature: retained state of %d bytes exceeds limit of %d bytes", size, f.Limits.MaxRetainedStateBytes)
+		}
+	}
+
+	f.OnUpdated(TimeCurrent, data)
+}
+
+func (f *ScriptFeature) InvalidateData(data *dfedata.InputData) {
+	f.call("invalidate", f.state, starlark.Float(data.DecimalCost.InexactFloat64()), starlark.MakeUint64(data.Timestamp))
+	f.LastAmount -= 1
+}
+
+func (f *ScriptFeature) CalculateData(data *dfedata.InputData) {
+	// Window starts over, carried data was dropped without invalidation
+	if f.LastAmount == 0 {
+		f.initState()
+	}
+
+	f.call("calculate", f.state, starlark.Float(data.DecimalCost.InexactFloat64()), starlark.MakeUint64(data.Timestamp))
+	f.LastAmount += 1
+}
+
+func (f *ScriptFeature) initState() {
+	f.state = starlark.NewDict(0)
+
+	if _, ok := f.globals["init"].(starlark.Callable); ok {
+		f.state = f.call("init")
+	}
+}
+
+// restart recreates state by init and data in window, including the carried one
+func (f *ScriptFeature) restart() {
+	f.initState()
+
+	for _, data := range f.DataStorage.Iterate()[f.invalidatedAmount:] {
+		f.call("calculate", f.state, starlark.Float(data.DecimalCost.InexactFloat64()), starlark.MakeUint64(data.Timestamp))
+	}
+}
+
+// call calls function of script unless script has already failed
+func (f *ScriptFeature) call(name string, args ...starlark.Value) starlark.Value {
+	if f.err != nil {
+		return starlark.None
+	}
+
+	result, err := starlark.Call(f.thread, f.globals[name], args, nil)
+
+	if err != nil {
+		f.err = fmt.Errorf("script feature: %s: %w", name, err)
+		return starlark.None
+	}
+
+	return result
+}
+
+func (f *ScriptFeature) value() (decimal.Decimal, error) {
+	result := f.call("value", f.state)
+
+	if f.err != nil {
+		return f.LastValue, f.err
+	}
+
+	value, ok := starlark.AsFloat(result)
+
+	if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+		return f.LastValue, fmt.Errorf("script feature: value returned %s, not a finite number", result)
+	}
+
+	return decimal.NewFromFloat(value), nil
+}
+
+func (f *ScriptFeature) GetError() error {
+	return f.err
+}
+
+// scriptValueSize approximate size of value in bytes, containers are counted once, so cycles are fine
+func scriptValueSize(value starlark.Value, seen map[starlark.Value]bool) int {
+	const header = 16
+
+	switch value := value.(type) {
+	case starlark.Int:
+		if _, ok := value.Int64(); !ok {
+			return header + value.BigInt().BitLen() / 8
+		}
+
+		return header
+	case starlark.String:
+		return header + len(value)
+	case starlark.Bytes:
+		return header + len(value)
+	case starlark.Tuple:
+		size := header
+
+		for _, item := range value {
+			size += scriptValueSize(item, seen)
+		}
+
+		return size
+	case *starlark.List:
+		if seen[value] {
+			return 0
+		}
+
+		seen[value] = true
+		size := header
+
+		for i := 0; i < value.Len(); i++ {
+			size += scriptValueSize(value.Index(i), seen)
+		}
+
+		return size
+	case *starlark.Dict:
+		if seen[value] {
+			return 0
+		}
+
+		seen[value] = true
+		size := header
+
+		for _, item := range value.Items() {
+			size += scriptValueSize(item[0], seen) + scriptValueSize(item[1], seen)
+		}
+
+		return size
+	case *starlark.Set:
+		if seen[value] {
+			return 0
+		}
+
+		seen[value] = true
+		size := header
+		iterator := value.Iterate()
+		defer iterator.Done()
+
+		var item starlark.Value
+
+		for iterator.Next(&item) {
+			size += scriptValueSize(item, seen)
+		}
+
+		return size
+	}
+
+	return header
+}
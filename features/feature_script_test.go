@@ -0,0 +1,187 @@
+package features
+
+import (
+	"data-feature-engineer/storage"
+	dfedata "data-feature-engineer/data"
+	"github.com/shopspring/decimal"
+	"math"
+	"strings"
+	"testing"
+)
+
+const averageScript = `
+def init():
+    return {"sum": 0.0, "n": 0}
+
+def calculate(state, price, timestamp):
+    state["sum"] += price
+    state["n"] += 1
+
+def invalidate(state, price, timestamp):
+    state["sum"] -= price
+    state["n"] -= 1
+
+def value(state):
+    return state["sum"] / state["n"]
+`
+
+// listScript average of prices kept in list, it goes through guarded operators, slices and calls
+const listScript = `
+def init():
+    return {"prices": []}
+
+def calculate(state, price, timestamp):
+    state["prices"] += [price]
+
+def invalidate(state, price, timestamp):
+    state["prices"] = state["prices"][1:]
+
+def value(state):
+    total = 0.0
+    for price in state["prices"]:
+        total += price
+    return total / len(state["prices"])
+`
+
+// Script average must follow AvgFeature through the same lifecycle, including carried data
+func TestScriptFeature_Update(t *testing.T) {
+	data := bootstrapRandomWalk(31, 1, 1800)
+
+	for _, source := range []string{averageScript, listScript} {
+		for _, WindowSeconds := range []uint64{5, 30, 300} {
+			script := (&ScriptFeature{}).New(WindowSeconds, &storage.LinkedListDataStorage{}, source, DefaultScriptLimits)
+			avg := (&AvgFeature{}).New(WindowSeconds, &storage.LinkedListDataStorage{})
+
+			replayTicks([]Feature{script, avg}, data, 1800, func(TimeCurrent uint64, seen []*dfedata.InputData) {
+				if err := script.GetError(); err != nil {
+					t.Fatal(err)
+				}
+
+				if script.GetAmount() != avg.GetAmount() || math.Abs(script.GetValue().InexactFloat64() - avg.GetValue().InexactFloat64()) > 1e-6 {
+					t.Fatalf("ScriptFeature.Update(%d, %d) should be %s of %d, got %s of %d",
+						WindowSeconds, TimeCurrent, avg.GetValue(), avg.GetAmount(), script.GetValue(), script.GetAmount())
+				}
+			})
+		}
+	}
+}
+
+// Failed tick doesn't stall feature, the next tick recreates state from window, which fails again
+// while the failing data stays in window
+func TestScriptFeature_Update_Recovers(t *testing.T) {
+	source := strings.Replace(averageScript, `    state["sum"] += price`, `    if price < 0:
+        fail("negative price")
+    state["sum"] += price`, 1)
+	f := (&ScriptFeature{}).New(10, &storage.LinkedListDataStorage{}, source, DefaultScriptLimits)
+
+	var tests = []struct {
+		TimeCurrent uint64
+		data []*dfedata.InputData
+		fails bool
+		expected float64
+	}{
+		{10, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(10), Timestamp: 10}}, false, 10},
+		{15, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(-1), Timestamp: 15}}, true, 0},
+		{20, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(30), Timestamp: 20}}, true, 0},
+		// Window is empty, the last data is carried
+		{40, []*dfedata.InputData{}, false, 30},
+		{45, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(50), Timestamp: 45}}, false, 50},
+	}
+
+	for _, tt := range tests {
+		f.Update(tt.TimeCurrent, tt.data)
+
+		if err := f.GetError(); (err != nil) != tt.fails {
+			t.Fatalf("ScriptFeature.Update(%d) error is %v", tt.TimeCurrent, err)
+		}
+
+		if !tt.fails && f.GetValue().InexactFloat64() != tt.expected {
+			t.Errorf("ScriptFeature.Update(%d) should be %v, got %s", tt.TimeCurrent, tt.expected, f.GetValue())
+		}
+	}
+}
+
+func TestScriptFeature_Update_Errors(t *testing.T) {
+	functions := `
+def calculate(state, price, timestamp):
+    %s
+
+def invalidate(state, price, timestamp):
+    pass
+
+def value(state):
+    %s
+`
+
+	allocating := func(calculate string) string {
+		return strings.Replace(strings.Replace(functions, "%s", calculate, 1), "%s", "return 1", 1)
+	}
+	allocationLimits := ScriptLimits{MaxTickAllocBytes: 1 << 20}
+
+	var tests = []struct {
+		name string
+		source string
+		limits ScriptLimits
+		expected string
+	}{
+		{"syntax", "def calculate(", DefaultScriptLimits, "feature.star"},
+		{"missing function", "def calculate(state, price, timestamp):\n    pass", DefaultScriptLimits, "function invalidate is not defined"},
+		{"load", `load("os.star", "os")`, DefaultScriptLimits, "load is not allowed"},
+		{"runtime", strings.Replace(strings.Replace(functions, "%s", "pass", 1), "%s", "return 1 // 0", 1), DefaultScriptLimits, "value"},
+		{"not a number", strings.Replace(strings.Replace(functions, "%s", "pass", 1), "%s", `return "1"`, 1), DefaultScriptLimits, "not a finite number"},
+		{"steps", strings.Replace(strings.Replace(functions, "%s", "[x for x in range(100000)]", 1), "%s", "return 1", 1),
+			ScriptLimits{MaxSteps: 1000, MaxRetainedStateBytes: 1 << 20}, "too many steps"},
+		{"state", strings.Replace(strings.Replace(functions, "%s", `state["log"] = state.get("log", "") + "x" * 1000`, 1), "%s", "return 1", 1),
+			ScriptLimits{MaxSteps: 1000000, MaxRetainedStateBytes: 10000}, "exceeds limit"},
+		{"augmented call", allocating(`state[str(price)] += 1`), DefaultScriptLimits, "augmented assignment"},
+		{"repeat string", allocating(`"x" * 100000000`), allocationLimits, "allocations of tick"},
+		{"repeat tuple", allocating(`(1,) * 100000000`), allocationLimits, "allocations of tick"},
+		{"repeat augmented", allocating("s = \"x\"\n    s *= 100000000"), allocationLimits, "allocations of tick"},
+		{"doubling", allocating("s = \"x\"\n    for i in range(40):\n        s += s"), allocationLimits, "allocations of tick"},
+		{"range", allocating(`list(range(100000000))`), allocationLimits, "allocations of tick"},
+		{"join", allocating(`",".join(["x" * 1000] * 1000)`), allocationLimits, "allocations of tick"},
+		{"replace", allocating(`("x" * 2000).replace("x", "y" * 1000)`), allocationLimits, "allocations of tick"},
+		{"format", allocating(`("{}" * 1000).format("x" * 1000)`), allocationLimits, "allocations of tick"},
+		{"percent", allocating(`("%r" * 1000) % ("x" * 1000,)`), allocationLimits, "allocations of tick"},
+		{"split", allocating(`("," * 100000).split(",")`), allocationLimits, "allocations of tick"},
+		{"str", allocating(`str(["x" * 1000] * 1000)`), allocationLimits, "allocations of tick"},
+		{"slices", allocating("s = \"x\" * 100000\n    for i in range(100):\n        state[i] = s[1:]"), allocationLimits, "allocations of tick"},
+	}
+
+	for _, tt := range tests {
+		f := (&ScriptFeature{}).New(5, &storage.LinkedListDataStorage{}, tt.source, tt.limits)
+
+		for TimeCurrent := uint64(5); TimeCurrent <= 100 && f.GetError() == nil; TimeCurrent += 5 {
+			f.Update(TimeCurrent, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(10), Timestamp: TimeCurrent}})
+		}
+
+		if err := f.GetError(); err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("ScriptFeature %s should fail with %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestScriptLimits_withDefaults(t *testing.T) {
+	var tests = []struct {
+		limits ScriptLimits
+		expected ScriptLimits
+	}{
+		{ScriptLimits{}, DefaultScriptLimits},
+		{ScriptLimits{MaxSteps: 1000}, ScriptLimits{MaxSteps: 1000, MaxTickAllocBytes: DefaultScriptLimits.MaxTickAllocBytes,
+			MaxRetainedStateBytes: DefaultScriptLimits.MaxRetainedStateBytes}},
+		{ScriptLimits{MaxTickAllocBytes: 1000}, ScriptLimits{MaxSteps: DefaultScriptLimits.MaxSteps, MaxTickAllocBytes: 1000,
+			MaxRetainedStateBytes: DefaultScriptLimits.MaxRetainedStateBytes}},
+		{ScriptLimits{MaxRetainedStateBytes: 10000}, ScriptLimits{MaxSteps: DefaultScriptLimits.MaxSteps,
+			MaxTickAllocBytes: DefaultScriptLimits.MaxTickAllocBytes, MaxRetainedStateBytes: 10000}},
+		{ScriptLimits{MaxSteps: 1000, MaxTickAllocBytes: 1000, MaxRetainedStateBytes: 10000},
+			ScriptLimits{MaxSteps: 1000, MaxTickAllocBytes: 1000, MaxRetainedStateBytes: 10000}},
+	}
+
+	for _, tt := range tests {
+		f := (&ScriptFeature{}).New(5, &storage.LinkedListDataStorage{}, "def calculate(", tt.limits)
+
+		if f.Limits != tt.expected {
+			t.Errorf("ScriptFeature.New limits %+v should be %+v, got %+v", tt.limits, tt.expected, f.Limits)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"math"
+)
+
+// autocorrelation of lag with index i of Lags, sum of products of deviations from mean of the whole window
+// of terms lag apart divided by sum of squared deviations. Multiplied by n² it is
+// (n² P - n S (A + B) + (n - lag) S²) / (n (n Q - S²)), where A is sum without the first lag terms
+// and B without the last lag terms, so only 2 lag terms are read
+func (w *windowedSums) autocorrelation(i int) decimal.Decimal {
+	lag, n := w.Lags[i], w.terms.Len()
+
+	if n <= lag {
+		return decimal.NewFromInt(0)
+	}
+
+	head, tail := decimal.Zero, decimal.Zero
+
+	for j := 0; j < lag; j++ {
+		head = head.Add(w.at(j))
+		tail = tail.Add(w.at(n - 1 - j))
+	}
+
+	N := decimal.NewFromInt(int64(n))
+	squaredSum := w.sum.Mul(w.sum)
+	denominator := N.Mul(N.Mul(w.sumSquared).Sub(squaredSum))
+
+	if !denominator.IsPositive() {
+		return decimal.NewFromInt(0)
+	}
+
+	numerator := N.Mul(N).Mul(w.lagSums[i]).
+		Sub(N.Mul(w.sum).Mul(w.sum.Sub(head).Add(w.sum.Sub(tail)))).
+		Add(decimal.NewFromInt(int64(n - lag)).Mul(squaredSum))
+
+	return numerator.DivRound(denominator, int32(decimal.DivisionPrecision))
+}
+
+// AutocorrelationFeature autocorrelation of log returns between closes of panes of PeriodSeconds in window
+// for every lag of Lags, values are in order of Lags. Negative values point to mean reversion, positive to momentum.
+// Returns are the ones of LogReturnFeature, so pane without data has no close and is skipped.
+// Cost of tick is the number of closed panes plus sum of Lags, it doesn't depend on amount of data in window.
+// Period of 0 seconds or lag below 1 is returned by GetError and feature is not updated
+type AutocorrelationFeature struct {
+	Lags []int
+	LastValues []decimal.Decimal
+
+	logReturns
+	err error
+
+	BasicFeature
+}
+
+func (f *AutocorrelationFeature) New(WindowSeconds uint64, PeriodSeconds uint64, Lags ...int) *AutocorrelationFeature {
+	f.Lags = Lags
+	f.LastValues = make([]decimal.Decimal, len(Lags))
+
+	for i := range f.LastValues {
+		f.LastValues[i] = decimal.NewFromInt(0)
+	}
+
+	f.LastValue = decimal.NewFromInt(0)
+	f.WindowSeconds = WindowSeconds
+
+	if PeriodSeconds == 0 {
+		f.err = fmt.Errorf("autocorrelation feature: period must be at least 1 second")
+		return f
+	}
+
+	for _, lag := range Lags {
+		if lag < 1 {
+			f.err = fmt.Errorf("autocorrelation feature: lag must be at least 1, got %d", lag)
+			return f
+		}
+	}
+
+	f.reset(PeriodSeconds, Lags...)
+	return f
+}
+
+func (f *AutocorrelationFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	if f.err != nil {
+		return
+	}
+
+	f.update(TimeCurrent, f.WindowSeconds, data)
+
+	for i := range f.Lags {
+		f.LastValues[i] = f.returns.autocorrelation(i)
+	}
+
+	if len(f.LastValues) > 0 {
+		f.LastValue = f.LastValues[0]
+	}
+
+	f.OnUpdated(TimeCurrent, data)
+}
+
+// GetValues autocorrelations in order of Lags
+func (f *AutocorrelationFeature) GetValues() []decimal.Decimal {
+	return f.LastValues
+}
+
+// GetAmount amount of returns in window
+func (f *AutocorrelationFeature) GetAmount() uint64 {
+	return uint64(f.returns.terms.Len())
+}
+
+func (f *AutocorrelationFeature) GetError() error {
+	return f.err
+}
+
+// minHurstChunk the smallest chunk rescaled range is computed for, smaller ones are too noisy
+const minHurstChunk = 4
+
+// HurstFeature Hurst exponent of log returns between closes of panes of PeriodSeconds in window estimated
+// by rescaled range: returns are split into chunks of n, n/2, n/4, ... down to minHurstChunk returns, chunks are
+// aligned to the newest return, and exponent is slope of log of average R/S of chunks by log of chunk size.
+// Below 0.5 is mean reversion, above is trend. It is 0 until there are 2 chunk sizes.
+// Returns are the ones of AutocorrelationFeature. Cost of tick is O(n log n) for n = WindowSeconds / PeriodSeconds returns at most.
+// Period of 0 seconds is returned by GetError and feature is not updated
+type HurstFeature struct {
+	logReturns
+	// buffer returns of window as floats, it is reused between ticks
+	buffer []float64
+	err error
+
+	BasicFeature
+}
+
+func (f *HurstFeature) New(WindowSeconds uint64, PeriodSeconds uint64) *HurstFeature {
+	f.reset(PeriodSeconds)
+	f.LastValue = decimal.NewFromInt(0)
+	f.WindowSeconds = WindowSeconds
+
+	if PeriodSeconds == 0 {
+		f.err = fmt.Errorf("hurst feature: period must be at least 1 second")
+	}
+
+	return f
+}
+
+func (f *HurstFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	if f.err != nil {
+		return
+	}
+
+	f.update(TimeCurrent, f.WindowSeconds, data)
+
+	f.buffer = f.buffer[:0]
+
+	for i := 0; i < f.returns.terms.Len(); i++ {
+		f.buffer = append(f.buffer, f.returns.at(i).InexactFloat64())
+	}
+
+	f.LastValue = decimal.NewFromInt(0)
+
+	if hurst, ok := rescaledRangeHurst(f.buffer); ok {
+		f.LastValue = decimal.NewFromFloat(hurst)
+	}
+
+	f.OnUpdated(TimeCurrent, data)
+}
+
+// GetAmount amount of returns in window
+func (f *HurstFeature) GetAmount() uint64 {
+	return uint64(f.returns.terms.Len())
+}
+
+func (f *HurstFeature) GetError() error {
+	return f.err
+}
+
+// rescaledRangeHurst see HurstFeature, chunks without variance have no rescaled range and are skipped
+func rescaledRangeHurst(returns []float64) (float64, bool) {
+	var sizes, ranges []float64
+
+	for size := len(returns); size >= minHurstChunk; size /= 2 {
+		total, chunks := 0.0, 0
+
+		for end := len(returns); end >= size; end -= size {
+			if rs, ok := rescaledRange(returns[end-size : end]); ok {
+				total += rs
+				chunks++
+			}
+		}
+
+		if chunks > 0 {
+			sizes = append(sizes, math.Log(float64(size)))
+			ranges = append(ranges, math.Log(total / float64(chunks)))
+		}
+	}
+
+	if len(sizes) < 2 {
+		return 0, false
+	}
+
+	var meanX, meanY float64
+
+	for i := range sizes {
+		meanX += sizes[i]
+		meanY += ranges[i]
+	}
+
+	meanX /= float64(len(sizes))
+	meanY /= float64(len(sizes))
+
+	var covariance, variance float64
+
+	for i := range sizes {
+		covariance += (sizes[i] - meanX) * (ranges[i] - meanY)
+		variance += (sizes[i] - meanX) * (sizes[i] - meanX)
+	}
+
+	return covariance / variance, true
+}
+
+// rescaledRange range of cumulative deviations from mean divided by standard deviation of chunk
+func rescaledRange(chunk []float64) (float64, bool) {
+	mean := 0.0
+
+	for _, value := range chunk {
+		mean += value
+	}
+
+	mean /= float64(len(chunk))
+
+	var cumulative, high, low, squared float64
+
+	for _, value := range chunk {
+		cumulative += value - mean
+		high = math.Max(high, cumulative)
+		low = math.Min(low, cumulative)
+		squared += (value - mean) * (value - mean)
+	}
+
+	deviation := math.Sqrt(squared / float64(len(chunk)))
+
+	if deviation == 0 {
+		return 0, false
+	}
+
+	return (high - low) / deviation, true
+}
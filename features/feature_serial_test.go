@@ -0,0 +1,219 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"github.com/shopspring/decimal"
+	"math"
+	"testing"
+)
+
+// bruteForceCloseReturns log returns of closes of panes ending in (TimeCurrent - WindowSeconds, TimeCurrent],
+// every close is the last price of pane, panes without data have no close
+func bruteForceCloseReturns(data []*dfedata.InputData, TimeCurrent uint64, WindowSeconds uint64, PeriodSeconds uint64) []float64 {
+	var result []float64
+	previous, next := 0.0, 0
+
+	for end := (data[0].Timestamp + PeriodSeconds - 1) / PeriodSeconds * PeriodSeconds; end <= TimeCurrent; end += PeriodSeconds {
+		from := next
+
+		for next < len(data) && data[next].Timestamp <= end {
+			next++
+		}
+
+		if next == from {
+			continue
+		}
+
+		close := data[next-1].DecimalCost.InexactFloat64()
+
+		if previous > 0 && end + WindowSeconds > TimeCurrent {
+			result = append(result, math.Log(close / previous))
+		}
+
+		previous = close
+	}
+
+	return result
+}
+
+func bruteForceAutocorrelation(returns []float64, lag int) float64 {
+	if len(returns) <= lag {
+		return 0
+	}
+
+	mean := 0.0
+
+	for _, r := range returns {
+		mean += r
+	}
+
+	mean /= float64(len(returns))
+
+	var numerator, denominator float64
+
+	for i, r := range returns {
+		denominator += (r - mean) * (r - mean)
+
+		if i >= lag {
+			numerator += (r - mean) * (returns[i-lag] - mean)
+		}
+	}
+
+	if denominator == 0 {
+		return 0
+	}
+
+	return numerator / denominator
+}
+
+func TestAutocorrelationFeature_Update(t *testing.T) {
+	data := bootstrapRandomWalk(21, 1, 3600)
+
+	for _, WindowSeconds := range []uint64{60, 300, 1800} {
+		f := (&AutocorrelationFeature{}).New(WindowSeconds, 5, 1, 2, 5)
+		hurst := (&HurstFeature{}).New(WindowSeconds, 5)
+
+		replayTicks([]Feature{f, hurst}, data, 3600, func(TimeCurrent uint64, seen []*dfedata.InputData) {
+			returns := bruteForceCloseReturns(data, TimeCurrent, WindowSeconds, 5)
+
+			if f.GetAmount() != uint64(len(returns)) {
+				t.Fatalf("AutocorrelationFeature.Update(%d, %d) should have %d returns, got %d", WindowSeconds, TimeCurrent, len(returns), f.GetAmount())
+			}
+
+			for i, lag := range f.Lags {
+				await := bruteForceAutocorrelation(returns, lag)
+
+				if math.Abs(f.GetValues()[i].InexactFloat64() - await) > 1e-9 {
+					t.Fatalf("AutocorrelationFeature.Update(%d, %d) lag %d should be %g, got %s", WindowSeconds, TimeCurrent, lag, await, f.GetValues()[i])
+				}
+			}
+
+			await, _ := rescaledRangeHurst(returns)
+
+			if math.Abs(hurst.GetValue().InexactFloat64() - await) > 1e-9 {
+				t.Fatalf("HurstFeature.Update(%d, %d) should be %g, got %s", WindowSeconds, TimeCurrent, await, hurst.GetValue())
+			}
+		})
+	}
+}
+
+func TestAutocorrelationFeature_Update_Table(t *testing.T) {
+	f := (&AutocorrelationFeature{}).New(20, 5, 1)
+	price := func(value int64, timestamp uint64) *dfedata.InputData {
+		return &dfedata.InputData{DecimalCost: decimal.NewFromInt(value), Timestamp: timestamp}
+	}
+
+	// Closes go between 100 and 200, so returns are ln 2 and -ln 2
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expected float64
+		amount uint64
+	}{
+		// Empty window without data before it
+		{5, nil, 0, 0},
+		// The first close has no return, the last of equal timestamps is the close
+		{10, []*dfedata.InputData{price(300, 9), price(100, 9)}, 0, 0},
+		// Single return has no lag
+		{15, []*dfedata.InputData{price(200, 12)}, 0, 1},
+		// Returns a, -a have mean 0, so autocorrelation is -a² / 2a²
+		{20, []*dfedata.InputData{price(100, 20)}, -0.5, 2},
+		// Returns a, -a, a have deviations 2a / 3, -4a / 3, 2a / 3
+		{25, []*dfedata.InputData{price(200, 25)}, -2.0 / 3, 3},
+		// Pane without data has no close
+		{30, nil, -2.0 / 3, 3},
+		// Return of pane ending at 15 left window
+		{35, nil, -0.5, 2},
+		{50, nil, 0, 0},
+		// Return is from the last close before window
+		{55, []*dfedata.InputData{price(400, 55)}, 0, 1},
+	}
+
+	for _, tick := range ticks {
+		f.Update(tick.TimeCurrent, tick.input)
+
+		if math.Abs(f.GetValue().InexactFloat64() - tick.expected) > 1e-9 || f.GetAmount() != tick.amount {
+			t.Errorf("AutocorrelationFeature.Update(%d) should be %g of %d returns, got %s of %d",
+				tick.TimeCurrent, tick.expected, tick.amount, f.GetValue(), f.GetAmount())
+		}
+	}
+
+	if r := f.returns.at(0).InexactFloat64(); math.Abs(r - math.Ln2) > 1e-9 {
+		t.Errorf("AutocorrelationFeature.Update should have return ln 2 from 200 to 400, got %g", r)
+	}
+}
+
+func TestAutocorrelationFeature_New_Lags(t *testing.T) {
+	for _, test := range []struct {
+		Lags []int
+		fails bool
+	}{
+		{[]int{1, 2}, false},
+		{[]int{}, false},
+		{[]int{0}, true},
+		{[]int{1, -1}, true},
+	} {
+		f := (&AutocorrelationFeature{}).New(30, 1, test.Lags...)
+
+		if (f.GetError() != nil) != test.fails {
+			t.Errorf("AutocorrelationFeature.New(%v) error is %v", test.Lags, f.GetError())
+		}
+
+		// Invalid feature is not updated, so it doesn't read before the first return
+		for TimeCurrent := uint64(1); TimeCurrent <= 3; TimeCurrent++ {
+			f.Update(TimeCurrent, []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(int64(10 + TimeCurrent)), Timestamp: TimeCurrent}})
+		}
+	}
+}
+
+// Period of 0 seconds would put returns of every data into window instead of closes of periods
+func TestSerialFeatures_New_PeriodSeconds(t *testing.T) {
+	for _, PeriodSeconds := range []uint64{0, 5} {
+		autocorrelation := (&AutocorrelationFeature{}).New(30, PeriodSeconds, 1)
+		hurst := (&HurstFeature{}).New(30, PeriodSeconds)
+
+		if (autocorrelation.GetError() != nil) != (PeriodSeconds == 0) {
+			t.Errorf("AutocorrelationFeature.New(%d) error is %v", PeriodSeconds, autocorrelation.GetError())
+		}
+
+		if (hurst.GetError() != nil) != (PeriodSeconds == 0) {
+			t.Errorf("HurstFeature.New(%d) error is %v", PeriodSeconds, hurst.GetError())
+		}
+
+		for TimeCurrent := uint64(1); TimeCurrent <= 3; TimeCurrent++ {
+			data := []*dfedata.InputData{{DecimalCost: decimal.NewFromInt(int64(10 + TimeCurrent)), Timestamp: TimeCurrent}}
+			autocorrelation.Update(TimeCurrent, data)
+			hurst.Update(TimeCurrent, data)
+		}
+
+		if PeriodSeconds == 0 && (autocorrelation.GetAmount() != 0 || hurst.GetAmount() != 0) {
+			t.Errorf("Invalid features should not be updated, got %d and %d returns", autocorrelation.GetAmount(), hurst.GetAmount())
+		}
+	}
+}
+
+// Price jumping back and forth every period is perfect mean reversion
+func TestHurstFeature_Update_MeanReversion(t *testing.T) {
+	autocorrelation := (&AutocorrelationFeature{}).New(300, 5, 1, 2)
+	hurst := (&HurstFeature{}).New(300, 5)
+
+	for TimeCurrent := uint64(5); TimeCurrent <= 600; TimeCurrent += 5 {
+		price := decimal.NewFromInt(100 + int64(TimeCurrent / 5 % 2))
+		data := []*dfedata.InputData{{DecimalCost: price, Timestamp: TimeCurrent - 1}}
+
+		autocorrelation.Update(TimeCurrent, data)
+		hurst.Update(TimeCurrent, data)
+	}
+
+	if lag1 := autocorrelation.GetValues()[0].InexactFloat64(); lag1 > -0.95 {
+		t.Errorf("AutocorrelationFeature.Update lag 1 should be close to -1, got %g", lag1)
+	}
+
+	if lag2 := autocorrelation.GetValues()[1].InexactFloat64(); lag2 < 0.9 {
+		t.Errorf("AutocorrelationFeature.Update lag 2 should be close to 1, got %g", lag2)
+	}
+
+	if value := hurst.GetValue().InexactFloat64(); value > 0.2 {
+		t.Errorf("HurstFeature.Update should be close to 0, got %g", value)
+	}
+}
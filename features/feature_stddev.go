@@ -9,7 +9,8 @@ import (
 
 // StdDevFeature Update looks like AvgFeature.Update, refactor probably?
 // Well we mostly Can't reuse AvgFeature result because of the structure of running StdDev algorithm
-// StdDevFeature implements Welford's online algorithm for continuous computation of standard deviation
+// StdDevFeature implements Welford's online algorithm for continuous computation of standard deviation,
+// invalidation is its exact inverse
 type StdDevFeature struct {
 	LastMean decimal.Decimal
 	LastS decimal.Decimal
@@ -27,71 +28,61 @@ func (f *StdDevFeature) New(WindowSeconds uint64, dataStorage storage.InputDataS
 	return f
 }
 
-//func (f *StdDevFeature) calculateMeanVarianceS(data *dfedata.InputData, amount uint64) (mean decimal.Decimal, variance decimal.Decimal, S decimal.Decimal) {
-//	n := decimal.NewFromInt(int64(amount))
-//	PrevMean := f.LastMean
-//	deltaPrevMean := data.DecimalCost.Sub(PrevMean)
-//	mean = PrevMean.Add(deltaPrevMean.Div(n))
-//
-//	deltaLastMean := data.DecimalCost.Sub(f.LastMean)
-//	S = f.LastS.Add(deltaPrevMean.Mul(deltaLastMean))
-//
-//	subValue, _ := S.Div(decimal.NewFromInt(int64(amount - 1))).Float64()
-//	sqrtValue := math.Sqrt(subValue)
-//	variance = decimal.NewFromFloat(sqrtValue)
-//	return
-//}
-
+// InvalidateData is exact inverse of CalculateData: data is added to the set without it, which gives the current one
 func (f *StdDevFeature) InvalidateData(data *dfedata.InputData) {
-	//if f.LastAmount - 1 < 2 {
-	//	f.LastValue = decimal.NewFromInt(0)
-	//	return
-	//}
-	if f.LastAmount <= 2 {
-		f.LastAmount -= 1
+	if f.LastAmount <= 1 {
+		f.LastMean = decimal.NewFromInt(0)
+		f.LastS = decimal.NewFromInt(0)
+		f.LastAmount = 0
+		f.LastValue = decimal.NewFromInt(0)
 		return
 	}
 
 	n := decimal.NewFromInt(int64(f.LastAmount))
-	PrevMean := f.LastMean
-	deltaPrevMean := data.DecimalCost.Sub(PrevMean)
-	f.LastMean = PrevMean.Add(deltaPrevMean.Div(n))
-
-	deltaLastMean := data.DecimalCost.Sub(f.LastMean)
-	f.LastS = f.LastS.Add(deltaPrevMean.Mul(deltaLastMean))
+	f.LastMean = f.LastMean.Mul(n).Sub(data.DecimalCost).Div(decimal.NewFromInt(int64(f.LastAmount - 1)))
 
-	subValue, _ := f.LastS.Div(decimal.NewFromInt(int64(f.LastAmount - 1))).Float64()
-	sqrtValue := math.Sqrt(subValue)
-	f.LastValue = f.LastValue.Sub(decimal.NewFromFloat(sqrtValue))
+	delta := data.DecimalCost.Sub(f.LastMean)
+	f.LastS = f.LastS.Sub(delta.Mul(delta.Div(n)).Mul(decimal.NewFromInt(int64(f.LastAmount - 1))))
+	f.round()
 
 	f.LastAmount -= 1
+	f.LastValue = f.sampleStdDev()
 }
 
 func (f *StdDevFeature) CalculateData(data *dfedata.InputData) {
-	n := decimal.NewFromInt(int64(f.LastAmount + 1))
+	// Window was reset, while only carried data was there, so S of the carried value is dropped too
 	if f.LastAmount == 0 {
 		f.LastMean = data.DecimalCost
 		f.LastS = decimal.NewFromInt(0)
 	} else {
-		PrevMean := f.LastMean
-		deltaPrevMean := data.DecimalCost.Sub(PrevMean)
-		f.LastMean = PrevMean.Add(deltaPrevMean.Div(n))
-
-		deltaLastMean := data.DecimalCost.Sub(f.LastMean)
-		f.LastS = f.LastS.Add(deltaPrevMean.Mul(deltaLastMean))
+		n := decimal.NewFromInt(int64(f.LastAmount + 1))
+		delta := data.DecimalCost.Sub(f.LastMean)
+		deltaN := delta.Div(n)
+		f.LastMean = f.LastMean.Add(deltaN)
+		f.LastS = f.LastS.Add(delta.Mul(deltaN).Mul(decimal.NewFromInt(int64(f.LastAmount))))
+		f.round()
 	}
 
 	f.LastAmount += 1
+	f.LastValue = f.sampleStdDev()
+}
+
+// round Decimal multiplication is exact, without rounding digits would grow on every update
+func (f *StdDevFeature) round() {
+	f.LastMean = f.LastMean.Round(int32(decimal.DivisionPrecision))
+	f.LastS = f.LastS.Round(int32(decimal.DivisionPrecision))
+}
+
+// sampleStdDev is defined for 2 values and more
+func (f *StdDevFeature) sampleStdDev() decimal.Decimal {
+	if f.LastAmount < 2 || !f.LastS.IsPositive() {
+		return decimal.NewFromInt(0)
+	}
 
 	// Implementing square root for Decimal is not an easy task
 	// Maybe converting to float taking math.Sqrt and returning LastValue will do
-	if f.LastAmount >= 2 {
-		subValue, _ := f.LastS.Div(decimal.NewFromInt(int64(f.LastAmount - 1))).Float64()
-		sqrtValue := math.Sqrt(subValue)
-		f.LastValue = decimal.NewFromFloat(sqrtValue)
-	} else {
-		f.LastValue = decimal.NewFromInt(0)
-	}
+	subValue, _ := f.LastS.Div(decimal.NewFromInt(int64(f.LastAmount - 1))).Float64()
+	return decimal.NewFromFloat(math.Sqrt(subValue))
 }
 
 func (f *StdDevFeature) GetAmount() uint64 {
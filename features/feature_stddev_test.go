@@ -34,10 +34,10 @@ func TestStdDevFeature_Update(t *testing.T) {
 			2,
 			f1,
 		},
-		// If there were no data in period we should store previous value
+		// If there were no data in period the last value is the only one by TZ, so stddev is 0
 		{ // 3
 			[]*dfedata.InputData{},
-			decimal.NewFromFloat(3.5355339059327378),
+			decimal.NewFromInt(0),
 			300,
 			f1,
 		},
@@ -77,4 +77,33 @@ func TestStdDevFeature_Update(t *testing.T) {
 			t.Errorf("StdDevFeature_Update(%#v): expected %s, actual %s, %d", tt.input, tt.expected, actual, i)
 		}
 	}
-}
\ No newline at end of file
+}
+
+// Evicted data must be removed exactly, stddev of what is left in window is the same as computed from scratch
+func TestStdDevFeature_Update_Eviction(t *testing.T) {
+	f := bootstrapStdDevFeature(10)
+	f.Update(4, []*dfedata.InputData{
+		{DecimalCost: decimal.NewFromInt(10), Timestamp: 1},
+		{DecimalCost: decimal.NewFromInt(20), Timestamp: 2},
+		{DecimalCost: decimal.NewFromInt(30), Timestamp: 3},
+		{DecimalCost: decimal.NewFromInt(40), Timestamp: 4},
+	})
+
+	var tests = []struct {
+		TimeCurrent uint64
+		expected decimal.Decimal
+	}{
+		{12, decimal.NewFromInt(10)},
+		{13, decimal.NewFromFloat(7.0710678118654755)},
+		// Only the carried last data is left
+		{14, decimal.NewFromInt(0)},
+	}
+
+	for _, tt := range tests {
+		f.Update(tt.TimeCurrent, nil)
+
+		if !f.GetValue().Equal(tt.expected) {
+			t.Errorf("StdDevFeature.Update(%d) should be %s, got %s", tt.TimeCurrent, tt.expected, f.GetValue())
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"github.com/gammazero/deque"
+	"github.com/shopspring/decimal"
+)
+
+// TWAPFeature calculates time weighted average price in window [TimeCurrent - WindowSeconds, TimeCurrent],
+// every price is weighted by seconds it was the prevailing price, so a burst of trades at one price counts
+// as long as the price lasted, not by amount of trades.
+// The last price from before window start prevails at window start, so it is kept and weighted by its part inside window.
+// Deque holds that price and prices of window, sum holds price * duration of closed segments between them,
+// then every tick is O(evicted + appended), like in AvgFeature
+type TWAPFeature struct {
+	dq deque.Deque
+	// sum of price * duration of segments between neighbours in dq, not clipped by window start
+	sum decimal.Decimal
+
+	BasicFeature
+}
+
+func (f *TWAPFeature) New(WindowSeconds uint64) *TWAPFeature {
+	f.LastValue = decimal.NewFromInt(0)
+	f.sum = decimal.NewFromInt(0)
+	f.WindowSeconds = WindowSeconds
+	return f
+}
+
+func (f *TWAPFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	for _, log := range data {
+		if !log.IsInWindow(TimeCurrent, f.WindowSeconds) {
+			continue
+		}
+
+		f.push(log)
+	}
+
+	windowStart := uint64(0)
+
+	if TimeCurrent > f.WindowSeconds {
+		windowStart = TimeCurrent - f.WindowSeconds
+	}
+
+	// Front price is needed while the next one starts after window start, it prevails at window start
+	for f.dq.Len() > 1 && f.dq.At(1).(*dfedata.InputData).Timestamp <= windowStart {
+		front := f.dq.PopFront().(*dfedata.InputData)
+		f.sum = f.sum.Sub(segment(front, f.dq.Front().(*dfedata.InputData)))
+	}
+
+	// Nothing has come yet, value stays 0, chained features still get the tick
+	if f.dq.Len() == 0 {
+		f.OnUpdated(TimeCurrent, data)
+		return
+	}
+
+	front := f.dq.Front().(*dfedata.InputData)
+	back := f.dq.Back().(*dfedata.InputData)
+	from := front.Timestamp
+
+	numerator := f.sum
+
+	// Part of the front segment before window start is not in window
+	if from < windowStart {
+		numerator = numerator.Sub(front.DecimalCost.Mul(decimal.NewFromInt(int64(windowStart - from))))
+		from = windowStart
+	}
+
+	// The last price lasts until now
+	if TimeCurrent > back.Timestamp {
+		numerator = numerator.Add(back.DecimalCost.Mul(decimal.NewFromInt(int64(TimeCurrent - back.Timestamp))))
+	}
+
+	// Prices came just now, none of them lasted, the last one prevails
+	if TimeCurrent <= from {
+		f.LastValue = back.DecimalCost
+	} else {
+		f.LastValue = numerator.Div(decimal.NewFromInt(int64(TimeCurrent - from)))
+	}
+
+	f.OnUpdated(TimeCurrent, data)
+}
+
+// push data must come sorted by timestamp, like scheduler provides them
+func (f *TWAPFeature) push(log *dfedata.InputData) {
+	if f.dq.Len() > 0 {
+		f.sum = f.sum.Add(segment(f.dq.Back().(*dfedata.InputData), log))
+	}
+
+	f.dq.PushBack(log)
+}
+
+// segment price * duration while price of from prevails until to
+func segment(from *dfedata.InputData, to *dfedata.InputData) decimal.Decimal {
+	if to.Timestamp <= from.Timestamp {
+		return decimal.Zero
+	}
+
+	return from.DecimalCost.Mul(decimal.NewFromInt(int64(to.Timestamp - from.Timestamp)))
+}
+
+// GetAmount amount of prices in window, including the one prevailing at window start
+func (f *TWAPFeature) GetAmount() uint64 {
+	return uint64(f.dq.Len())
+}
@@ -0,0 +1,130 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"github.com/shopspring/decimal"
+	"math"
+	"testing"
+	"time"
+)
+
+// bruteForceTWAP averages prevailing price of every second of [TimeCurrent - WindowSeconds, TimeCurrent)
+// since the first data, data are sorted
+func bruteForceTWAP(data []*dfedata.InputData, TimeCurrent uint64, WindowSeconds uint64) (float64, bool) {
+	sum, seconds := 0.0, 0
+	next := 0
+	var prevailing *dfedata.InputData
+
+	for second := uint64(0); second < TimeCurrent; second++ {
+		for next < len(data) && data[next].Timestamp <= second {
+			prevailing = data[next]
+			next++
+		}
+
+		if prevailing == nil || second + WindowSeconds < TimeCurrent {
+			continue
+		}
+
+		sum += prevailing.DecimalCost.InexactFloat64()
+		seconds++
+	}
+
+	if seconds == 0 {
+		return 0, false
+	}
+
+	return sum / float64(seconds), true
+}
+
+func TestTWAPFeature_Update(t *testing.T) {
+	data := bootstrapRandomWalk(4, 1, 900)
+
+	for _, WindowSeconds := range []uint64{5, 30, 300} {
+		f := (&TWAPFeature{}).New(WindowSeconds)
+
+		replayTicks([]Feature{f}, data, 900, func(TimeCurrent uint64, seen []*dfedata.InputData) {
+			expected, ok := bruteForceTWAP(seen, TimeCurrent, WindowSeconds)
+
+			if !ok {
+				return
+			}
+
+			if math.Abs(f.GetValue().InexactFloat64() - expected) > 1e-6 {
+				t.Fatalf("TWAPFeature_Update(%d, %d) should be %f, got %s", WindowSeconds, TimeCurrent, expected, f.GetValue())
+			}
+		})
+	}
+}
+
+func TestTWAPFeature_Update_Burst(t *testing.T) {
+	f := (&TWAPFeature{}).New(10)
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expected decimal.Decimal
+	}{
+		// Nothing lasted yet, the last price prevails
+		{5, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(100), Timestamp: 5},
+		}, decimal.NewFromInt(100)},
+		// 100 lasted 4 seconds, burst at 200 lasted 1 second
+		{10, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(200), Timestamp: 9},
+			{DecimalCost: decimal.NewFromInt(200), Timestamp: 9},
+			{DecimalCost: decimal.NewFromInt(200), Timestamp: 9},
+		}, decimal.NewFromInt(120)},
+		// Window is [10, 20], 200 carried from before window start prevails for all 10 seconds
+		{20, []*dfedata.InputData {}, decimal.NewFromInt(200)},
+		// Window is [15, 25], 200 for 5 seconds, 300 for 5 seconds
+		{25, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(300), Timestamp: 20},
+		}, decimal.NewFromInt(250)},
+		// Window is [16, 26], 200 for 4 seconds, 300 for 6 seconds, 400 and 500 of equal timestamps lasted 0 seconds
+		{26, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(400), Timestamp: 26},
+			{DecimalCost: decimal.NewFromInt(500), Timestamp: 26},
+		}, decimal.NewFromInt(260)},
+		// Window is [40, 50], 500 carried from before window start prevails
+		{50, []*dfedata.InputData {}, decimal.NewFromInt(500)},
+	}
+
+	for _, tick := range ticks {
+		f.Update(tick.TimeCurrent, tick.input)
+
+		if !f.GetValue().Equal(tick.expected) {
+			t.Errorf("TWAPFeature_Update(%d) should be %s, got %s", tick.TimeCurrent, tick.expected, f.GetValue())
+		}
+	}
+}
+
+// Chained features get every tick, including the ones before the first data
+func TestTWAPFeature_Update_Chain(t *testing.T) {
+	f := (&TWAPFeature{}).New(10)
+	var chained Feature = &chainedValues{values: make(chan decimal.Decimal, 1)}
+	f.Chain(&chained)
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfedata.InputData
+		expected int64
+	}{
+		{5, []*dfedata.InputData {}, 0},
+		{10, []*dfedata.InputData {
+			{DecimalCost: decimal.NewFromInt(100), Timestamp: 10},
+		}, 100},
+	}
+
+	for _, tick := range ticks {
+		f.Update(tick.TimeCurrent, tick.input)
+
+		select {
+		case value := <-chained.(*chainedValues).values:
+			if !value.Equal(decimal.NewFromInt(tick.expected)) {
+				t.Fatalf("TWAPFeature.Update(%d) should send %d to chained features, got %s", tick.TimeCurrent, tick.expected, value)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("TWAPFeature.Update(%d) should notify chained features", tick.TimeCurrent)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/shopspring/decimal"
+)
+
+// VolumeFeature sums traded size in window, Side filters trades by aggressor side, SideUnknown sums all trades,
+// the last trade is not carried, so volume of window without trades is 0
+type VolumeFeature struct {
+	Side dfedata.Side
+
+	BasicRunningFeature
+}
+
+func (f *VolumeFeature) New(WindowSeconds uint64, dataStorage storage.InputDataStorage, Side dfedata.Side) *VolumeFeature {
+	f.DataStorage = dataStorage
+	f.LastValue = decimal.NewFromInt(0)
+	f.NoCarry = true
+	f.RunningFeature = f
+	f.WindowSeconds = WindowSeconds
+	f.Side = Side
+	return f
+}
+
+func (f *VolumeFeature) isCounted(data *dfedata.InputData) bool {
+	return f.Side == dfedata.SideUnknown || f.Side == data.Side
+}
+
+func (f *VolumeFeature) InvalidateData(data *dfedata.InputData) {
+	if f.isCounted(data) {
+		f.LastValue = f.LastValue.Sub(data.DecimalSize)
+	}
+
+	f.LastAmount -= 1
+}
+
+func (f *VolumeFeature) CalculateData(data *dfedata.InputData) {
+	if f.isCounted(data) {
+		f.LastValue = f.LastValue.Add(data.DecimalSize)
+	}
+
+	f.LastAmount += 1
+}
+
+// TradeCountFeature amount of trades in window, 0 without trades
+type TradeCountFeature struct {
+	BasicRunningFeature
+}
+
+func (f *TradeCountFeature) New(WindowSeconds uint64, dataStorage storage.InputDataStorage) *TradeCountFeature {
+	f.DataStorage = dataStorage
+	f.LastValue = decimal.NewFromInt(0)
+	f.NoCarry = true
+	f.RunningFeature = f
+	f.WindowSeconds = WindowSeconds
+	return f
+}
+
+func (f *TradeCountFeature) InvalidateData(data *dfedata.InputData) {
+	f.LastAmount -= 1
+	f.LastValue = decimal.NewFromInt(int64(f.LastAmount))
+}
+
+func (f *TradeCountFeature) CalculateData(data *dfedata.InputData) {
+	f.LastAmount += 1
+	f.LastValue = decimal.NewFromInt(int64(f.LastAmount))
+}
+
+// OrderFlowImbalanceFeature is (buy volume - sell volume) / (buy volume + sell volume) in window, it is in [-1, 1],
+// trades with unknown side are not counted, it is 0 without volume
+type OrderFlowImbalanceFeature struct {
+	LastBuySize decimal.Decimal
+	LastSellSize decimal.Decimal
+
+	BasicRunningFeature
+}
+
+func (f *OrderFlowImbalanceFeature) New(WindowSeconds uint64, dataStorage storage.InputDataStorage) *OrderFlowImbalanceFeature {
+	f.DataStorage = dataStorage
+	f.LastValue = decimal.NewFromInt(0)
+	f.LastBuySize = decimal.NewFromInt(0)
+	f.LastSellSize = decimal.NewFromInt(0)
+	f.NoCarry = true
+	f.RunningFeature = f
+	f.WindowSeconds = WindowSeconds
+	return f
+}
+
+func (f *OrderFlowImbalanceFeature) InvalidateData(data *dfedata.InputData) {
+	switch data.Side {
+	case dfedata.SideBuy:
+		f.LastBuySize = f.LastBuySize.Sub(data.DecimalSize)
+	case dfedata.SideSell:
+		f.LastSellSize = f.LastSellSize.Sub(data.DecimalSize)
+	}
+
+	f.LastAmount -= 1
+	f.calculateValue()
+}
+
+func (f *OrderFlowImbalanceFeature) CalculateData(data *dfedata.InputData) {
+	switch data.Side {
+	case dfedata.SideBuy:
+		f.LastBuySize = f.LastBuySize.Add(data.DecimalSize)
+	case dfedata.SideSell:
+		f.LastSellSize = f.LastSellSize.Add(data.DecimalSize)
+	}
+
+	f.LastAmount += 1
+	f.calculateValue()
+}
+
+func (f *OrderFlowImbalanceFeature) calculateValue() {
+	total := f.LastBuySize.Add(f.LastSellSize)
+
+	if total.IsZero() {
+		f.LastValue = decimal.NewFromInt(0)
+		return
+	}
+
+	f.LastValue = f.LastBuySize.Sub(f.LastSellSize).Div(total)
+}
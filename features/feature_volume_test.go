@@ -0,0 +1,96 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/shopspring/decimal"
+	"testing"
+)
+
+func TestVolumeFeatures_Update(t *testing.T) {
+	total := (&VolumeFeature{}).New(10, &storage.LinkedListDataStorage{}, dfedata.SideUnknown)
+	buy := (&VolumeFeature{}).New(10, &storage.LinkedListDataStorage{}, dfedata.SideBuy)
+	sell := (&VolumeFeature{}).New(10, &storage.LinkedListDataStorage{}, dfedata.SideSell)
+	count := (&TradeCountFeature{}).New(10, &storage.LinkedListDataStorage{})
+	imbalance := (&OrderFlowImbalanceFeature{}).New(10, &storage.LinkedListDataStorage{})
+
+	var tests = []struct {
+		input []*dfedata.InputData
+		TimeCurrent uint64
+		// total, buy, sell, count, imbalance
+		expected []string
+	}{
+		{
+			[]*dfedata.InputData{
+				{DecimalCost: decimal.NewFromInt(10), DecimalSize: decimal.NewFromInt(3), Side: dfedata.SideBuy, Timestamp: 1},
+				{DecimalCost: decimal.NewFromInt(10), DecimalSize: decimal.NewFromInt(1), Side: dfedata.SideSell, Timestamp: 3},
+				{DecimalCost: decimal.NewFromInt(10), DecimalSize: decimal.NewFromInt(2), Side: dfedata.SideUnknown, Timestamp: 5},
+			},
+			5,
+			[]string{"6", "3", "1", "3", "0.5"},
+		},
+		{
+			[]*dfedata.InputData{
+				{DecimalCost: decimal.NewFromInt(10), DecimalSize: decimal.NewFromInt(4), Side: dfedata.SideSell, Timestamp: 10},
+			},
+			10,
+			[]string{"10", "3", "5", "4", "-0.25"},
+		},
+		// Data at 1 and 3 are evicted without new data
+		{
+			[]*dfedata.InputData{},
+			15,
+			[]string{"6", "0", "4", "2", "-1"},
+		},
+		// Nothing is evicted twice
+		{
+			[]*dfedata.InputData{},
+			17,
+			[]string{"4", "0", "4", "1", "-1"},
+		},
+		// Window without trades has no volume, the last trade is not carried
+		{
+			[]*dfedata.InputData{},
+			100,
+			[]string{"0", "0", "0", "0", "0"},
+		},
+		{
+			[]*dfedata.InputData{
+				{DecimalCost: decimal.NewFromInt(10), DecimalSize: decimal.NewFromInt(1), Side: dfedata.SideBuy, Timestamp: 105},
+			},
+			105,
+			[]string{"1", "1", "0", "1", "1"},
+		},
+		{
+			[]*dfedata.InputData{
+				{DecimalCost: decimal.NewFromInt(10), DecimalSize: decimal.NewFromInt(2), Side: dfedata.SideBuy, Timestamp: 110},
+				{DecimalCost: decimal.NewFromInt(10), DecimalSize: decimal.NewFromInt(2), Side: dfedata.SideSell, Timestamp: 110},
+			},
+			110,
+			[]string{"5", "3", "2", "3", "0.2"},
+		},
+		{
+			[]*dfedata.InputData{},
+			120,
+			[]string{"4", "2", "2", "2", "0"},
+		},
+		// Trades with equal timestamps leave window together
+		{
+			[]*dfedata.InputData{},
+			121,
+			[]string{"0", "0", "0", "0", "0"},
+		},
+	}
+
+	for i, tt := range tests {
+		features := []Feature{total, buy, sell, count, imbalance}
+
+		for j, feature := range features {
+			feature.Update(tt.TimeCurrent, tt.input)
+
+			if expected := decimal.RequireFromString(tt.expected[j]); !feature.GetValue().Equal(expected) {
+				t.Errorf("TestVolumeFeatures_Update feature %d: expected %s, actual %s, test=%d", j, expected, feature.GetValue(), i+1)
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/shopspring/decimal"
+)
+
+// VWAPFeature calculates volume weighted average price in window, it keeps running sums of price * size and size
+// of trades in window, so eviction is just a subtraction like in AvgFeature
+type VWAPFeature struct {
+	LastPriceSize decimal.Decimal
+	LastSize decimal.Decimal
+
+	BasicRunningFeature
+}
+
+func (f *VWAPFeature) New(WindowSeconds uint64, dataStorage storage.InputDataStorage) *VWAPFeature {
+	f.DataStorage = dataStorage
+	f.LastValue = decimal.NewFromInt(0)
+	f.LastPriceSize = decimal.NewFromInt(0)
+	f.LastSize = decimal.NewFromInt(0)
+	f.NoCarry = true
+	f.RunningFeature = f
+	f.WindowSeconds = WindowSeconds
+	return f
+}
+
+// Update value is calculated once window is updated, window emptied by the tick keeps VWAP of the previous tick,
+// not of its part left after evicting some of trades
+func (f *VWAPFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData) {
+	f.updateWindow(TimeCurrent, data)
+	f.calculateValue()
+	f.OnUpdated(TimeCurrent, data)
+}
+
+func (f *VWAPFeature) InvalidateData(data *dfedata.InputData) {
+	f.LastPriceSize = f.LastPriceSize.Sub(data.DecimalCost.Mul(data.DecimalSize))
+	f.LastSize = f.LastSize.Sub(data.DecimalSize)
+	f.LastAmount -= 1
+}
+
+func (f *VWAPFeature) CalculateData(data *dfedata.InputData) {
+	f.LastPriceSize = f.LastPriceSize.Add(data.DecimalCost.Mul(data.DecimalSize))
+	f.LastSize = f.LastSize.Add(data.DecimalSize)
+	f.LastAmount += 1
+}
+
+// calculateValue VWAP is not defined without volume, then we keep the last value
+func (f *VWAPFeature) calculateValue() {
+	if f.LastSize.IsZero() {
+		return
+	}
+
+	f.LastValue = f.LastPriceSize.Div(f.LastSize)
+}
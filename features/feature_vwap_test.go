@@ -0,0 +1,65 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/storage"
+	"github.com/shopspring/decimal"
+	"testing"
+)
+
+func bootstrapVWAPFeature(WindowSeconds uint64) *VWAPFeature {
+	feature := &VWAPFeature{}
+	return feature.New(WindowSeconds, &storage.LinkedListDataStorage{})
+}
+
+func TestVWAPFeature_Update(t *testing.T) {
+	f1 := bootstrapVWAPFeature(100)
+
+	var tests = []struct {
+		input []*dfedata.InputData
+		expected decimal.Decimal
+		TimeCurrent uint64
+	}{
+		{ // 1
+			[]*dfedata.InputData{
+				{DecimalCost: decimal.NewFromInt(10), DecimalSize: decimal.NewFromInt(1), Timestamp: 1},
+				{DecimalCost: decimal.NewFromInt(20), DecimalSize: decimal.NewFromInt(3), Timestamp: 2},
+			},
+			decimal.RequireFromString("17.5"),
+			2,
+		},
+		// If there were no data in period we should store previous value
+		{ // 2
+			[]*dfedata.InputData{},
+			decimal.RequireFromString("17.5"),
+			50,
+		},
+		// Data at 1 is evicted, since window is [2, 102]
+		{ // 3
+			[]*dfedata.InputData{{DecimalCost: decimal.NewFromInt(40), DecimalSize: decimal.NewFromInt(1), Timestamp: 101}},
+			decimal.NewFromInt(25),
+			102,
+		},
+		// Window has no volume, VWAP is not defined then, so the last value is kept, the last trade is not carried
+		{ // 4
+			[]*dfedata.InputData{},
+			decimal.NewFromInt(25),
+			300,
+		},
+		{ // 5
+			[]*dfedata.InputData{{DecimalCost: decimal.NewFromInt(30), DecimalSize: decimal.NewFromInt(2), Timestamp: 400}},
+			decimal.NewFromInt(30),
+			400,
+		},
+	}
+
+	for i, tt := range tests {
+		f1.Update(tt.TimeCurrent, tt.input)
+
+		actual := f1.GetValue()
+
+		if !actual.Equal(tt.expected) {
+			t.Errorf("VWAPFeature_Update(%#v): expected %s, actual %s, test=%d", tt.input, tt.expected, actual, i+1)
+		}
+	}
+}
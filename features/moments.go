@@ -0,0 +1,116 @@
+package features
+
+import (
+	"github.com/shopspring/decimal"
+	"math"
+)
+
+// moments keeps mean and sums of powers of deviations from it (M2, M3, M4) of a changing set of values,
+// values are added with Welford's update extended to higher moments (Pébay) and removed with its exact inverse,
+// so windowed features don't need to rescan window on eviction
+type moments struct {
+	n int64
+	mean decimal.Decimal
+	m2 decimal.Decimal
+	m3 decimal.Decimal
+	m4 decimal.Decimal
+}
+
+func (m *moments) reset() {
+	m.n = 0
+	m.mean = decimal.Zero
+	m.m2 = decimal.Zero
+	m.m3 = decimal.Zero
+	m.m4 = decimal.Zero
+}
+
+func (m *moments) add(x decimal.Decimal) {
+	m.n++
+
+	if m.n == 1 {
+		m.mean, m.m2, m.m3, m.m4 = x, decimal.Zero, decimal.Zero, decimal.Zero
+		return
+	}
+
+	n := decimal.NewFromInt(m.n)
+	delta := x.Sub(m.mean)
+	deltaN := delta.Div(n)
+	deltaN2 := deltaN.Mul(deltaN)
+	term := delta.Mul(deltaN).Mul(decimal.NewFromInt(m.n - 1))
+
+	m.mean = m.mean.Add(deltaN)
+	// M4 and M3 use previous M3 and M2, so order matters
+	m.m4 = m.m4.Add(term.Mul(deltaN2).Mul(decimal.NewFromInt(m.n * m.n - 3 * m.n + 3))).
+		Add(deltaN2.Mul(m.m2).Mul(decimal.NewFromInt(6))).
+		Sub(deltaN.Mul(m.m3).Mul(decimal.NewFromInt(4)))
+	m.m3 = m.m3.Add(term.Mul(deltaN).Mul(decimal.NewFromInt(m.n - 2))).Sub(deltaN.Mul(m.m2).Mul(decimal.NewFromInt(3)))
+	m.m2 = m.m2.Add(term)
+	m.round()
+}
+
+// remove is add backwards: x is added to the set without it, which gives the current one
+func (m *moments) remove(x decimal.Decimal) {
+	if m.n <= 1 {
+		m.reset()
+		return
+	}
+
+	n := decimal.NewFromInt(m.n)
+	m.mean = m.mean.Mul(n).Sub(x).Div(decimal.NewFromInt(m.n - 1))
+
+	delta := x.Sub(m.mean)
+	deltaN := delta.Div(n)
+	deltaN2 := deltaN.Mul(deltaN)
+	term := delta.Mul(deltaN).Mul(decimal.NewFromInt(m.n - 1))
+
+	m.m2 = m.m2.Sub(term)
+	m.m3 = m.m3.Sub(term.Mul(deltaN).Mul(decimal.NewFromInt(m.n - 2))).Add(deltaN.Mul(m.m2).Mul(decimal.NewFromInt(3)))
+	m.m4 = m.m4.Sub(term.Mul(deltaN2).Mul(decimal.NewFromInt(m.n * m.n - 3 * m.n + 3))).
+		Sub(deltaN2.Mul(m.m2).Mul(decimal.NewFromInt(6))).
+		Add(deltaN.Mul(m.m3).Mul(decimal.NewFromInt(4)))
+	m.n--
+	m.round()
+}
+
+// round Decimal multiplication is exact, without rounding digits would grow on every update
+func (m *moments) round() {
+	precision := int32(decimal.DivisionPrecision)
+	m.mean = m.mean.Round(precision)
+	m.m2 = m.m2.Round(precision)
+	m.m3 = m.m3.Round(precision)
+	m.m4 = m.m4.Round(precision)
+}
+
+// sampleStdDev is defined for 2 values and more
+func (m *moments) sampleStdDev() decimal.Decimal {
+	if m.n < 2 || !m.m2.IsPositive() {
+		return decimal.NewFromInt(0)
+	}
+
+	// Implementing square root for Decimal is not an easy task
+	// Maybe converting to float taking math.Sqrt and returning LastValue will do
+	subValue, _ := m.m2.Div(decimal.NewFromInt(m.n - 1)).Float64()
+	return decimal.NewFromFloat(math.Sqrt(subValue))
+}
+
+// skewness population skewness, zero when values are equal
+func (m *moments) skewness() decimal.Decimal {
+	m2 := m.m2.InexactFloat64()
+
+	if m.n < 2 || m2 <= 0 {
+		return decimal.NewFromInt(0)
+	}
+
+	return decimal.NewFromFloat(math.Sqrt(float64(m.n)) * m.m3.InexactFloat64() / math.Pow(m2, 1.5))
+}
+
+// excessKurtosis population kurtosis minus 3 of normal distribution, zero when values are equal
+func (m *moments) excessKurtosis() decimal.Decimal {
+	m2 := m.m2.InexactFloat64()
+
+	if m.n < 2 || m2 <= 0 {
+		return decimal.NewFromInt(0)
+	}
+
+	return decimal.NewFromFloat(float64(m.n) * m.m4.InexactFloat64() / (m2 * m2) - 3)
+}
@@ -0,0 +1,60 @@
+package features
+
+import (
+	dfedata "data-feature-engineer/data"
+	"github.com/shopspring/decimal"
+	"math/rand"
+	"sort"
+)
+
+// bruteForceWindow returns prices of data in [TimeCurrent - WindowSeconds, TimeCurrent], sorted
+func bruteForceWindow(data []*dfedata.InputData, TimeCurrent uint64, WindowSeconds uint64) []float64 {
+	var result []float64
+
+	for _, log := range data {
+		if log.IsInWindow(TimeCurrent, WindowSeconds) && log.Timestamp <= TimeCurrent {
+			result = append(result, log.DecimalCost.InexactFloat64())
+		}
+	}
+
+	sort.Float64s(result)
+
+	return result
+}
+
+// bootstrapRandomWalk generates sorted data for seconds [from, to), some seconds are empty
+func bootstrapRandomWalk(seed int64, from uint64, to uint64) []*dfedata.InputData {
+	random := rand.New(rand.NewSource(seed))
+	price := int64(6537200)
+	var result []*dfedata.InputData
+
+	for second := from; second < to; second++ {
+		for i := random.Intn(4) - 1; i > 0; i-- {
+			price += random.Int63n(101) - 50
+			result = append(result, &dfedata.InputData{DecimalCost: decimal.New(price, -2), Timestamp: second})
+		}
+	}
+
+	return result
+}
+
+// replayTicks feeds data to features in ticks of 5 seconds ending before to, like FeatureEngineer does:
+// every tick gets data which came since the previous one and features are updated in given order.
+// check is called after every tick with all data up to TimeCurrent
+func replayTicks(features []Feature, data []*dfedata.InputData, to uint64, check func(TimeCurrent uint64, seen []*dfedata.InputData)) {
+	next := 0
+
+	for TimeCurrent := uint64(5); TimeCurrent < to; TimeCurrent += 5 {
+		from := next
+
+		for next < len(data) && data[next].Timestamp <= TimeCurrent {
+			next++
+		}
+
+		for _, f := range features {
+			f.Update(TimeCurrent, data[from:next])
+		}
+
+		check(TimeCurrent, data[:next])
+	}
+}
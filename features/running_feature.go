@@ -14,6 +14,11 @@ type RunningFeatureInterface interface {
 
 type BasicRunningFeature struct {
 	LastAmount uint64
+	// NoCarry window without data is empty instead of keeping the last data, for features summing data like volume
+	NoCarry bool
+	// invalidatedAmount data at the head of storage which are already invalidated, but still stored,
+	// because storage is invalidated only when new data are appended, they must not be invalidated twice
+	invalidatedAmount uint64
 
 	DataStorage storage.InputDataStorage
 	RunningFeature RunningFeatureInterface
@@ -24,11 +29,19 @@ type BasicRunningFeature struct {
 // Update Data should go in sorted manner, probably linked list is an efficient underlying data storage for this use case
 // Probably much code can be refactored for reuse in another features ¯\_(ツ)_/¯ (we do here)
 func (f *BasicRunningFeature) Update(TimeCurrent uint64, data []*dfedata.InputData, connectionChannel ...chan ConnectionChannelData)  {
+	f.updateWindow(TimeCurrent, data)
+	f.OnUpdated(TimeCurrent, data)
+}
+
+// updateWindow Update without OnUpdated, features computing values after the window is updated
+// call OnUpdated themselves, so chained features see the new values
+func (f *BasicRunningFeature) updateWindow(TimeCurrent uint64, data []*dfedata.InputData) {
 	// Deal with reallocation? Set to len of data, or precompute valid batch size
 	var dataToAppend []*dfedata.InputData
 	willAppend := dfedata.IsThereAreAnyDataToProcess(TimeCurrent, f.WindowSeconds, data)
 
-	storageData := f.DataStorage.Iterate()
+	storageData := f.DataStorage.Iterate()[f.invalidatedAmount:]
+	invalidatedAmount := uint64(0)
 
 	// We are invalidating old results based on a current window size
 	// \frac{1}{N} \Sum_{i}^{N} a_i - a_j = (\Sum_{i}^{N} a_i - a_j) 1/(N-1))
@@ -42,7 +55,7 @@ func (f *BasicRunningFeature) Update(TimeCurrent uint64, data []*dfedata.InputDa
 		}
 
 		// We are invalidating data until there are no more than 1 element available, which we should preserve by TZ
-		if f.LastAmount == 1 {
+		if f.LastAmount == 1 && !f.NoCarry {
 			// Check if data will be appended otherwise preserve last data
 			// We should also find the latest available element if it is there
 			if willAppend {
@@ -54,6 +67,7 @@ func (f *BasicRunningFeature) Update(TimeCurrent uint64, data []*dfedata.InputDa
 		}
 
 		f.RunningFeature.InvalidateData(log)
+		invalidatedAmount++
 	}
 
 	if f.DataStorage != nil {
@@ -66,6 +80,9 @@ func (f *BasicRunningFeature) Update(TimeCurrent uint64, data []*dfedata.InputDa
 		}
 		if willAppend {
 			f.DataStorage = f.DataStorage.InvalidateDataBeforeTimestamp(diff)
+			f.invalidatedAmount = 0
+		} else {
+			f.invalidatedAmount += invalidatedAmount
 		}
 	}
 
@@ -85,8 +102,6 @@ func (f *BasicRunningFeature) Update(TimeCurrent uint64, data []*dfedata.InputDa
 	if f.DataStorage != nil {
 		f.DataStorage = f.DataStorage.Append(dataToAppend)
 	}
-
-	f.OnUpdated(TimeCurrent, data)
 }
 
 func (f *BasicRunningFeature) GetAmount() uint64 {
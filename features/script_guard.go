@@ -0,0 +1,608 @@
+package features
+
+import (
+	"fmt"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// scriptGuard counts bytes allocated by script during tick, Starlark doesn't count allocations, so script is rewritten
+// before compilation: operators, slices and calls go through builtins of guard, which estimate size of result and fail
+// before the operation runs, when tick would exceed the limit. Other operations allocate a few words per step at most,
+// they are bounded by steps
+type scriptGuard struct {
+	limit int
+	allocated int
+}
+
+// Names of guard builtins can't be spelled in script, so script can neither shadow nor call them
+const (
+	scriptGuardBinary = "$binary"
+	scriptGuardAugmented = "$augmented"
+	scriptGuardUnary = "$unary"
+	scriptGuardSize = "$size"
+	scriptGuardCall = "$call"
+)
+
+// scriptHeaderBytes approximate size of any value
+const scriptHeaderBytes = 16
+
+// scriptGuardedBinary operators allocating results, comparisons, membership and logical operators don't
+var scriptGuardedBinary = map[syntax.Token]bool{
+	syntax.PLUS: true, syntax.MINUS: true, syntax.STAR: true, syntax.SLASH: true, syntax.SLASHSLASH: true,
+	syntax.PERCENT: true, syntax.AMP: true, syntax.PIPE: true, syntax.CIRCUMFLEX: true, syntax.LTLT: true, syntax.GTGT: true,
+}
+
+// scriptCheapBuiltins builtins and methods allocating a few words at most, whatever their arguments are
+var scriptCheapBuiltins = map[string]bool{
+	"len": true, "type": true, "bool": true, "float": true, "hasattr": true, "getattr": true, "min": true, "max": true,
+	"any": true, "all": true, "hash": true, "range": true, "chr": true, "ord": true,
+	"append": true, "insert": true, "pop": true, "popitem": true, "remove": true, "clear": true, "get": true,
+	"setdefault": true, "index": true, "rindex": true, "find": true, "rfind": true, "count": true,
+	"startswith": true, "endswith": true,
+}
+
+func (g *scriptGuard) reset(limit int) {
+	g.limit = limit
+	g.allocated = 0
+}
+
+func (g *scriptGuard) charge(size int) error {
+	if size > g.limit - g.allocated {
+		return fmt.Errorf("allocations of tick exceed limit of %d bytes", g.limit)
+	}
+
+	g.allocated += size
+
+	return nil
+}
+
+// remaining sizes above it fail anyway, so estimates stop there
+func (g *scriptGuard) remaining() int {
+	return g.limit - g.allocated
+}
+
+func (g *scriptGuard) builtins() starlark.StringDict {
+	return starlark.StringDict{
+		scriptGuardBinary: starlark.NewBuiltin("operator", g.binary),
+		scriptGuardAugmented: starlark.NewBuiltin("operator", g.augmented),
+		scriptGuardUnary: starlark.NewBuiltin("operator", g.unary),
+		scriptGuardSize: starlark.NewBuiltin("slice", g.size),
+		scriptGuardCall: starlark.NewBuiltin("call", g.call),
+	}
+}
+
+// binary $binary(op, x, y) is x op y
+func (g *scriptGuard) binary(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, _ []starlark.Tuple) (starlark.Value, error) {
+	op := scriptGuardToken(args[0])
+
+	if err := g.charge(scriptBinarySize(op, args[1], args[2], g.remaining())); err != nil {
+		return nil, err
+	}
+
+	return starlark.Binary(op, args[1], args[2])
+}
+
+// augmented $augmented(op, x, y) is y checked for x op= y, interpreter applies the operator itself,
+// because += extends list in place
+func (g *scriptGuard) augmented(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, _ []starlark.Tuple) (starlark.Value, error) {
+	op := scriptGuardToken(args[0])
+	size := 0
+
+	if _, ok := args[1].(*starlark.List); ok && op == syntax.PLUS {
+		size = scriptShallowSize(args[2])
+	} else {
+		size = scriptBinarySize(op, args[1], args[2], g.remaining())
+	}
+
+	if err := g.charge(size); err != nil {
+		return nil, err
+	}
+
+	return args[2], nil
+}
+
+// unary $unary(op, x) is op x
+func (g *scriptGuard) unary(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, _ []starlark.Tuple) (starlark.Value, error) {
+	if err := g.charge(scriptShallowSize(args[1])); err != nil {
+		return nil, err
+	}
+
+	return starlark.Unary(scriptGuardToken(args[0]), args[1])
+}
+
+// size $size(x) is x, slice of x is not larger than x
+func (g *scriptGuard) size(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, _ []starlark.Tuple) (starlark.Value, error) {
+	if err := g.charge(scriptShallowSize(args[0])); err != nil {
+		return nil, err
+	}
+
+	return args[0], nil
+}
+
+// call $call(fn, args...) is fn(args...), calls of script functions allocate only their arguments
+func (g *scriptGuard) call(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	fn, args := args[0], args[1:]
+	size := scriptHeaderBytes + 8 * len(args) + 16 * len(kwargs)
+
+	if builtin, ok := fn.(*starlark.Builtin); ok {
+		size = scriptAddSize(size, scriptBuiltinSize(builtin, args, kwargs, g.remaining()), g.remaining())
+	}
+
+	if err := g.charge(size); err != nil {
+		return nil, err
+	}
+
+	return starlark.Call(thread, fn, args, kwargs)
+}
+
+func scriptGuardToken(value starlark.Value) syntax.Token {
+	op, _ := starlark.AsInt32(value)
+
+	return syntax.Token(op)
+}
+
+// scriptBinarySize estimate of bytes allocated by x op y
+func scriptBinarySize(op syntax.Token, x, y starlark.Value, limit int) int {
+	switch op {
+	case syntax.STAR:
+		if n, ok := y.(starlark.Int); ok && scriptIsRepeatable(x) {
+			return scriptRepeatSize(x, n, limit)
+		}
+
+		if n, ok := x.(starlark.Int); ok && scriptIsRepeatable(y) {
+			return scriptRepeatSize(y, n, limit)
+		}
+	case syntax.PERCENT:
+		// Every conversion may print the whole operand, like %(key)s of dict
+		if format, ok := x.(starlark.String); ok {
+			conversions := strings.Count(string(format), "%")
+
+			return scriptAddSize(len(format), scriptMulSize(conversions, scriptPrintedSize(y, limit, map[starlark.Value]bool{}), limit), limit)
+		}
+	}
+
+	return scriptAddSize(scriptShallowSize(x), scriptShallowSize(y), limit)
+}
+
+func scriptIsRepeatable(value starlark.Value) bool {
+	switch value.(type) {
+	case starlark.String, starlark.Bytes, starlark.Tuple, *starlark.List:
+		return true
+	}
+
+	return false
+}
+
+func scriptRepeatSize(value starlark.Value, n starlark.Int, limit int) int {
+	count, err := starlark.AsInt32(n)
+
+	if err != nil {
+		return limit + 1
+	}
+
+	return scriptMulSize(scriptShallowSize(value), count, limit)
+}
+
+// scriptBuiltinSize estimate of bytes allocated by builtin or method call
+func scriptBuiltinSize(builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple, limit int) int {
+	name := builtin.Name()
+
+	if scriptCheapBuiltins[name] {
+		return 0
+	}
+
+	if receiver, ok := builtin.Receiver().(starlark.String); ok {
+		switch name {
+		case "replace":
+			// Every occurrence of old may be replaced by new
+			if len(args) >= 2 {
+				old, _ := starlark.AsString(args[0])
+				replacement, _ := starlark.AsString(args[1])
+				occurrences := len(receiver) / int(math.Max(float64(len(old)), 1)) + 1
+
+				return scriptAddSize(len(receiver), scriptMulSize(occurrences, len(replacement), limit), limit)
+			}
+		case "format":
+			// Every field may print the whole arguments
+			fields := strings.Count(string(receiver), "{")
+
+			return scriptAddSize(len(receiver), scriptMulSize(fields, scriptArgumentsSize(args, kwargs, limit), limit), limit)
+		case "join":
+			// Separator is repeated between elements
+			if len(args) == 1 {
+				separators := 0
+
+				if sequence, ok := args[0].(starlark.Sequence); ok {
+					separators = sequence.Len()
+				}
+
+				return scriptAddSize(scriptMulSize(separators, len(receiver), limit), scriptArgumentsSize(args, kwargs, limit), limit)
+			}
+		case "split", "rsplit", "splitlines":
+			// Every byte may become a string
+			return scriptMulSize(2 * scriptHeaderBytes, len(receiver), limit)
+		}
+	}
+
+	size := scriptArgumentsSize(args, kwargs, limit)
+
+	if receiver := builtin.Receiver(); receiver != nil {
+		size = scriptAddSize(size, scriptShallowSize(receiver), limit)
+	}
+
+	return size
+}
+
+func scriptArgumentsSize(args starlark.Tuple, kwargs []starlark.Tuple, limit int) int {
+	size := 0
+
+	for _, arg := range args {
+		size = scriptAddSize(size, scriptPrintedSize(arg, limit - size, map[starlark.Value]bool{}), limit)
+	}
+
+	for _, kwarg := range kwargs {
+		size = scriptAddSize(size, scriptPrintedSize(kwarg[1], limit - size, map[starlark.Value]bool{}), limit)
+	}
+
+	return size
+}
+
+// scriptShallowSize approximate size of value without values it refers to, which is what copy of value allocates
+func scriptShallowSize(value starlark.Value) int {
+	switch value := value.(type) {
+	case starlark.String:
+		return scriptHeaderBytes + len(value)
+	case starlark.Bytes:
+		return scriptHeaderBytes + len(value)
+	case starlark.Int:
+		if _, ok := value.Int64(); !ok {
+			return scriptHeaderBytes + value.BigInt().BitLen() / 8
+		}
+	case starlark.Tuple:
+		return scriptHeaderBytes + 8 * len(value)
+	case *starlark.List:
+		return scriptHeaderBytes + 8 * value.Len()
+	case *starlark.Dict:
+		return scriptHeaderBytes + 48 * value.Len()
+	case *starlark.Set:
+		return scriptHeaderBytes + 48 * value.Len()
+	case starlark.Sequence:
+		// Lazy sequences like range, their elements are created when they are copied
+		return scriptHeaderBytes + 2 * scriptHeaderBytes * value.Len()
+	}
+
+	return scriptHeaderBytes
+}
+
+// scriptPrintedSize approximate size of value converted to string, including escapes of repr, value referred to
+// many times is printed many times, so it is counted many times too, containers in cycles are printed once.
+// Counting stops above limit
+func scriptPrintedSize(value starlark.Value, limit int, path map[starlark.Value]bool) int {
+	var items []starlark.Value
+
+	switch value := value.(type) {
+	case starlark.String:
+		return scriptHeaderBytes + 4 * len(value)
+	case starlark.Bytes:
+		return scriptHeaderBytes + 4 * len(value)
+	case starlark.Int:
+		if _, ok := value.Int64(); !ok {
+			return scriptHeaderBytes + value.BigInt().BitLen() / 3
+		}
+
+		return scriptHeaderBytes + 20
+	case starlark.Float:
+		return scriptHeaderBytes + 32
+	case starlark.Tuple:
+		items = value
+	case *starlark.List, *starlark.Dict, *starlark.Set:
+		if path[value] {
+			return scriptHeaderBytes
+		}
+
+		path[value] = true
+		defer delete(path, value)
+
+		iterator := value.(starlark.Iterable).Iterate()
+		defer iterator.Done()
+
+		var item starlark.Value
+
+		for iterator.Next(&item) {
+			items = append(items, item)
+
+			if dict, ok := value.(*starlark.Dict); ok {
+				entry, _, _ := dict.Get(item)
+				items = append(items, entry)
+			}
+		}
+	case starlark.Sequence:
+		return scriptShallowSize(value)
+	default:
+		return 4 * scriptHeaderBytes
+	}
+
+	size := scriptHeaderBytes
+
+	for _, item := range items {
+		if size > limit {
+			break
+		}
+
+		size = scriptAddSize(size, scriptPrintedSize(item, limit - size, path) + 2, limit)
+	}
+
+	return size
+}
+
+// scriptAddSize a + b, sizes above limit are limit + 1, so they don't overflow
+func scriptAddSize(a, b, limit int) int {
+	if a > limit || b > limit - a {
+		return limit + 1
+	}
+
+	return a + b
+}
+
+// scriptMulSize a * b, sizes above limit are limit + 1, so they don't overflow
+func scriptMulSize(a, b, limit int) int {
+	if a <= 0 || b <= 0 {
+		return 0
+	}
+
+	if a > limit / b {
+		return limit + 1
+	}
+
+	return a * b
+}
+
+// guardScript rewrites script, so operations allocating more than a few words call guard builtins
+func guardScript(file *syntax.File) error {
+	return guardStatements(file.Stmts)
+}
+
+func guardStatements(statements []syntax.Stmt) error {
+	for _, statement := range statements {
+		if err := guardStatement(statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func guardStatement(statement syntax.Stmt) error {
+	switch statement := statement.(type) {
+	case *syntax.AssignStmt:
+		if statement.Op != syntax.EQ {
+			// x op= y reads x twice, which is fine only when reading has no side effects
+			if !guardIsPure(statement.LHS) {
+				return fmt.Errorf("%s: augmented assignment to expression with calls is not supported", statement.OpPos)
+			}
+
+			op := statement.Op - syntax.PLUS_EQ + syntax.PLUS
+			statement.RHS = guardCall(scriptGuardAugmented, statement.OpPos, guardToken(op, statement.OpPos),
+				guardExpression(guardCopy(statement.LHS)), guardExpression(statement.RHS))
+		} else {
+			statement.RHS = guardExpression(statement.RHS)
+		}
+
+		guardTarget(statement.LHS)
+	case *syntax.DefStmt:
+		guardParameters(statement.Params)
+
+		return guardStatements(statement.Body)
+	case *syntax.ExprStmt:
+		statement.X = guardExpression(statement.X)
+	case *syntax.ForStmt:
+		guardTarget(statement.Vars)
+		statement.X = guardExpression(statement.X)
+
+		return guardStatements(statement.Body)
+	case *syntax.WhileStmt:
+		statement.Cond = guardExpression(statement.Cond)
+
+		return guardStatements(statement.Body)
+	case *syntax.IfStmt:
+		statement.Cond = guardExpression(statement.Cond)
+
+		if err := guardStatements(statement.True); err != nil {
+			return err
+		}
+
+		return guardStatements(statement.False)
+	case *syntax.ReturnStmt:
+		if statement.Result != nil {
+			statement.Result = guardExpression(statement.Result)
+		}
+	}
+
+	return nil
+}
+
+// guardTarget rewrites expressions read by assignment to target, target itself stays
+func guardTarget(target syntax.Expr) {
+	switch target := target.(type) {
+	case *syntax.IndexExpr:
+		target.X = guardExpression(target.X)
+		target.Y = guardExpression(target.Y)
+	case *syntax.DotExpr:
+		target.X = guardExpression(target.X)
+	case *syntax.ParenExpr:
+		guardTarget(target.X)
+	case *syntax.TupleExpr:
+		for _, item := range target.List {
+			guardTarget(item)
+		}
+	case *syntax.ListExpr:
+		for _, item := range target.List {
+			guardTarget(item)
+		}
+	}
+}
+
+// guardParameters rewrites default values, parameters are name, name=default, *args and **kwargs
+func guardParameters(parameters []syntax.Expr) {
+	for _, parameter := range parameters {
+		if parameter, ok := parameter.(*syntax.BinaryExpr); ok && parameter.Op == syntax.EQ {
+			parameter.Y = guardExpression(parameter.Y)
+		}
+	}
+}
+
+func guardExpression(expression syntax.Expr) syntax.Expr {
+	switch expression := expression.(type) {
+	case *syntax.BinaryExpr:
+		expression.X = guardExpression(expression.X)
+		expression.Y = guardExpression(expression.Y)
+
+		if scriptGuardedBinary[expression.Op] {
+			return guardCall(scriptGuardBinary, expression.OpPos, guardToken(expression.Op, expression.OpPos), expression.X, expression.Y)
+		}
+	case *syntax.UnaryExpr:
+		// Literals like -1 are small
+		if _, ok := expression.X.(*syntax.Literal); ok || expression.Op == syntax.NOT {
+			expression.X = guardExpression(expression.X)
+			break
+		}
+
+		return guardCall(scriptGuardUnary, expression.OpPos, guardToken(expression.Op, expression.OpPos), guardExpression(expression.X))
+	case *syntax.CallExpr:
+		arguments := []syntax.Expr{guardExpression(expression.Fn)}
+
+		// Arguments are value, name=value, *args and **kwargs
+		for _, argument := range expression.Args {
+			switch argument := argument.(type) {
+			case *syntax.BinaryExpr:
+				if argument.Op == syntax.EQ {
+					argument.Y = guardExpression(argument.Y)
+					arguments = append(arguments, argument)
+					continue
+				}
+			case *syntax.UnaryExpr:
+				if argument.Op == syntax.STAR || argument.Op == syntax.STARSTAR {
+					argument.X = guardExpression(argument.X)
+					arguments = append(arguments, argument)
+					continue
+				}
+			}
+
+			arguments = append(arguments, guardExpression(argument))
+		}
+
+		expression.Fn = &syntax.Ident{NamePos: expression.Lparen, Name: scriptGuardCall}
+		expression.Args = arguments
+	case *syntax.SliceExpr:
+		expression.X = guardCall(scriptGuardSize, expression.Lbrack, guardExpression(expression.X))
+		expression.Lo = guardOptional(expression.Lo)
+		expression.Hi = guardOptional(expression.Hi)
+		expression.Step = guardOptional(expression.Step)
+	case *syntax.IndexExpr:
+		expression.X = guardExpression(expression.X)
+		expression.Y = guardExpression(expression.Y)
+	case *syntax.DotExpr:
+		expression.X = guardExpression(expression.X)
+	case *syntax.ParenExpr:
+		expression.X = guardExpression(expression.X)
+	case *syntax.CondExpr:
+		expression.Cond = guardExpression(expression.Cond)
+		expression.True = guardExpression(expression.True)
+		expression.False = guardExpression(expression.False)
+	case *syntax.ListExpr:
+		for i, item := range expression.List {
+			expression.List[i] = guardExpression(item)
+		}
+	case *syntax.TupleExpr:
+		for i, item := range expression.List {
+			expression.List[i] = guardExpression(item)
+		}
+	case *syntax.DictExpr:
+		for _, entry := range expression.List {
+			guardExpression(entry)
+		}
+	case *syntax.DictEntry:
+		expression.Key = guardExpression(expression.Key)
+		expression.Value = guardExpression(expression.Value)
+	case *syntax.Comprehension:
+		expression.Body = guardExpression(expression.Body)
+
+		for _, clause := range expression.Clauses {
+			switch clause := clause.(type) {
+			case *syntax.ForClause:
+				guardTarget(clause.Vars)
+				clause.X = guardExpression(clause.X)
+			case *syntax.IfClause:
+				clause.Cond = guardExpression(clause.Cond)
+			}
+		}
+	case *syntax.LambdaExpr:
+		guardParameters(expression.Params)
+		expression.Body = guardExpression(expression.Body)
+	}
+
+	return expression
+}
+
+func guardOptional(expression syntax.Expr) syntax.Expr {
+	if expression == nil {
+		return nil
+	}
+
+	return guardExpression(expression)
+}
+
+func guardCall(name string, position syntax.Position, args ...syntax.Expr) syntax.Expr {
+	return &syntax.CallExpr{Fn: &syntax.Ident{NamePos: position, Name: name}, Lparen: position, Args: args, Rparen: position}
+}
+
+func guardToken(op syntax.Token, position syntax.Position) syntax.Expr {
+	return &syntax.Literal{Token: syntax.INT, TokenPos: position, Raw: strconv.Itoa(int(op)), Value: int64(op)}
+}
+
+// guardIsPure expression reads values without calls
+func guardIsPure(expression syntax.Expr) bool {
+	switch expression := expression.(type) {
+	case *syntax.Ident, *syntax.Literal:
+		return true
+	case *syntax.ParenExpr:
+		return guardIsPure(expression.X)
+	case *syntax.DotExpr:
+		return guardIsPure(expression.X)
+	case *syntax.IndexExpr:
+		return guardIsPure(expression.X) && guardIsPure(expression.Y)
+	case *syntax.BinaryExpr:
+		return guardIsPure(expression.X) && guardIsPure(expression.Y)
+	case *syntax.UnaryExpr:
+		return guardIsPure(expression.X)
+	}
+
+	return false
+}
+
+// guardCopy copy of pure expression, resolver annotates identifiers, so tree must not share them
+func guardCopy(expression syntax.Expr) syntax.Expr {
+	switch expression := expression.(type) {
+	case *syntax.Ident:
+		return &syntax.Ident{NamePos: expression.NamePos, Name: expression.Name}
+	case *syntax.Literal:
+		copied := *expression
+		return &copied
+	case *syntax.ParenExpr:
+		return &syntax.ParenExpr{Lparen: expression.Lparen, X: guardCopy(expression.X), Rparen: expression.Rparen}
+	case *syntax.DotExpr:
+		return &syntax.DotExpr{X: guardCopy(expression.X), Dot: expression.Dot, NamePos: expression.NamePos, Name: guardCopy(expression.Name).(*syntax.Ident)}
+	case *syntax.IndexExpr:
+		return &syntax.IndexExpr{X: guardCopy(expression.X), Lbrack: expression.Lbrack, Y: guardCopy(expression.Y), Rbrack: expression.Rbrack}
+	case *syntax.BinaryExpr:
+		return &syntax.BinaryExpr{X: guardCopy(expression.X), OpPos: expression.OpPos, Op: expression.Op, Y: guardCopy(expression.Y)}
+	case *syntax.UnaryExpr:
+		return &syntax.UnaryExpr{OpPos: expression.OpPos, Op: expression.Op, X: guardCopy(expression.X)}
+	}
+
+	return expression
+}
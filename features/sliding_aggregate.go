@@ -0,0 +1,87 @@
+package features
+
+// slidingAggregate is a FIFO queue which keeps aggregate of all its values, aggregate must be associative,
+// like merge of sketches or min of values. It is the two stacks technique: values are pushed onto back stack,
+// popped from front stack, which keeps aggregates of its suffixes, when front is empty back is moved there,
+// so every value is combined amortized O(1) times, while inverse operation is not needed at all
+type slidingAggregate struct {
+	// combine must not modify its arguments, older values are always the first argument
+	combine func(older interface{}, newer interface{}) interface{}
+
+	// front top is the oldest value, aggregates are of the value and all newer values in front
+	front []slidingAggregateItem
+	back []interface{}
+	backAggregate interface{}
+}
+
+type slidingAggregateItem struct {
+	value interface{}
+	aggregate interface{}
+}
+
+func (s *slidingAggregate) Push(value interface{}) {
+	s.back = append(s.back, value)
+
+	if len(s.back) == 1 {
+		s.backAggregate = value
+	} else {
+		s.backAggregate = s.combine(s.backAggregate, value)
+	}
+}
+
+// Pop removes the oldest value
+func (s *slidingAggregate) Pop() interface{} {
+	if len(s.front) == 0 {
+		s.flip()
+	}
+
+	item := s.front[len(s.front)-1]
+	s.front[len(s.front)-1] = slidingAggregateItem{}
+	s.front = s.front[:len(s.front)-1]
+
+	return item.value
+}
+
+// Front returns the oldest value
+func (s *slidingAggregate) Front() interface{} {
+	if len(s.front) == 0 {
+		s.flip()
+	}
+
+	return s.front[len(s.front)-1].value
+}
+
+func (s *slidingAggregate) Len() int {
+	return len(s.front) + len(s.back)
+}
+
+// Aggregate returns aggregate of all values from the oldest to the newest, nil for empty queue
+func (s *slidingAggregate) Aggregate() interface{} {
+	switch {
+	case len(s.front) == 0 && len(s.back) == 0:
+		return nil
+	case len(s.front) == 0:
+		return s.backAggregate
+	case len(s.back) == 0:
+		return s.front[len(s.front)-1].aggregate
+	default:
+		return s.combine(s.front[len(s.front)-1].aggregate, s.backAggregate)
+	}
+}
+
+// flip moves back stack to front, newest value goes to the bottom of front
+func (s *slidingAggregate) flip() {
+	for i := len(s.back) - 1; i >= 0; i-- {
+		item := slidingAggregateItem{value: s.back[i], aggregate: s.back[i]}
+
+		if len(s.front) > 0 {
+			item.aggregate = s.combine(s.back[i], s.front[len(s.front)-1].aggregate)
+		}
+
+		s.front = append(s.front, item)
+		s.back[i] = nil
+	}
+
+	s.back = s.back[:0]
+	s.backAggregate = nil
+}
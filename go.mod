@@ -3,7 +3,9 @@ module data-feature-engineer
 go 1.17
 
 require (
-	github.com/bits-and-blooms/bitset v1.2.1
 	github.com/gammazero/deque v0.1.0
 	github.com/shopspring/decimal v1.3.1
+	go.starlark.net v0.0.0-20230525235612-a134d8f9ddca
 )
+
+require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
@@ -0,0 +1,158 @@
+package main
+
+import (
+	dfeData "data-feature-engineer/data"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Run `go test -run TestGolden -update` to regenerate golden files after intended output changes
+var updateGolden = flag.Bool("update", false, "regenerate golden files in testdata/golden")
+
+// goldenDiffLimit amount of differing vectors shown in diff view
+const goldenDiffLimit = 10
+
+// replayGolden replays recorded stream through the full engine and formats every emitted vector as one line
+func replayGolden(data []*dfeData.InputData) ([]string, error) {
+	featureEngineer := bootstrapWindowFeatures((&FeatureEngineer{}).New(DefaultWindowSeconds), DefaultWindowSeconds)
+	var lines []string
+
+	scheduler := (&TickScheduler{}).New(DefaultTickSeconds, featureEngineer, func(vector Vector) {
+		fields := make([]string, 0, len(vector.Values) + 1)
+		fields = append(fields, strconv.FormatUint(vector.TimeCurrent, 10))
+
+		for _, value := range vector.Values {
+			fields = append(fields, value.String())
+		}
+
+		lines = append(lines, strings.Join(fields, " "))
+	})
+
+	if err := scheduler.Push(data...); err != nil {
+		return nil, err
+	}
+
+	if err := scheduler.Flush(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// diffGolden shows differing lines in unified-like form, -expected +actual
+func diffGolden(expected []string, actual []string) string {
+	builder := strings.Builder{}
+	shown := 0
+
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		var expectedLine, actualLine string
+
+		if i < len(expected) {
+			expectedLine = expected[i]
+		}
+
+		if i < len(actual) {
+			actualLine = actual[i]
+		}
+
+		if expectedLine == actualLine {
+			continue
+		}
+
+		if shown == goldenDiffLimit {
+			builder.WriteString("...\n")
+			break
+		}
+
+		shown++
+		builder.WriteString(fmt.Sprintf("@@ vector %d @@\n", i + 1))
+
+		if i < len(expected) {
+			builder.WriteString("-" + expectedLine + "\n")
+		}
+
+		if i < len(actual) {
+			builder.WriteString("+" + actualLine + "\n")
+		}
+	}
+
+	return builder.String()
+}
+
+func TestGolden(t *testing.T) {
+	streams, err := filepath.Glob(filepath.Join("testdata", "golden", "*.csv"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(streams) == 0 {
+		t.Fatal("TestGolden there are no recorded streams in testdata/golden")
+	}
+
+	for _, streamPath := range streams {
+		streamPath := streamPath
+		goldenPath := strings.TrimSuffix(streamPath, ".csv") + ".golden"
+
+		t.Run(filepath.Base(streamPath), func(t *testing.T) {
+			file, err := os.Open(streamPath)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer file.Close()
+
+			data, err := dfeData.ReadCSV(file)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := replayGolden(data)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if *updateGolden {
+				if err = os.WriteFile(goldenPath, []byte(strings.Join(actual, "\n") + "\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			encoded, err := os.ReadFile(goldenPath)
+
+			if err != nil {
+				t.Fatalf("%s, run with -update to create it", err)
+			}
+
+			expected := strings.Split(strings.TrimSuffix(string(encoded), "\n"), "\n")
+
+			if diff := diffGolden(expected, actual); diff != "" {
+				t.Errorf("TestGolden %s differs from %s (%d expected, %d actual vectors)\n%s",
+					streamPath, goldenPath, len(expected), len(actual), diff)
+			}
+		})
+	}
+}
+
+func TestDiffGolden(t *testing.T) {
+	diff := diffGolden([]string { "5 1 2", "10 1 2" }, []string { "5 1 2", "10 1 3", "15 1 3" })
+	await := "@@ vector 2 @@\n-10 1 2\n+10 1 3\n@@ vector 3 @@\n+15 1 3\n"
+
+	if diff != await {
+		t.Errorf("diffGolden should be %q, got %q", await, diff)
+	}
+
+	if diff = diffGolden([]string { "5 1 2" }, []string { "5 1 2" }); diff != "" {
+		t.Errorf("diffGolden of same vectors should be empty, got %q", diff)
+	}
+}
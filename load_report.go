@@ -0,0 +1,165 @@
+package main
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/features"
+	"data-feature-engineer/storage"
+	"encoding/json"
+	"os"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// LoadConfig describes one reproducible load run, same config always produces same input stream
+type LoadConfig struct {
+	Seed int64 `json:"seed"`
+	// Rate amount of data per second of stream time
+	Rate uint64 `json:"rate"`
+	// DurationSeconds amount of stream time to generate
+	DurationSeconds uint64 `json:"duration_seconds"`
+	TickSeconds uint64 `json:"tick_seconds"`
+	WindowSeconds []uint64 `json:"window_seconds"`
+	// Bars OHLC bars are emitted after statistics of windows
+	Bars bool `json:"bars"`
+	// Expressions derived features over named values of windows and bars, like `avg_5 / avg_3600 - 1`
+	Expressions []string `json:"expressions,omitempty"`
+	// Scripts sources of script features, every script is appended for every window after expressions
+	Scripts []string `json:"scripts,omitempty"`
+}
+
+// LoadReport is machine-readable result of a load run, it is written as JSON for regression tracking
+type LoadReport struct {
+	Config LoadConfig `json:"config"`
+	GoVersion string `json:"go_version"`
+	Features int `json:"features"`
+
+	InputTicks uint64 `json:"input_ticks"`
+	Emissions uint64 `json:"emissions"`
+	ElapsedNanoseconds int64 `json:"elapsed_ns"`
+	TicksPerSecond float64 `json:"ticks_per_second"`
+	AllocsPerTick float64 `json:"allocs_per_tick"`
+	BytesPerTick float64 `json:"bytes_per_tick"`
+
+	// Emission latency is time spent on processing one tick and building its vector
+	EmissionLatencyP50 int64 `json:"emission_latency_p50_ns"`
+	EmissionLatencyP95 int64 `json:"emission_latency_p95_ns"`
+	EmissionLatencyP99 int64 `json:"emission_latency_p99_ns"`
+	EmissionLatencyMax int64 `json:"emission_latency_max_ns"`
+}
+
+// latencyProcessor measures time of Update and Emit for each tick
+type latencyProcessor struct {
+	processor TickProcessor
+	started time.Time
+	latencies []int64
+}
+
+func (p *latencyProcessor) Update(TimeCurrent uint64, data []*dfedata.InputData) error {
+	p.started = time.Now()
+	return p.processor.Update(TimeCurrent, data)
+}
+
+func (p *latencyProcessor) Emit(TimeCurrent uint64, emit func(vector Vector)) {
+	p.processor.Emit(TimeCurrent, emit)
+	p.latencies = append(p.latencies, int64(time.Since(p.started)))
+}
+
+// RunLoad generates stream by config and pushes it through the full pipeline of window features
+func RunLoad(config LoadConfig) (report LoadReport, err error) {
+	if config.TickSeconds == 0 {
+		return report, errZeroTick
+	}
+
+	featureEngineer := (&FeatureEngineer{}).New(config.WindowSeconds)
+
+	// Features of every window are named by it, so repeated window fails
+	if err = featureEngineer.AppendWindowFeatures(config.WindowSeconds); err != nil {
+		return report, err
+	}
+
+	if config.Bars {
+		if err = featureEngineer.AppendBarFeatures(config.WindowSeconds); err != nil {
+			return report, err
+		}
+	}
+
+	for _, expression := range config.Expressions {
+		if err = featureEngineer.AppendExpressionFeature(expression); err != nil {
+			return report, err
+		}
+	}
+
+	for _, script := range config.Scripts {
+		for _, windowSeconds := range config.WindowSeconds {
+			featureEngineer.AppendFeature((&features.ScriptFeature{}).New(windowSeconds, &storage.LinkedListDataStorage{}, script, features.DefaultScriptLimits))
+		}
+	}
+
+	processor := &latencyProcessor{processor: featureEngineer, latencies: make([]int64, 0, config.DurationSeconds/config.TickSeconds+1)}
+
+	report.Config = config
+	report.GoVersion = runtime.Version()
+	report.Features = len(featureEngineer.Features)
+
+	scheduler := (&TickScheduler{}).New(config.TickSeconds, processor, func(vector Vector) {
+		report.Emissions++
+	})
+
+	generator := (&dfedata.Generator{}).New(config.Seed, config.Rate, 0)
+	buffer := make([]*dfedata.InputData, 0, config.Rate)
+
+	var memStatsBefore, memStatsAfter runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&memStatsBefore)
+	started := time.Now()
+
+	for second := uint64(0); second < config.DurationSeconds; second++ {
+		buffer = generator.NextSecond(buffer)
+
+		if err = scheduler.Push(buffer...); err != nil {
+			return
+		}
+
+		report.InputTicks += uint64(len(buffer))
+	}
+
+	if err = scheduler.Flush(); err != nil {
+		return
+	}
+
+	report.ElapsedNanoseconds = int64(time.Since(started))
+	runtime.ReadMemStats(&memStatsAfter)
+
+	if report.InputTicks > 0 {
+		report.TicksPerSecond = float64(report.InputTicks) / time.Duration(report.ElapsedNanoseconds).Seconds()
+		// Generator allocations are included, they are one InputData per tick
+		report.AllocsPerTick = float64(memStatsAfter.Mallocs - memStatsBefore.Mallocs) / float64(report.InputTicks)
+		report.BytesPerTick = float64(memStatsAfter.TotalAlloc - memStatsBefore.TotalAlloc) / float64(report.InputTicks)
+	}
+
+	latencies := processor.latencies
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+
+	if len(latencies) > 0 {
+		report.EmissionLatencyP50 = latencies[len(latencies) * 50 / 100]
+		report.EmissionLatencyP95 = latencies[len(latencies) * 95 / 100]
+		report.EmissionLatencyP99 = latencies[len(latencies) * 99 / 100]
+		report.EmissionLatencyMax = latencies[len(latencies) - 1]
+	}
+
+	return
+}
+
+// WriteLoadReport writes report as indented JSON into file
+func WriteLoadReport(path string, report LoadReport) error {
+	encoded, err := json.MarshalIndent(report, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(encoded, '\n'), 0644)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	dfeData "data-feature-engineer/data"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunLoad(t *testing.T) {
+	config := LoadConfig{Seed: 1, Rate: 10, DurationSeconds: 60, TickSeconds: DefaultTickSeconds, WindowSeconds: DefaultWindowSeconds}
+	report, err := RunLoad(config)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.InputTicks != 600 {
+		t.Errorf("RunLoad InputTicks should be 600, got %d", report.InputTicks)
+	}
+
+	// Stream starts at 0, so there are ticks 0, 5, ... 55 and flushed 60
+	if report.Emissions != 13 {
+		t.Errorf("RunLoad Emissions should be 13, got %d", report.Emissions)
+	}
+
+	if report.Features != 24 {
+		t.Errorf("RunLoad Features should be 24, got %d", report.Features)
+	}
+
+	if report.TicksPerSecond <= 0 || report.EmissionLatencyMax < report.EmissionLatencyP50 {
+		t.Errorf("RunLoad measurements are wrong %#v", report)
+	}
+
+	path := filepath.Join(t.TempDir(), "report.json")
+
+	if err = WriteLoadReport(path, report); err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := LoadReport{}
+
+	if err = json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.InputTicks != report.InputTicks || decoded.Config.Seed != config.Seed {
+		t.Errorf("WriteLoadReport wrote %s", encoded)
+	}
+
+	config.TickSeconds = 0
+
+	if _, err = RunLoad(config); err == nil {
+		t.Errorf("RunLoad should fail for tick of 0 seconds")
+	}
+
+	config.TickSeconds, config.WindowSeconds = DefaultTickSeconds, []uint64 { 5, 30, 5 }
+
+	if _, err = RunLoad(config); err == nil {
+		t.Errorf("RunLoad should fail for repeated window")
+	}
+}
+
+func TestRunLoad_Bars(t *testing.T) {
+	config := LoadConfig{Seed: 1, Rate: 10, DurationSeconds: 60, TickSeconds: DefaultTickSeconds, WindowSeconds: DefaultWindowSeconds, Bars: true}
+	report, err := RunLoad(config)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Statistics and a bar for every window
+	if report.Features != 5 * len(DefaultWindowSeconds) {
+		t.Errorf("RunLoad with bars should have %d features, got %d", 5 * len(DefaultWindowSeconds), report.Features)
+	}
+}
+
+// BenchmarkFeatureEngineer_Update measures one 5 second tick of the six window, four statistic pipeline
+func BenchmarkFeatureEngineer_Update(b *testing.B) {
+	for _, rate := range []uint64 { 100, 1000, 10000, 100500 } {
+		b.Run(fmt.Sprintf("rate=%d", rate), func(b *testing.B) {
+			featureEngineer := bootstrapWindowFeatures((&FeatureEngineer{}).New(DefaultWindowSeconds), DefaultWindowSeconds)
+			generator := (&dfeData.Generator{}).New(1, rate, 0)
+			batch := make([]*dfeData.InputData, 0, rate * DefaultTickSeconds)
+			second := make([]*dfeData.InputData, 0, rate)
+			elapsed := time.Duration(0)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				batch = batch[:0]
+
+				for s := 0; s < DefaultTickSeconds; s++ {
+					second = generator.NextSecond(second)
+					batch = append(batch, second...)
+				}
+
+				b.StartTimer()
+				started := time.Now()
+
+				if err := featureEngineer.Update(generator.Timestamp() - 1, batch); err != nil {
+					b.Fatal(err)
+				}
+
+				featureEngineer.GetVector()
+				elapsed += time.Since(started)
+			}
+
+			b.ReportMetric(float64(uint64(b.N) * rate * DefaultTickSeconds) / elapsed.Seconds(), "ticks/s")
+		})
+	}
+}
+
+// BenchmarkRunLoad whole load run, including generator and scheduler
+func BenchmarkRunLoad(b *testing.B) {
+	config := LoadConfig{Seed: 1, Rate: 100, DurationSeconds: 300, TickSeconds: DefaultTickSeconds, WindowSeconds: DefaultWindowSeconds}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		report, err := RunLoad(config)
+
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.ReportMetric(report.TicksPerSecond, "ticks/s")
+		b.ReportMetric(report.AllocsPerTick, "allocs/tick")
+		b.ReportMetric(float64(report.EmissionLatencyP99), "p99-ns/emission")
+	}
+}
+
+func TestRunLoad_Expressions(t *testing.T) {
+	config := LoadConfig{Seed: 1, Rate: 10, DurationSeconds: 60, TickSeconds: DefaultTickSeconds, WindowSeconds: DefaultWindowSeconds,
+		Expressions: []string { "(max_300 - min_300) / avg_300", "avg_5 / avg_3600 - 1" }}
+	report, err := RunLoad(config)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Features != 4 * len(DefaultWindowSeconds) + 2 {
+		t.Errorf("RunLoad with expressions should have %d features, got %d", 4 * len(DefaultWindowSeconds) + 2, report.Features)
+	}
+
+	config.Expressions = []string { "open_300 / avg_300" }
+
+	if _, err = RunLoad(config); err == nil {
+		t.Errorf("RunLoad should fail for expression over bars which are not emitted")
+	}
+}
+
+func TestRunLoad_Scripts(t *testing.T) {
+	script := `
+def calculate(state, price, timestamp):
+    state["n"] = state.get("n", 0) + 1
+
+def invalidate(state, price, timestamp):
+    state["n"] -= 1
+
+def value(state):
+    return state["n"]
+`
+	config := LoadConfig{Seed: 1, Rate: 10, DurationSeconds: 60, TickSeconds: DefaultTickSeconds, WindowSeconds: DefaultWindowSeconds,
+		Scripts: []string { script }}
+	report, err := RunLoad(config)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Features != 5 * len(DefaultWindowSeconds) {
+		t.Errorf("RunLoad with script should have %d features, got %d", 5 * len(DefaultWindowSeconds), report.Features)
+	}
+
+	config.Scripts = []string { "def value(state):\n    return 1" }
+
+	if _, err = RunLoad(config); err == nil {
+		t.Errorf("RunLoad should fail for script without calculate")
+	}
+}
@@ -1,4 +1,72 @@
 package main
 
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// stringsFlag flag which can be repeated
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
 func main() {
-}
\ No newline at end of file
+	seed := flag.Int64("seed", 1, "seed of generated stream")
+	rate := flag.Uint64("rate", 100, "amount of data per second of stream time")
+	duration := flag.Uint64("duration", 3600, "seconds of stream time to generate")
+	bars := flag.Bool("bars", false, "emit OHLC bars of windows after statistics")
+	var expressions stringsFlag
+	flag.Var(&expressions, "expression", "emit derived feature over named windows and bars, like avg_5 / avg_3600 - 1, can be repeated")
+	var scripts stringsFlag
+	flag.Var(&scripts, "script", "emit feature of Starlark script from this file for every window, can be repeated")
+	reportPath := flag.String("report", "", "write load report as JSON into this file")
+	flag.Parse()
+
+	var sources []string
+
+	for _, path := range scripts {
+		source, err := os.ReadFile(path)
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		sources = append(sources, string(source))
+	}
+
+	report, err := RunLoad(LoadConfig{
+		Seed: *seed,
+		Rate: *rate,
+		DurationSeconds: *duration,
+		TickSeconds: DefaultTickSeconds,
+		WindowSeconds: DefaultWindowSeconds,
+		Bars: *bars,
+		Expressions: expressions,
+		Scripts: sources,
+	})
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%d ticks, %.0f ticks/sec, %.1f allocs/tick, emission latency p50=%dns p99=%dns\n",
+		report.InputTicks, report.TicksPerSecond, report.AllocsPerTick, report.EmissionLatencyP50, report.EmissionLatencyP99)
+
+	if *reportPath != "" {
+		if err = WriteLoadReport(*reportPath, report); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package sketch
+
+import (
+	"math"
+	"sort"
+)
+
+// Centroid is a mean of Weight values
+type Centroid struct {
+	Mean float64
+	Weight float64
+}
+
+// TDigest is a merging t-digest (Dunning, Ertl), it keeps approximately Compression centroids,
+// which are small near the tails and large near the median, so extreme quantiles are more accurate.
+// Digests are mergeable: merging digests of two panes gives digest of their union, that is what makes
+// windowed quantiles cheap, window is merge of its panes
+type TDigest struct {
+	Compression float64
+
+	centroids []Centroid
+	unmerged []Centroid
+	count float64
+	min float64
+	max float64
+}
+
+// DefaultCompression gives roughly 1% rank error on the tails and much better near the median
+const DefaultCompression = 100
+
+func (t *TDigest) New(Compression float64) *TDigest {
+	t.Compression = Compression
+	t.Reset()
+	return t
+}
+
+func (t *TDigest) Reset() {
+	t.centroids = t.centroids[:0]
+	t.unmerged = t.unmerged[:0]
+	t.count = 0
+	t.min = math.Inf(1)
+	t.max = math.Inf(-1)
+}
+
+// Add adds value with weight 1
+func (t *TDigest) Add(value float64) {
+	t.AddWeighted(value, 1)
+}
+
+func (t *TDigest) AddWeighted(value float64, weight float64) {
+	t.unmerged = append(t.unmerged, Centroid{Mean: value, Weight: weight})
+	t.count += weight
+	t.min = math.Min(t.min, value)
+	t.max = math.Max(t.max, value)
+
+	// Buffer is compressed when it grows, so memory stays O(Compression)
+	if float64(len(t.unmerged)) > 5 * t.Compression {
+		t.compress()
+	}
+}
+
+// Merge adds all values of another digest, another digest is not modified
+func (t *TDigest) Merge(another *TDigest) {
+	if another.count == 0 {
+		return
+	}
+
+	t.unmerged = append(t.unmerged, another.centroids...)
+	t.unmerged = append(t.unmerged, another.unmerged...)
+	t.count += another.count
+	t.min = math.Min(t.min, another.min)
+	t.max = math.Max(t.max, another.max)
+
+	if float64(len(t.unmerged)) > 5 * t.Compression {
+		t.compress()
+	}
+}
+
+// Clone returns independent copy of the digest
+func (t *TDigest) Clone() *TDigest {
+	return &TDigest{
+		Compression: t.Compression,
+		centroids: append([]Centroid{}, t.centroids...),
+		unmerged: append([]Centroid{}, t.unmerged...),
+		count: t.count,
+		min: t.min,
+		max: t.max,
+	}
+}
+
+func (t *TDigest) Count() float64 {
+	return t.count
+}
+
+// Centroids returns compressed centroids sorted by mean
+func (t *TDigest) Centroids() []Centroid {
+	t.compress()
+	return t.centroids
+}
+
+// Quantile returns approximate value at quantile q in [0, 1], NaN for empty digest
+func (t *TDigest) Quantile(q float64) float64 {
+	if t.count == 0 {
+		return math.NaN()
+	}
+
+	t.compress()
+
+	if q <= 0 {
+		return t.min
+	}
+
+	if q >= 1 {
+		return t.max
+	}
+
+	// Every centroid is thought of as its weight spread evenly around its mean,
+	// so we interpolate between centers of neighbour centroids, tails are interpolated to min and max
+	target := q * t.count
+	cumulative := 0.0
+
+	for i, centroid := range t.centroids {
+		// Single values are exact
+		if centroid.Weight == 1 && target >= cumulative && target < cumulative + 1 {
+			return centroid.Mean
+		}
+
+		center := cumulative + centroid.Weight / 2
+
+		if target < center {
+			if i == 0 {
+				return interpolate(t.min, centroid.Mean, target / center)
+			}
+
+			previous := t.centroids[i-1]
+			previousCenter := cumulative - previous.Weight / 2
+
+			return interpolate(previous.Mean, centroid.Mean, (target - previousCenter) / (center - previousCenter))
+		}
+
+		cumulative += centroid.Weight
+	}
+
+	last := t.centroids[len(t.centroids)-1]
+	lastCenter := t.count - last.Weight / 2
+
+	return interpolate(last.Mean, t.max, (target - lastCenter) / (t.count - lastCenter))
+}
+
+func interpolate(from float64, to float64, fraction float64) float64 {
+	return from + (to - from) * fraction
+}
+
+// scale is k_1 scale function, centroid may span at most 1 unit of it
+func (t *TDigest) scale(q float64) float64 {
+	return t.Compression / (2 * math.Pi) * math.Asin(2 * q - 1)
+}
+
+func (t *TDigest) scaleInverse(k float64) float64 {
+	// k_1 is bounded by Compression / 4 at q = 1
+	if k >= t.Compression / 4 {
+		return 1
+	}
+
+	return (math.Sin(k * 2 * math.Pi / t.Compression) + 1) / 2
+}
+
+func (t *TDigest) compress() {
+	if len(t.unmerged) == 0 {
+		return
+	}
+
+	all := append(t.unmerged, t.centroids...)
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Mean < all[j].Mean
+	})
+
+	result := make([]Centroid, 0, len(t.centroids) + 1)
+	weightSoFar := 0.0
+	weightLimit := t.count * t.scaleInverse(t.scale(0) + 1)
+	current := all[0]
+
+	for _, next := range all[1:] {
+		if weightSoFar + current.Weight + next.Weight <= weightLimit {
+			current.Weight += next.Weight
+			current.Mean += (next.Mean - current.Mean) * next.Weight / current.Weight
+			continue
+		}
+
+		weightSoFar += current.Weight
+		result = append(result, current)
+		weightLimit = t.count * t.scaleInverse(t.scale(weightSoFar / t.count) + 1)
+		current = next
+	}
+
+	t.centroids = append(result, current)
+	t.unmerged = all[:0]
+}
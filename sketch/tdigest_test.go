@@ -0,0 +1,118 @@
+package sketch
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// rankOf returns fraction of sorted values which are less than value
+func rankOf(sorted []float64, value float64) float64 {
+	return float64(sort.SearchFloat64s(sorted, value)) / float64(len(sorted))
+}
+
+func checkQuantiles(t *testing.T, name string, digest *TDigest, sorted []float64, maxRankError float64) {
+	for _, q := range []float64{0.001, 0.01, 0.05, 0.25, 0.5, 0.75, 0.95, 0.99, 0.999} {
+		value := digest.Quantile(q)
+		// Equal values make rank an interval, value is right if q is inside it
+		rankFrom := rankOf(sorted, value)
+		rankTo := float64(sort.Search(len(sorted), func(i int) bool { return sorted[i] > value })) / float64(len(sorted))
+
+		if q < rankFrom - maxRankError || q > rankTo + maxRankError {
+			t.Errorf("%s Quantile(%f) = %f has rank [%f, %f]", name, q, value, rankFrom, rankTo)
+		}
+	}
+}
+
+func TestTDigest_Quantile(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	distributions := map[string]func() float64{
+		"uniform": random.Float64,
+		"normal": random.NormFloat64,
+		"exponential": random.ExpFloat64,
+		// Prices have a lot of equal values
+		"discrete": func() float64 { return 65000 + float64(random.Intn(20)) },
+	}
+
+	for name, distribution := range distributions {
+		digest := (&TDigest{}).New(DefaultCompression)
+		values := make([]float64, 0, 100000)
+
+		for i := 0; i < 100000; i++ {
+			value := distribution()
+			values = append(values, value)
+			digest.Add(value)
+		}
+
+		sort.Float64s(values)
+		checkQuantiles(t, name, digest, values, 0.01)
+
+		if digest.Quantile(0) != values[0] || digest.Quantile(1) != values[len(values)-1] {
+			t.Errorf("%s Quantile(0) and Quantile(1) should be min and max", name)
+		}
+
+		if centroids := len(digest.Centroids()); centroids > DefaultCompression {
+			t.Errorf("%s digest should be compressed to about %d centroids, got %d", name, DefaultCompression, centroids)
+		}
+	}
+}
+
+func TestTDigest_Merge(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	merged := (&TDigest{}).New(DefaultCompression)
+	var values []float64
+
+	// Panes of different sizes and distributions
+	for pane := 0; pane < 50; pane++ {
+		digest := (&TDigest{}).New(DefaultCompression)
+		shift := float64(pane)
+
+		for i := 0; i < 100 + pane * 37; i++ {
+			value := random.NormFloat64() + shift
+			values = append(values, value)
+			digest.Add(value)
+		}
+
+		count := digest.Count()
+		merged.Merge(digest)
+
+		if digest.Count() != count {
+			t.Errorf("TDigest.Merge modified merged digest")
+		}
+	}
+
+	sort.Float64s(values)
+	checkQuantiles(t, "merged", merged, values, 0.01)
+
+	if merged.Count() != float64(len(values)) {
+		t.Errorf("TDigest.Merge count should be %d, got %f", len(values), merged.Count())
+	}
+}
+
+func TestTDigest_Quantile_Small(t *testing.T) {
+	digest := (&TDigest{}).New(DefaultCompression)
+
+	if !math.IsNaN(digest.Quantile(0.5)) {
+		t.Errorf("TDigest.Quantile of empty digest should be NaN")
+	}
+
+	for _, value := range []float64{5, 1, 4, 2, 3} {
+		digest.Add(value)
+	}
+
+	// Few values are kept as they are, so quantiles are exact
+	for q, expected := range map[float64]float64{0: 1, 0.1: 1, 0.5: 3, 0.7: 4, 1: 5} {
+		if actual := digest.Quantile(q); actual != expected {
+			t.Errorf("TDigest.Quantile(%f) should be %f, got %f", q, expected, actual)
+		}
+	}
+
+	clone := digest.Clone()
+	clone.Add(100)
+
+	if digest.Quantile(1) != 5 {
+		t.Errorf("TDigest.Clone is not independent")
+	}
+}
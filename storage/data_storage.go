@@ -54,21 +54,8 @@ func (storage *LinkedListDataStorage) Iterate() []*dfedata.InputData {
 }
 
 func (storage *LinkedListDataStorage) Clone() InputDataStorage {
-	// Empty list
-	if storage.item == nil {
-		return &LinkedListDataStorage{item: nil, length: 0, head: nil}
-	}
-
-	item := storage.item.Clone()
-	resultItem := item
-
-	for item.prev != nil {
-		newItem := item.prev.Clone()
-		item.prev = newItem
-		item = item.prev
-	}
-
-	return &LinkedListDataStorage{item: resultItem, length: storage.length, head: storage.head.Clone()}
+	result, _ := storage.CloneWithLookup()
+	return result
 }
 
 // CloneWithLookup is O(N)
@@ -97,6 +84,8 @@ func (storage *LinkedListDataStorage) CloneWithLookup(lookupValues ...*LinkedLis
 		if position, ok := mapLookupValues[item.prev]; ok {
 			resultLookupValues[position] = newItem
 		}
+		// Cloned item must point forward to the clone, not to the original list
+		newItem.next = item
 		item.prev = newItem
 		item = item.prev
 	}
@@ -227,36 +216,20 @@ func (storage *LinkedListDataStorage) InvalidateDataBeforeTimestamp(beforeTimest
 
 	item := result.head
 
-	// data must be sorted
-	for item != nil {
-		if item.data.Timestamp < beforeTimestamp {
-			// remove
-
-			if item.next != nil {
-				if item == result.head {
-					result.head = item.next
-				}
-				item.next.prev = item.prev
-			}
-
-			if item.prev != nil {
-				item.prev = nil
-			}
-
-			if result.length != 0 {
-				result.length -= 1
-				result.item = item.next
-			} else {
-				result.item = nil
-				result.head = nil
-			}
-		} else {
-			// No need to continue on sorted list
-			break
-		}
-
+	// data must be sorted, so we just move head forward
+	for item != nil && item.data.Timestamp < beforeTimestamp {
 		item = item.next
+		result.length -= 1
+	}
+
+	if item == nil {
+		result.item = nil
+		result.head = nil
+		result.length = 0
+	} else {
+		item.prev = nil
+		result.head = item
 	}
 
 	return result
-}
\ No newline at end of file
+}
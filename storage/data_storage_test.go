@@ -140,4 +140,39 @@ func TestLinkedListDataStorage_InvalidateDataBeforeTimestamp(t *testing.T) {
 	if !reflect.DeepEqual(newList.Iterate(), data[2:5]) {
 		t.Errorf("LinkedListDataStorage.InvalidateDataBeforeTimestamp(100), items were not invalidated %#v != %#v", newList.Iterate(), data[2:5])
 	}
-}
\ No newline at end of file
+}
+
+func TestLinkedListDataStorage_InvalidateDataBeforeTimestamp_Append(t *testing.T) {
+	list := bootstrap_linked_list()
+
+	data := []*dfedata.InputData{
+		{DecimalCost: decimal.NewFromInt(10), Timestamp: 1},
+		{DecimalCost: decimal.NewFromInt(10), Timestamp: 2},
+		{DecimalCost: decimal.NewFromInt(10), Timestamp: 3},
+		{DecimalCost: decimal.NewFromInt(10), Timestamp: 4},
+		{DecimalCost: decimal.NewFromInt(10), Timestamp: 11},
+	}
+
+	list = list.Append(data[0:3])
+	invalidated := list.InvalidateDataBeforeTimestamp(2)
+	appended := invalidated.Append(data[3:4])
+
+	if !reflect.DeepEqual(appended.Iterate(), data[1:4]) {
+		t.Errorf("LinkedListDataStorage.Append() after invalidation lost items %#v != %#v", appended.Iterate(), data[1:4])
+	}
+
+	// Clones are independent in both directions
+	if !reflect.DeepEqual(invalidated.Iterate(), data[1:3]) || !reflect.DeepEqual(list.Iterate(), data[0:3]) {
+		t.Errorf("LinkedListDataStorage.Append() mutated previous lists %#v, %#v", invalidated.Iterate(), list.Iterate())
+	}
+
+	empty := appended.InvalidateDataBeforeTimestamp(10)
+
+	if len(empty.Iterate()) != 0 || empty.(*LinkedListDataStorage).length != 0 {
+		t.Errorf("LinkedListDataStorage.InvalidateDataBeforeTimestamp(10) should remove all items, got %#v", empty.Iterate())
+	}
+
+	if !reflect.DeepEqual(empty.Append(data[4:]).Iterate(), data[4:]) {
+		t.Errorf("LinkedListDataStorage.Append() to emptied list is wrong %#v", empty.Append(data[4:]).Iterate())
+	}
+}
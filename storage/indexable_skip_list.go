@@ -0,0 +1,204 @@
+package storage
+
+import (
+	dfedata "data-feature-engineer/data"
+	"github.com/shopspring/decimal"
+	"math/rand"
+)
+
+const (
+	skipListMaxLevel = 32
+	// skipListProbability of a node to be promoted to the next level
+	skipListProbability = 0.25
+)
+
+// IndexableSkipList keeps InputData sorted by DecimalCost and gives access by rank, both in O(log N),
+// so any order statistic of sliding window is available without sorting it on every tick.
+// Every link knows how many nodes it skips (span), that is what makes it indexable.
+// Data are identified by pointers, equal costs are ordered by insertion
+type IndexableSkipList struct {
+	head *skipListNode
+	level int
+	length int
+
+	random *rand.Rand
+	sequence uint64
+	// sequences of inserted data, they break ties of equal costs and let us find node of the pointer
+	sequences map[*dfedata.InputData]uint64
+}
+
+type skipListNode struct {
+	data *dfedata.InputData
+	sequence uint64
+
+	next []*skipListNode
+	span []int
+}
+
+func (l *IndexableSkipList) New() *IndexableSkipList {
+	l.head = &skipListNode{next: make([]*skipListNode, skipListMaxLevel), span: make([]int, skipListMaxLevel)}
+	l.level = 1
+	l.length = 0
+	// Fixed seed, so structure and performance are reproducible
+	l.random = rand.New(rand.NewSource(1))
+	l.sequences = make(map[*dfedata.InputData]uint64)
+	return l
+}
+
+func (l *IndexableSkipList) Len() int {
+	return l.length
+}
+
+// less orders nodes by cost, then by insertion
+func (n *skipListNode) less(data *dfedata.InputData, sequence uint64) bool {
+	if comparison := n.data.DecimalCost.Cmp(data.DecimalCost); comparison != 0 {
+		return comparison < 0
+	}
+
+	return n.sequence < sequence
+}
+
+func (l *IndexableSkipList) randomLevel() int {
+	level := 1
+
+	for level < skipListMaxLevel && l.random.Float64() < skipListProbability {
+		level++
+	}
+
+	return level
+}
+
+// Insert adds data, inserting the same pointer twice is ignored
+func (l *IndexableSkipList) Insert(data *dfedata.InputData) {
+	if _, ok := l.sequences[data]; ok {
+		return
+	}
+
+	l.sequence++
+	l.sequences[data] = l.sequence
+
+	var update [skipListMaxLevel]*skipListNode
+	var rank [skipListMaxLevel]int
+	node := l.head
+
+	for i := l.level - 1; i >= 0; i-- {
+		if i < l.level - 1 {
+			rank[i] = rank[i+1]
+		}
+
+		for node.next[i] != nil && node.next[i].less(data, l.sequence) {
+			rank[i] += node.span[i]
+			node = node.next[i]
+		}
+
+		update[i] = node
+	}
+
+	level := l.randomLevel()
+
+	if level > l.level {
+		for i := l.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = l.head
+			update[i].span[i] = l.length
+		}
+
+		l.level = level
+	}
+
+	inserted := &skipListNode{data: data, sequence: l.sequence, next: make([]*skipListNode, level), span: make([]int, level)}
+
+	for i := 0; i < level; i++ {
+		inserted.next[i] = update[i].next[i]
+		update[i].next[i] = inserted
+
+		inserted.span[i] = update[i].span[i] - (rank[0] - rank[i])
+		update[i].span[i] = rank[0] - rank[i] + 1
+	}
+
+	// Upper links now skip one more node
+	for i := level; i < l.level; i++ {
+		update[i].span[i]++
+	}
+
+	l.length++
+}
+
+// Remove removes data by pointer, returns false if it is not there
+func (l *IndexableSkipList) Remove(data *dfedata.InputData) bool {
+	sequence, ok := l.sequences[data]
+
+	if !ok {
+		return false
+	}
+
+	var update [skipListMaxLevel]*skipListNode
+	node := l.head
+
+	for i := l.level - 1; i >= 0; i-- {
+		for node.next[i] != nil && node.next[i].less(data, sequence) {
+			node = node.next[i]
+		}
+
+		update[i] = node
+	}
+
+	removed := node.next[0]
+
+	for i := 0; i < l.level; i++ {
+		if update[i].next[i] == removed {
+			update[i].span[i] += removed.span[i] - 1
+			update[i].next[i] = removed.next[i]
+		} else {
+			update[i].span[i]--
+		}
+	}
+
+	for l.level > 1 && l.head.next[l.level-1] == nil {
+		l.level--
+	}
+
+	delete(l.sequences, data)
+	l.length--
+
+	return true
+}
+
+// CountLess returns amount of data cheaper than cost
+func (l *IndexableSkipList) CountLess(cost decimal.Decimal) int {
+	traversed := 0
+	node := l.head
+
+	for i := l.level - 1; i >= 0; i-- {
+		for node.next[i] != nil && node.next[i].data.DecimalCost.LessThan(cost) {
+			traversed += node.span[i]
+			node = node.next[i]
+		}
+	}
+
+	return traversed
+}
+
+// Get returns data at rank index (0 is the cheapest), nil when index is out of range
+func (l *IndexableSkipList) Get(index int) *dfedata.InputData {
+	if index < 0 || index >= l.length {
+		return nil
+	}
+
+	// Ranks of nodes are 1-based, head has rank 0
+	traversed := 0
+	node := l.head
+
+	for i := l.level - 1; i >= 0; i-- {
+		for node.next[i] != nil && traversed + node.span[i] <= index + 1 {
+			traversed += node.span[i]
+			node = node.next[i]
+		}
+
+		if traversed == index + 1 {
+			return node.data
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	dfedata "data-feature-engineer/data"
+	"github.com/shopspring/decimal"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestIndexableSkipList(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	list := (&IndexableSkipList{}).New()
+	var expected []*dfedata.InputData
+
+	for step := 0; step < 5000; step++ {
+		// Mostly inserts, so list grows, and removals from random positions
+		if len(expected) == 0 || random.Intn(3) > 0 {
+			data := &dfedata.InputData{DecimalCost: decimal.NewFromInt(random.Int63n(100)), Timestamp: uint64(step)}
+			list.Insert(data)
+			expected = append(expected, data)
+		} else {
+			index := random.Intn(len(expected))
+
+			if !list.Remove(expected[index]) {
+				t.Fatalf("IndexableSkipList.Remove(%s) should find inserted data", expected[index])
+			}
+
+			expected = append(expected[:index], expected[index+1:]...)
+		}
+
+		if step % 100 != 0 {
+			continue
+		}
+
+		// Equal costs are ordered by insertion, which is Timestamp here
+		sorted := append([]*dfedata.InputData{}, expected...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if !sorted[i].DecimalCost.Equal(sorted[j].DecimalCost) {
+				return sorted[i].DecimalCost.LessThan(sorted[j].DecimalCost)
+			}
+
+			return sorted[i].Timestamp < sorted[j].Timestamp
+		})
+
+		if list.Len() != len(sorted) {
+			t.Fatalf("IndexableSkipList.Len should be %d, got %d", len(sorted), list.Len())
+		}
+
+		for index, data := range sorted {
+			if list.Get(index) != data {
+				t.Fatalf("IndexableSkipList.Get(%d) should be %s, got %s", index, data, list.Get(index))
+			}
+		}
+
+		cost := decimal.NewFromInt(random.Int63n(100))
+		less := sort.Search(len(sorted), func(i int) bool { return !sorted[i].DecimalCost.LessThan(cost) })
+
+		if list.CountLess(cost) != less {
+			t.Fatalf("IndexableSkipList.CountLess(%s) should be %d, got %d", cost, less, list.CountLess(cost))
+		}
+	}
+
+	if list.Get(-1) != nil || list.Get(list.Len()) != nil {
+		t.Errorf("IndexableSkipList.Get out of range should be nil")
+	}
+
+	if list.Remove(&dfedata.InputData{}) {
+		t.Errorf("IndexableSkipList.Remove should not remove data which was not inserted")
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	dfedata "data-feature-engineer/data"
+	"data-feature-engineer/features"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"sort"
+)
+
+// StreamEngine keeps independent aggregator and features for every stream key (venue and instrument),
+// FeatureEngineer for a key is created lazily on its first data and evicted when the key is idle
+type StreamEngine struct {
+	// NewFeatureEngineer creates pipeline for a new key
+	NewFeatureEngineer func(key dfedata.StreamKey) *FeatureEngineer
+	// IdleSeconds key without data for longer than that is evicted, 0 means keys are never evicted
+	IdleSeconds uint64
+
+	streams map[dfedata.StreamKey]*keyedStream
+	// keys are sorted, so vectors are emitted in deterministic order
+	keys []dfedata.StreamKey
+	// pairs are in order they were appended
+	pairs []*pairStream
+}
+
+// pairStream features of two keys, they are emitted as one vector
+type pairStream struct {
+	first dfedata.StreamKey
+	second dfedata.StreamKey
+	features []features.PairFeature
+}
+
+type keyedStream struct {
+	featureEngineer *FeatureEngineer
+	lastDataTimestamp uint64
+	// batch is reused between ticks
+	batch []*dfedata.InputData
+}
+
+func (e *StreamEngine) New(newFeatureEngineer func(key dfedata.StreamKey) *FeatureEngineer, IdleSeconds uint64) *StreamEngine {
+	e.NewFeatureEngineer = newFeatureEngineer
+	e.IdleSeconds = IdleSeconds
+	e.streams = make(map[dfedata.StreamKey]*keyedStream)
+	return e
+}
+
+// Update splits data by key and updates every known key, keys without data this tick still get updated,
+// so they carry their last values forward until they are evicted. Error of one key doesn't stop the tick,
+// other keys, pairs and eviction are still updated and the first error is returned at the end
+func (e *StreamEngine) Update(TimeCurrent uint64, data []*dfedata.InputData) (err error) {
+	for _, stream := range e.streams {
+		stream.batch = stream.batch[:0]
+	}
+
+	for _, log := range data {
+		key := log.Key()
+		stream, ok := e.streams[key]
+
+		if !ok {
+			stream = &keyedStream{featureEngineer: e.NewFeatureEngineer(key)}
+			e.streams[key] = stream
+			e.insertKey(key)
+		}
+
+		stream.batch = append(stream.batch, log)
+
+		if log.Timestamp > stream.lastDataTimestamp {
+			stream.lastDataTimestamp = log.Timestamp
+		}
+	}
+
+	for _, key := range e.keys {
+		if keyErr := e.streams[key].featureEngineer.Update(TimeCurrent, e.streams[key].batch); keyErr != nil && err == nil {
+			err = fmt.Errorf("stream %s: %w", key, keyErr)
+		}
+	}
+
+	for _, pair := range e.pairs {
+		first, second := e.batchOf(pair.first), e.batchOf(pair.second)
+
+		for _, feature := range pair.features {
+			feature.UpdatePair(TimeCurrent, first, second)
+		}
+	}
+
+	e.evictIdle(TimeCurrent)
+
+	return err
+}
+
+// AppendPairFeature appends feature of the first key to the second one, features of the same keys share one vector,
+// pair features keep their own state, so they are not evicted with idle keys
+func (e *StreamEngine) AppendPairFeature(first dfedata.StreamKey, second dfedata.StreamKey, feature features.PairFeature) {
+	for _, pair := range e.pairs {
+		if pair.first == first && pair.second == second {
+			pair.features = append(pair.features, feature)
+			return
+		}
+	}
+
+	e.pairs = append(e.pairs, &pairStream{first: first, second: second, features: []features.PairFeature{feature}})
+}
+
+// batchOf data of the key this tick, nil when the key is not tracked
+func (e *StreamEngine) batchOf(key dfedata.StreamKey) []*dfedata.InputData {
+	if stream, ok := e.streams[key]; ok {
+		return stream.batch
+	}
+
+	return nil
+}
+
+// Emit emits one vector per key, tagged by the key, then one vector per pair of keys, tagged by both keys
+func (e *StreamEngine) Emit(TimeCurrent uint64, emit func(vector Vector)) {
+	for _, key := range e.keys {
+		emit(Vector{Key: key, TimeCurrent: TimeCurrent, Values: e.streams[key].featureEngineer.GetVector()})
+	}
+
+	for _, pair := range e.pairs {
+		var values []decimal.Decimal
+
+		for _, feature := range pair.features {
+			values = append(values, feature.GetValues()...)
+		}
+
+		emit(Vector{Key: pair.first, Pair: pair.second, TimeCurrent: TimeCurrent, Values: values})
+	}
+}
+
+// Keys returns keys which are currently tracked in sorted order
+func (e *StreamEngine) Keys() []dfedata.StreamKey {
+	return append([]dfedata.StreamKey{}, e.keys...)
+}
+
+// GetFeatureEngineer returns pipeline of the key, nil if the key is not tracked
+func (e *StreamEngine) GetFeatureEngineer(key dfedata.StreamKey) *FeatureEngineer {
+	if stream, ok := e.streams[key]; ok {
+		return stream.featureEngineer
+	}
+
+	return nil
+}
+
+func (e *StreamEngine) insertKey(key dfedata.StreamKey) {
+	index := sort.Search(len(e.keys), func(i int) bool {
+		return !e.keys[i].Less(key)
+	})
+
+	e.keys = append(e.keys, dfedata.StreamKey{})
+	copy(e.keys[index+1:], e.keys[index:])
+	e.keys[index] = key
+}
+
+func (e *StreamEngine) evictIdle(TimeCurrent uint64) {
+	if e.IdleSeconds == 0 {
+		return
+	}
+
+	keys := e.keys[:0]
+
+	for _, key := range e.keys {
+		if TimeCurrent > e.streams[key].lastDataTimestamp + e.IdleSeconds {
+			delete(e.streams, key)
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	e.keys = keys
+}
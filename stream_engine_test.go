@@ -0,0 +1,205 @@
+package main
+
+import (
+	dfeData "data-feature-engineer/data"
+	"data-feature-engineer/features"
+	"github.com/shopspring/decimal"
+	"reflect"
+	"testing"
+)
+
+func bootstrapStreamEngine(IdleSeconds uint64) (*StreamEngine, map[dfeData.StreamKey]int) {
+	created := map[dfeData.StreamKey]int{}
+
+	engine := (&StreamEngine{}).New(func(key dfeData.StreamKey) *FeatureEngineer {
+		created[key]++
+		return bootstrapWindowFeatures((&FeatureEngineer{}).New([]uint64 { 5, 30 }), []uint64 { 5, 30 })
+	}, IdleSeconds)
+
+	return engine, created
+}
+
+func TestStreamEngine_Update(t *testing.T) {
+	engine, created := bootstrapStreamEngine(0)
+	btc := dfeData.StreamKey{Venue: "binance", Instrument: "BTC/USD"}
+	eth := dfeData.StreamKey{Venue: "binance", Instrument: "ETH/USD"}
+
+	data := []*dfeData.InputData {
+		{DecimalCost: decimal.NewFromInt(4000), Timestamp: 4, Venue: eth.Venue, Instrument: eth.Instrument},
+		{DecimalCost: decimal.NewFromInt(65000), Timestamp: 4, Venue: btc.Venue, Instrument: btc.Instrument},
+		{DecimalCost: decimal.NewFromInt(65010), Timestamp: 5, Venue: btc.Venue, Instrument: btc.Instrument},
+	}
+
+	if err := engine.Update(5, data); err != nil {
+		t.Fatal(err)
+	}
+
+	var vectors []Vector
+	engine.Emit(5, func(vector Vector) {
+		vectors = append(vectors, vector)
+	})
+
+	if len(vectors) != 2 || vectors[0].Key != btc || vectors[1].Key != eth {
+		t.Fatalf("StreamEngine.Emit should emit vectors for %s and %s in order, got %v", btc, eth, vectors)
+	}
+
+	// Streams are independent, max of BTC is not affected by ETH
+	if !vectors[0].Values[1].Equal(decimal.NewFromInt(65010)) || !vectors[1].Values[1].Equal(decimal.NewFromInt(4000)) {
+		t.Errorf("StreamEngine.Update max values are wrong %v", vectors)
+	}
+
+	// ETH has no data on this tick, but it is still updated and emitted with carried value
+	if err := engine.Update(10, data[2:2]); err != nil {
+		t.Fatal(err)
+	}
+
+	vectors = vectors[:0]
+	engine.Emit(10, func(vector Vector) {
+		vectors = append(vectors, vector)
+	})
+
+	if len(vectors) != 2 || !vectors[1].Values[1].Equal(decimal.NewFromInt(4000)) {
+		t.Errorf("StreamEngine.Update should carry values of idle key, got %v", vectors)
+	}
+
+	if !reflect.DeepEqual(map[dfeData.StreamKey]int{btc: 1, eth: 1}, created) {
+		t.Errorf("StreamEngine.Update should create pipeline once per key, got %v", created)
+	}
+}
+
+func TestStreamEngine_Update_EvictsIdle(t *testing.T) {
+	engine, created := bootstrapStreamEngine(10)
+	btc := dfeData.StreamKey{Venue: "binance", Instrument: "BTC/USD"}
+	eth := dfeData.StreamKey{Venue: "kraken", Instrument: "ETH/USD"}
+
+	ticks := []struct {
+		TimeCurrent uint64
+		input []*dfeData.InputData
+		expected []dfeData.StreamKey
+	}{
+		{5, []*dfeData.InputData {
+			{DecimalCost: decimal.NewFromInt(4000), Timestamp: 5, Venue: eth.Venue, Instrument: eth.Instrument},
+			{DecimalCost: decimal.NewFromInt(65000), Timestamp: 5, Venue: btc.Venue, Instrument: btc.Instrument},
+		}, []dfeData.StreamKey { btc, eth }},
+		{10, []*dfeData.InputData {
+			{DecimalCost: decimal.NewFromInt(65000), Timestamp: 10, Venue: btc.Venue, Instrument: btc.Instrument},
+		}, []dfeData.StreamKey { btc, eth }},
+		{15, []*dfeData.InputData {}, []dfeData.StreamKey { btc, eth }},
+		// ETH was idle for more than 10 seconds
+		{20, []*dfeData.InputData {}, []dfeData.StreamKey { btc }},
+		// ETH comes back with a fresh pipeline
+		{25, []*dfeData.InputData {
+			{DecimalCost: decimal.NewFromInt(4100), Timestamp: 25, Venue: eth.Venue, Instrument: eth.Instrument},
+		}, []dfeData.StreamKey { eth }},
+	}
+
+	for i, tick := range ticks {
+		if err := engine.Update(tick.TimeCurrent, tick.input); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(tick.expected, engine.Keys()) {
+			t.Errorf("StreamEngine.Update tick %d keys should be %v, got %v", i, tick.expected, engine.Keys())
+		}
+	}
+
+	if created[eth] != 2 || engine.GetFeatureEngineer(btc) != nil {
+		t.Errorf("StreamEngine.Update should recreate evicted key, created %v", created)
+	}
+
+	values := engine.GetFeatureEngineer(eth).GetVector()
+
+	if !values[1].Equal(decimal.NewFromInt(4100)) {
+		t.Errorf("StreamEngine.Update recreated pipeline should not keep old values, got %v", values)
+	}
+}
+
+// Failed key doesn't stop the tick for other keys and eviction
+func TestStreamEngine_Update_KeyError(t *testing.T) {
+	btc := dfeData.StreamKey{Venue: "binance", Instrument: "BTC/USD"}
+	eth := dfeData.StreamKey{Venue: "binance", Instrument: "ETH/USD"}
+	sol := dfeData.StreamKey{Venue: "kraken", Instrument: "SOL/USD"}
+
+	engine := (&StreamEngine{}).New(func(key dfeData.StreamKey) *FeatureEngineer {
+		featureEngineer := bootstrapWindowFeatures((&FeatureEngineer{}).New([]uint64 { 5 }), []uint64 { 5 })
+
+		// Invalid RSI fails on every tick
+		if key == btc {
+			featureEngineer.AppendFeature((&features.RSIFeature{}).New(0, 5))
+		}
+
+		return featureEngineer
+	}, 10)
+
+	if err := engine.Update(5, []*dfeData.InputData {
+		{DecimalCost: decimal.NewFromInt(65000), Timestamp: 5, Venue: btc.Venue, Instrument: btc.Instrument},
+		{DecimalCost: decimal.NewFromInt(4000), Timestamp: 5, Venue: eth.Venue, Instrument: eth.Instrument},
+		{DecimalCost: decimal.NewFromInt(150), Timestamp: 5, Venue: sol.Venue, Instrument: sol.Instrument},
+	}); err == nil {
+		t.Fatalf("StreamEngine.Update should return error of %s", btc)
+	}
+
+	err := engine.Update(20, []*dfeData.InputData {
+		{DecimalCost: decimal.NewFromInt(65100), Timestamp: 20, Venue: btc.Venue, Instrument: btc.Instrument},
+		{DecimalCost: decimal.NewFromInt(4100), Timestamp: 20, Venue: eth.Venue, Instrument: eth.Instrument},
+	})
+
+	if err == nil {
+		t.Errorf("StreamEngine.Update should return error of %s", btc)
+	}
+
+	// ETH after the failed key is updated and idle SOL is evicted
+	if !reflect.DeepEqual(engine.Keys(), []dfeData.StreamKey { btc, eth }) {
+		t.Errorf("StreamEngine.Update should evict idle keys despite error, got %v", engine.Keys())
+	}
+
+	if values := engine.GetFeatureEngineer(eth).GetVector(); !values[1].Equal(decimal.NewFromInt(4100)) {
+		t.Errorf("StreamEngine.Update should update keys after the failed one, got %v", values)
+	}
+}
+
+func TestStreamEngine_AppendPairFeature(t *testing.T) {
+	engine, _ := bootstrapStreamEngine(0)
+	btc := dfeData.StreamKey{Venue: "binance", Instrument: "BTC/USD"}
+	eth := dfeData.StreamKey{Venue: "binance", Instrument: "ETH/USD"}
+	engine.AppendPairFeature(eth, btc, (&features.CrossMomentsFeature{}).New(30))
+	engine.AppendPairFeature(eth, btc, (&features.CrossMomentsFeature{}).New(60))
+
+	ticks := []struct {
+		TimeCurrent uint64
+		eth int64
+		btc int64
+	}{
+		{5, 4000, 65000},
+		{10, 4400, 71500},
+		{15, 4000, 65000},
+	}
+
+	var vectors []Vector
+
+	for _, tick := range ticks {
+		data := []*dfeData.InputData {
+			{DecimalCost: decimal.NewFromInt(tick.btc), Timestamp: tick.TimeCurrent, Venue: btc.Venue, Instrument: btc.Instrument},
+			{DecimalCost: decimal.NewFromInt(tick.eth), Timestamp: tick.TimeCurrent, Venue: eth.Venue, Instrument: eth.Instrument},
+		}
+
+		if err := engine.Update(tick.TimeCurrent, data); err != nil {
+			t.Fatal(err)
+		}
+
+		vectors = vectors[:0]
+		engine.Emit(tick.TimeCurrent, func(vector Vector) {
+			vectors = append(vectors, vector)
+		})
+	}
+
+	// Vectors of keys go first, then one vector of the pair with values of both features
+	if len(vectors) != 3 || vectors[2].Key != eth || vectors[2].Pair != btc || len(vectors[2].Values) != 6 {
+		t.Fatalf("StreamEngine.Emit should emit vector of %s to %s after vectors of keys, got %v", eth, btc, vectors)
+	}
+
+	// Both moved by the same returns
+	if !vectors[2].Values[1].Round(9).Equal(decimal.NewFromInt(1)) || !vectors[2].Values[2].Round(9).Equal(decimal.NewFromInt(1)) {
+		t.Errorf("StreamEngine.Emit correlation and beta should be 1, got %v", vectors[2].Values)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	dfedata "data-feature-engineer/data"
+	"errors"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultTickSeconds by TZ we emit one vector every 5 seconds
+const DefaultTickSeconds = 5
+
+// Vector is a single output of the engine for one tick, Key is empty for a single stream,
+// Pair is the second key of vectors of pair features, it is empty otherwise
+type Vector struct {
+	Key dfedata.StreamKey
+	Pair dfedata.StreamKey
+	TimeCurrent uint64
+	Values []decimal.Decimal
+}
+
+// TickProcessor is anything TickScheduler can drive, FeatureEngineer is the main implementation
+type TickProcessor interface {
+	Update(TimeCurrent uint64, data []*dfedata.InputData) error
+	Emit(TimeCurrent uint64, emit func(vector Vector))
+}
+
+// TickScheduler splits sorted stream of data into ticks of TickSeconds and updates processor once per tick.
+// Tick T gets all data with Timestamp in (T - TickSeconds, T], ticks without data are still processed,
+// so features can carry forward the last value (there may be 0 data in a minute). Tick must be at least a second
+type TickScheduler struct {
+	TickSeconds uint64
+	Processor TickProcessor
+	OnEmit func(vector Vector)
+
+	started bool
+	nextTick uint64
+	lastTimestamp uint64
+	// pending is reused between ticks, so processor must not keep reference to the slice itself
+	pending []*dfedata.InputData
+}
+
+func (s *TickScheduler) New(TickSeconds uint64, processor TickProcessor, onEmit func(vector Vector)) *TickScheduler {
+	s.TickSeconds = TickSeconds
+	s.Processor = processor
+	s.OnEmit = onEmit
+	return s
+}
+
+// errZeroTick tick of 0 seconds never finishes
+var errZeroTick = errors.New("tick must be at least 1 second")
+
+// Push data must be sorted by Timestamp, every tick that is finished by data gets processed
+func (s *TickScheduler) Push(data ...*dfedata.InputData) error {
+	if s.TickSeconds == 0 {
+		return errZeroTick
+	}
+
+	for _, log := range data {
+		if !s.started {
+			s.start(log.Timestamp)
+		}
+
+		if log.Timestamp < s.lastTimestamp {
+			return errors.New("data are not sorted by timestamp")
+		}
+
+		// Data from the future finishes all ticks before it
+		for s.nextTick < log.Timestamp {
+			if err := s.processTick(); err != nil {
+				return err
+			}
+		}
+
+		s.lastTimestamp = log.Timestamp
+		s.pending = append(s.pending, log)
+	}
+
+	return nil
+}
+
+// Advance processes all ticks which end before TimeCurrent, it is used as a clock when there is no data
+func (s *TickScheduler) Advance(TimeCurrent uint64) error {
+	if s.TickSeconds == 0 {
+		return errZeroTick
+	}
+
+	if !s.started {
+		return nil
+	}
+
+	for s.nextTick < TimeCurrent {
+		if err := s.processTick(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Flush processes current tick even if it is not finished yet
+func (s *TickScheduler) Flush() error {
+	if !s.started || len(s.pending) == 0 {
+		return nil
+	}
+
+	return s.processTick()
+}
+
+func (s *TickScheduler) start(Timestamp uint64) {
+	s.started = true
+	// Rounding up to the tick boundary
+	s.nextTick = (Timestamp + s.TickSeconds - 1) / s.TickSeconds * s.TickSeconds
+}
+
+func (s *TickScheduler) processTick() error {
+	TimeCurrent := s.nextTick
+	s.nextTick += s.TickSeconds
+
+	err := s.Processor.Update(TimeCurrent, s.pending)
+	s.pending = s.pending[:0]
+
+	if err != nil {
+		return err
+	}
+
+	if s.OnEmit != nil {
+		s.Processor.Emit(TimeCurrent, s.OnEmit)
+	}
+
+	return nil
+}
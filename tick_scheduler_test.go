@@ -0,0 +1,94 @@
+package main
+
+import (
+	dfeData "data-feature-engineer/data"
+	"github.com/shopspring/decimal"
+	"reflect"
+	"testing"
+)
+
+type recordingProcessor struct {
+	ticks []uint64
+	batches [][]*dfeData.InputData
+}
+
+func (p *recordingProcessor) Update(TimeCurrent uint64, data []*dfeData.InputData) error {
+	p.ticks = append(p.ticks, TimeCurrent)
+	p.batches = append(p.batches, append([]*dfeData.InputData{}, data...))
+	return nil
+}
+
+func (p *recordingProcessor) Emit(TimeCurrent uint64, emit func(vector Vector)) {
+	emit(Vector{TimeCurrent: TimeCurrent})
+}
+
+func TestTickScheduler_Push(t *testing.T) {
+	data := []*dfeData.InputData {
+		{DecimalCost: decimal.NewFromInt(15), Timestamp: 3},
+		{DecimalCost: decimal.NewFromInt(16), Timestamp: 5},
+		{DecimalCost: decimal.NewFromInt(17), Timestamp: 6},
+		{DecimalCost: decimal.NewFromInt(18), Timestamp: 21},
+	}
+
+	processor := &recordingProcessor{}
+	var emitted []uint64
+	scheduler := (&TickScheduler{}).New(5, processor, func(vector Vector) {
+		emitted = append(emitted, vector.TimeCurrent)
+	})
+
+	if err := scheduler.Push(data...); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scheduler.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ticks 15 and 20 have no data but still should be processed
+	awaitTicks := []uint64 { 5, 10, 15, 20, 25 }
+
+	if !reflect.DeepEqual(awaitTicks, processor.ticks) || !reflect.DeepEqual(awaitTicks, emitted) {
+		t.Errorf("TickScheduler.Push ticks should be %v, got processed %v, emitted %v", awaitTicks, processor.ticks, emitted)
+	}
+
+	awaitBatches := [][]*dfeData.InputData { { data[0], data[1] }, { data[2] }, {}, {}, { data[3] } }
+
+	if !reflect.DeepEqual(awaitBatches, processor.batches) {
+		t.Errorf("TickScheduler.Push batches should be %v, got %v", awaitBatches, processor.batches)
+	}
+}
+
+func TestTickScheduler_Advance(t *testing.T) {
+	processor := &recordingProcessor{}
+	scheduler := (&TickScheduler{}).New(5, processor, nil)
+
+	if err := scheduler.Push(&dfeData.InputData{DecimalCost: decimal.NewFromInt(15), Timestamp: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scheduler.Advance(12); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual([]uint64 { 5, 10 }, processor.ticks) {
+		t.Errorf("TickScheduler.Advance(12) ticks should be [5 10], got %v", processor.ticks)
+	}
+
+	err := scheduler.Push(&dfeData.InputData{DecimalCost: decimal.NewFromInt(15), Timestamp: 0})
+
+	if err == nil {
+		t.Errorf("TickScheduler.Push should fail on unsorted data")
+	}
+}
+
+func TestTickScheduler_ZeroTick(t *testing.T) {
+	scheduler := (&TickScheduler{}).New(0, &recordingProcessor{}, nil)
+
+	if err := scheduler.Push(&dfeData.InputData{DecimalCost: decimal.NewFromInt(15), Timestamp: 1}); err == nil {
+		t.Errorf("TickScheduler.Push should fail for tick of 0 seconds")
+	}
+
+	if err := scheduler.Advance(12); err == nil {
+		t.Errorf("TickScheduler.Advance should fail for tick of 0 seconds")
+	}
+}